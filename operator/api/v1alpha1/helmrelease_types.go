@@ -153,6 +153,9 @@ type HelmReleaseStatus struct {
 	Message     string           `json:"message,omitempty"`
 	RetryCount  int32            `json:"retryCount,omitempty"`
 	HelmRelease *HelmReleaseInfo `json:"helmRelease,omitempty"`
+	// Images are the images of the release pods, recorded before it is
+	// uninstalled when spec.cleanup.deleteImages is set.
+	Images []string `json:"images,omitempty"`
 }
 
 //+kubebuilder:object:root=true
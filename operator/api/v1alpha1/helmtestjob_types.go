@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -24,18 +26,51 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+const (
+	// LabelHelmTestJob is set on every object created for a HelmTestJob run.
+	LabelHelmTestJob = "steer.io/helmtestjob"
+	// LabelRunKey identifies the run an object was created for.
+	LabelRunKey = "steer.io/run-key"
+	// LabelHelmTestJobNamespace is set on the namespaces of ephemeral
+	// environments, which live outside of the namespace of their
+	// HelmTestJob.
+	LabelHelmTestJobNamespace = "steer.io/helmtestjob-namespace"
+
+	// AnnotationRunRequestedAt requests a manual run of a HelmTestJob. Its
+	// value is an RFC3339 timestamp; setting a new value starts a new run.
+	// Under the Forbid concurrency policy the run starts once the active run
+	// finishes.
+	AnnotationRunRequestedAt = "steer.io/run-requested-at"
+
+	// AnnotationCancelRequestedAt requests cancellation of the active runs of
+	// a HelmTestJob. Its value is an RFC3339 timestamp.
+	AnnotationCancelRequestedAt = "steer.io/cancel-requested-at"
+	// AnnotationCancelRequestedBy records who requested the cancellation.
+	AnnotationCancelRequestedBy = "steer.io/cancel-requested-by"
+
+	// AnnotationResultFormat on a test pod selects how its output is parsed
+	// into test cases, like spec.test.resultFormat.
+	AnnotationResultFormat = "steer.io/result-format"
+)
+
 // HelmReleaseRef references a HelmRelease resource.
 type HelmReleaseRef struct {
-	Name      string `json:"name"`
+	Name string `json:"name"`
+	// Namespace of the HelmRelease, the HelmTestJob's when empty. Other
+	// namespaces must be listed in allowedTargetNamespaces of the operator
+	// config.
 	Namespace string `json:"namespace"`
 }
 
-// +kubebuilder:validation:Enum=once;cron
+// +kubebuilder:validation:Enum=once;cron;onRelease
 type ScheduleType string
 
 const (
 	ScheduleTypeOnce ScheduleType = "once"
 	ScheduleTypeCron ScheduleType = "cron"
+	// ScheduleTypeOnRelease starts a run whenever the referenced HelmRelease
+	// reaches a new Helm revision.
+	ScheduleTypeOnRelease ScheduleType = "onRelease"
 )
 
 type ScheduleSpec struct {
@@ -57,13 +92,41 @@ type ScheduleSpec struct {
 	Timezone string `json:"timezone,omitempty"`
 }
 
+// ConcurrencyPolicy describes how a due run is handled while a previous run is still active.
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyPolicyAllow starts the new run alongside the active ones.
+	ConcurrencyPolicyAllow ConcurrencyPolicy = "Allow"
+	// ConcurrencyPolicyForbid skips the new run while another one is active.
+	ConcurrencyPolicyForbid ConcurrencyPolicy = "Forbid"
+	// ConcurrencyPolicyReplace stops the active runs and starts the new one.
+	ConcurrencyPolicyReplace ConcurrencyPolicy = "Replace"
+)
+
+// Environment decides which installation of the release a run tests.
+// +kubebuilder:validation:Enum=shared;ephemeral
+type Environment string
+
+const (
+	// EnvironmentShared tests the release the HelmRelease installed.
+	EnvironmentShared Environment = "shared"
+	// EnvironmentEphemeral installs a copy of the release for each run into
+	// its own namespace, <release>-<hash>-<runKey>, where the hash tells
+	// HelmTestJobs apart. It needs an operator with a Helm client.
+	EnvironmentEphemeral Environment = "ephemeral"
+)
+
 type TestSpec struct {
 	// Image is the container image used to run helm test.
-	// If empty, the controller will fall back to env var STEER_JOB_IMAGE.
+	// If empty, the controller falls back to defaultImages.test of the
+	// operator config.
 	// +optional
 	Image string `json:"image,omitempty"`
 
-	// Timeout is the helm test timeout.
+	// Timeout is the helm test timeout. It is enforced as the
+	// activeDeadlineSeconds of the test Job.
 	// +kubebuilder:default="10m"
 	// +optional
 	Timeout metav1.Duration `json:"timeout,omitempty"`
@@ -76,14 +139,57 @@ type TestSpec struct {
 	// Filter limits the executed tests.
 	// +optional
 	Filter string `json:"filter,omitempty"`
+
+	// Env injects environment variables into the test container, e.g. the
+	// outputs of pre-test hooks.
+	// +optional
+	Env []HookEnvVar `json:"env,omitempty"`
+
+	// ResultFormat makes the controller parse the output of the test pod
+	// into test cases. Without it, the steer.io/result-format annotation of
+	// the test pod is used. A test with failed cases fails even when its pod
+	// succeeded.
+	// +optional
+	ResultFormat ResultFormat `json:"resultFormat,omitempty"`
+
+	// Retries is how often a failed test is retried. Retries get the names of
+	// the failed test cases in STEER_FAILED_TESTS, one per line, so they can
+	// run only those; their results replace the failed ones. A test that
+	// passes on a retry is flaky, see status.flakiness.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	Retries int32 `json:"retries,omitempty"`
 }
 
-// +kubebuilder:validation:Enum=script;kubernetes
+// ResultFormat is the format of structured test output.
+// +kubebuilder:validation:Enum=junit;tap;goTest
+type ResultFormat string
+
+const (
+	// ResultFormatJUnit is JUnit XML.
+	ResultFormatJUnit ResultFormat = "junit"
+	// ResultFormatTAP is the Test Anything Protocol.
+	ResultFormatTAP ResultFormat = "tap"
+	// ResultFormatGoTest is the output of go test -json.
+	ResultFormatGoTest ResultFormat = "goTest"
+)
+
+// +kubebuilder:validation:Enum=script;kubernetes;http;waitFor;chaos
 type HookType string
 
 const (
 	HookTypeScript     HookType = "script"
 	HookTypeKubernetes HookType = "kubernetes"
+	// HookTypeHTTP probes a Service of the release namespace from the
+	// controller.
+	HookTypeHTTP HookType = "http"
+	// HookTypeWaitFor waits for objects to reach a state, evaluated by the
+	// controller.
+	HookTypeWaitFor HookType = "waitFor"
+	// HookTypeChaos disrupts workloads of the release, evaluated by the
+	// controller.
+	HookTypeChaos HookType = "chaos"
 )
 
 type HookEnvVarSource struct {
@@ -95,6 +201,24 @@ type HookEnvVarSource struct {
 	// HelmReleaseRef references the referenced HelmRelease object.
 	// +optional
 	HelmReleaseRef *HookEnvVarHelmReleaseRefSource `json:"helmReleaseRef,omitempty"`
+
+	// HookOutput references an output of an earlier hook of the same run.
+	// +optional
+	HookOutput *HookEnvVarHookOutputSource `json:"hookOutput,omitempty"`
+}
+
+// HookEnvVarHookOutputSource selects an output written by a hook. The env
+// var refers to the outputs Secret of the run, <run>-outputs, and is unset
+// when the hook did not run or did not write the key.
+type HookEnvVarHookOutputSource struct {
+	// Hook is the name of a hook that finished before the consumer started:
+	// a pre-test hook for the test, or an earlier hook outside its parallel
+	// group for other hooks.
+	// +kubebuilder:validation:Required
+	Hook string `json:"hook"`
+
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
 }
 
 type HookEnvVarHelmReleaseRefSource struct {
@@ -123,11 +247,105 @@ type KubernetesHookSpec struct {
 	k8sruntime.RawExtension `json:",inline"`
 }
 
+// PodTemplateOverlay customizes the pods of the Jobs the controller creates
+// for tests and script hooks. Maps are merged, lists are appended (volumes
+// and mounts replace entries with the same name/path) and other fields
+// replace what is already set.
+type PodTemplateOverlay struct {
+	// Labels are added to the pod.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the pod.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// ServiceAccountName is the ServiceAccount the pod runs as. It must be
+	// listed in podTemplate.allowedServiceAccounts of the operator config.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Resources are applied to every container of the pod.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// SecurityContext is the pod-level security context.
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// ContainerSecurityContext is applied to every container of the pod.
+	// Privileged containers, privilege escalation and added capabilities need
+	// podTemplate.allowPrivileged in the operator config.
+	// +optional
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+
+	// Volumes are added to the pod. Their schema is left out of the CRD to
+	// keep it below the size limit of client-side apply; the webhook validates
+	// them instead. hostPath volumes need podTemplate.allowHostPath in the
+	// operator config.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts are added to every container of the pod.
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// HookRunPolicy decides whether a hook runs depending on the outcome of the run so far.
+// +kubebuilder:validation:Enum=onSuccess;onFailure;always
+type HookRunPolicy string
+
+const (
+	HookRunPolicyOnSuccess HookRunPolicy = "onSuccess"
+	HookRunPolicyOnFailure HookRunPolicy = "onFailure"
+	HookRunPolicyAlways    HookRunPolicy = "always"
+)
+
 type Hook struct {
 	Name string `json:"name"`
 	// +kubebuilder:validation:Required
 	Type HookType `json:"type"`
 
+	// RunPolicy decides whether the hook runs when an earlier step has failed.
+	// onSuccess hooks only run while nothing has failed, onFailure hooks only
+	// run after a failure and always hooks run regardless, e.g. for teardown.
+	// +kubebuilder:default=onSuccess
+	// +optional
+	RunPolicy HookRunPolicy `json:"runPolicy,omitempty"`
+
+	// ContinueOnError records a failure of this hook without failing the run.
+	// +optional
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+
+	// ParallelGroup runs consecutive hooks of a stage that share the same
+	// group concurrently. The stage moves on once every hook of the group
+	// has finished; runPolicy is evaluated against the steps before the group.
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	ParallelGroup string `json:"parallelGroup,omitempty"`
+
+	// Timeout bounds the hook. It is enforced as the activeDeadlineSeconds of
+	// the Job or Pod running the hook; hooks the controller executes itself
+	// fail once it has passed since they started.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// PodTemplate overrides spec.podTemplate for this hook. It only applies
+	// to script hooks; kubernetes hooks carry their own pod spec.
+	// +optional
+	PodTemplate *PodTemplateOverlay `json:"podTemplate,omitempty"`
+
 	// Env injects environment variables for script/kubernetes hooks.
 	// +optional
 	Env []HookEnvVar `json:"env,omitempty"`
@@ -139,6 +357,168 @@ type Hook struct {
 	// Kubernetes is only meaningful for type=kubernetes.
 	// +optional
 	Kubernetes *KubernetesHookSpec `json:"kubernetes,omitempty"`
+
+	// HTTP is only meaningful for type=http.
+	// +optional
+	HTTP *HTTPHookSpec `json:"http,omitempty"`
+
+	// WaitFor is only meaningful for type=waitFor.
+	// +optional
+	WaitFor *WaitForHookSpec `json:"waitFor,omitempty"`
+
+	// Chaos is only meaningful for type=chaos.
+	// +optional
+	Chaos *ChaosHookSpec `json:"chaos,omitempty"`
+}
+
+// HTTPHookSpec probes an HTTP endpoint until it answers as expected. The
+// controller sends the requests, so no pod is started for the hook. Only
+// Services in the release namespace can be probed: the host of the URL is
+// <service>, <service>.<namespace> or <service>.<namespace>.svc[.<domain>].
+// Redirects are not followed and the response body is not recorded.
+//
+// URL, header values and body are Go templates. They can reference
+// .Release and .Job, the HelmRelease and HelmTestJob as unstructured
+// objects, and .Outputs, the outputs of earlier hooks, e.g.
+// http://{{ .Release.metadata.name }}.{{ .Release.spec.deployment.namespace }}.svc/healthz
+// or {{ index .Outputs "login" "token" }}.
+type HTTPHookSpec struct {
+	// +kubebuilder:validation:Required
+	URL string `json:"url"`
+
+	// +kubebuilder:validation:Enum=GET;HEAD;POST;PUT;PATCH;DELETE;OPTIONS
+	// +kubebuilder:default=GET
+	// +optional
+	Method string `json:"method,omitempty"`
+
+	// +optional
+	Headers []HTTPHeader `json:"headers,omitempty"`
+
+	// Body is sent with the request.
+	// +optional
+	Body string `json:"body,omitempty"`
+
+	// ExpectedStatus lists the accepted status codes. Defaults to any 2xx.
+	// +optional
+	ExpectedStatus []int32 `json:"expectedStatus,omitempty"`
+
+	// BodyRegex must match the response body.
+	// +optional
+	BodyRegex string `json:"bodyRegex,omitempty"`
+
+	// Retries is the number of attempts after the first one before the
+	// hook fails.
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Retries int32 `json:"retries,omitempty"`
+
+	// Interval is the time between attempts.
+	// +kubebuilder:default="5s"
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+
+	// RequestTimeout bounds a single attempt. Hook.Timeout bounds all of them.
+	// +kubebuilder:default="10s"
+	// +optional
+	RequestTimeout metav1.Duration `json:"requestTimeout,omitempty"`
+}
+
+// WaitForHookSpec waits until the selected objects satisfy a condition or a
+// JSONPath expression, like kubectl wait. The controller polls the objects,
+// so no pod is started for the hook. It waits for Hook.Timeout, or 5m when
+// that is not set.
+type WaitForHookSpec struct {
+	// +kubebuilder:default="v1"
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind must be allowed by the operator config (allowedWaitForKinds),
+	// by default pods, services, endpoints, ConfigMaps, PVCs and workloads.
+	// Secrets never are.
+	// +kubebuilder:validation:Required
+	Kind string `json:"kind"`
+
+	// Namespace defaults to the target namespace of the HelmRelease, or the
+	// namespace of the run's ephemeral environment. No other namespace can
+	// be watched, and a target namespace other than the HelmTestJob's only
+	// when the operator config allows it (allowedTargetNamespaces).
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name selects a single object.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Selector selects the objects by label. All objects in the namespace
+	// are selected when neither name nor selector is set.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Condition is a status condition type that must be True, or
+	// type=status, e.g. Available or Ready=False.
+	// +optional
+	Condition string `json:"condition,omitempty"`
+
+	// JSONPath is evaluated on every object, e.g. {.status.phase}.
+	// +optional
+	JSONPath string `json:"jsonPath,omitempty"`
+
+	// Value is what JSONPath must render to. When empty, any non-empty
+	// result passes.
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// Interval is the time between checks.
+	// +kubebuilder:default="5s"
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=deletePods;scaleToZero
+type ChaosAction string
+
+const (
+	// ChaosActionDeletePods deletes pods matching a selector.
+	ChaosActionDeletePods ChaosAction = "deletePods"
+	// ChaosActionScaleToZero scales a Deployment to zero and back.
+	ChaosActionScaleToZero ChaosAction = "scaleToZero"
+)
+
+// ChaosHookSpec performs a controlled disruption of the release workloads,
+// to check the release survives it. Only objects in the target namespace of
+// the HelmRelease are touched, which must be the namespace of the
+// HelmTestJob, one listed in allowedTargetNamespaces of the operator config
+// or an ephemeral environment. What was disrupted is recorded in the hook
+// result and a scaled Deployment is restored however the run ends.
+type ChaosHookSpec struct {
+	// +kubebuilder:validation:Required
+	Action ChaosAction `json:"action"`
+
+	// Selector selects the pods to delete. Required for deletePods.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Count is the number of pods to delete.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Count int32 `json:"count,omitempty"`
+
+	// Deployment is the Deployment to scale. Required for scaleToZero.
+	// +optional
+	Deployment string `json:"deployment,omitempty"`
+
+	// Duration is how long the Deployment stays scaled to zero. The hook
+	// succeeds once it is back at its replicas and they are all ready.
+	// +kubebuilder:default="30s"
+	// +optional
+	Duration metav1.Duration `json:"duration,omitempty"`
+}
+
+type HTTPHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 type HooksSpec struct {
@@ -151,6 +531,35 @@ type HooksSpec struct {
 	PostTest []Hook `json:"postTest,omitempty"`
 }
 
+// HelmTestJobRBACSpec grants the pods of a run access to the release's
+// target namespace.
+type HelmTestJobRBACSpec struct {
+	// Rules are granted in the target namespace of the referenced
+	// HelmRelease, which must be the namespace of the HelmTestJob or listed
+	// in allowedTargetNamespaces of the operator config. They must stay
+	// within allowedRBACRules of the operator config. Defaults to reading the
+	// release's pods and workloads, without access to Secrets.
+	// +optional
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+}
+
+// CleanupWhen decides after which runs their environment is cleaned up.
+// +kubebuilder:validation:Enum=always;onSuccess;onFailure
+type CleanupWhen string
+
+const (
+	CleanupWhenAlways    CleanupWhen = "always"
+	CleanupWhenOnSuccess CleanupWhen = "onSuccess"
+	// CleanupWhenOnFailure also cleans up after cancelled runs.
+	CleanupWhenOnFailure CleanupWhen = "onFailure"
+)
+
+// HelmTestJobCleanupSpec decides how the environment is cleaned up after a
+// run. Ephemeral environments fall back to spec.cleanup of the HelmRelease
+// for unset fields and delete their namespace by default. The shared
+// environment is only cleaned up as set here, and only if its namespace is
+// the HelmTestJob's or listed in allowedTargetNamespaces of the operator
+// config.
 type HelmTestJobCleanupSpec struct {
 	// DeleteNamespace controls whether to delete the namespace.
 	// +optional
@@ -159,6 +568,16 @@ type HelmTestJobCleanupSpec struct {
 	// DeleteImages controls whether to delete images (if supported).
 	// +optional
 	DeleteImages *bool `json:"deleteImages,omitempty"`
+
+	// When decides after which runs to clean up. The default onSuccess keeps
+	// the environment of failed runs for debugging.
+	// +kubebuilder:default=onSuccess
+	// +optional
+	When CleanupWhen `json:"when,omitempty"`
+
+	// Delay postpones the cleanup after the run finished.
+	// +optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
 }
 
 // HelmTestJobSpec defines the desired state of HelmTestJob
@@ -167,6 +586,16 @@ type HelmTestJobSpec struct {
 	// +kubebuilder:validation:Required
 	HelmReleaseRef HelmReleaseRef `json:"helmReleaseRef"`
 
+	// Environment is shared to test the release installed by the
+	// HelmRelease, or ephemeral to install the HelmRelease spec into a new
+	// namespace for each run. Ephemeral environments are uninstalled and
+	// their namespace deleted per spec.cleanup, and at the latest when the
+	// run is pruned or the HelmTestJob deleted. Runs fail with ephemeral
+	// when the operator has no Helm client configured.
+	// +kubebuilder:default=shared
+	// +optional
+	Environment Environment `json:"environment,omitempty"`
+
 	// Schedule defines once/cron execution.
 	// +kubebuilder:validation:Required
 	Schedule ScheduleSpec `json:"schedule"`
@@ -179,12 +608,53 @@ type HelmTestJobSpec struct {
 	// +optional
 	Hooks HooksSpec `json:"hooks,omitempty"`
 
-	// Cleanup can override HelmRelease cleanup settings.
+	// PodTemplate is merged into the pods of the test Job and of script hook
+	// Jobs, e.g. to set resource limits or a ServiceAccount.
+	// +optional
+	PodTemplate *PodTemplateOverlay `json:"podTemplate,omitempty"`
+
+	// RBAC makes each run create a ServiceAccount for its pods, bound to a
+	// Role in the release's target namespace. They are deleted when the run
+	// finishes.
+	// +optional
+	RBAC *HelmTestJobRBACSpec `json:"rbac,omitempty"`
+
+	// Cleanup decides how the environment is cleaned up after runs.
 	// +optional
 	Cleanup *HelmTestJobCleanupSpec `json:"cleanup,omitempty"`
+
+	// ConcurrencyPolicy decides what happens when a run is due while a
+	// previous run is still active.
+	// +kubebuilder:default=Forbid
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// RunTimeout bounds a whole run. When it is exceeded the active child Jobs
+	// are stopped, only post-test hooks with runPolicy always still run and
+	// the run fails.
+	// +optional
+	RunTimeout *metav1.Duration `json:"runTimeout,omitempty"`
+
+	// StartingDeadlineSeconds is the deadline for starting a cron run after
+	// its scheduled time. Runs that miss it are skipped.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// SuccessfulRunsHistoryLimit is the number of succeeded HelmTestRuns to keep.
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SuccessfulRunsHistoryLimit *int32 `json:"successfulRunsHistoryLimit,omitempty"`
+
+	// FailedRunsHistoryLimit is the number of failed HelmTestRuns to keep.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedRunsHistoryLimit *int32 `json:"failedRunsHistoryLimit,omitempty"`
 }
 
-// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed;Skipped;Cancelled
 type HelmTestJobPhase string
 
 const (
@@ -192,29 +662,207 @@ const (
 	HelmTestJobPhaseRunning   HelmTestJobPhase = "Running"
 	HelmTestJobPhaseSucceeded HelmTestJobPhase = "Succeeded"
 	HelmTestJobPhaseFailed    HelmTestJobPhase = "Failed"
+	// HelmTestJobPhaseSkipped is only used for hook and test results.
+	HelmTestJobPhaseSkipped HelmTestJobPhase = "Skipped"
+	// HelmTestJobPhaseCancelled is a run stopped by a cancel request.
+	HelmTestJobPhaseCancelled HelmTestJobPhase = "Cancelled"
 )
 
+// IsFinished reports whether a run, hook, test or cleanup in this phase is
+// done and won't change anymore.
+func (p HelmTestJobPhase) IsFinished() bool {
+	switch p {
+	case HelmTestJobPhaseSucceeded, HelmTestJobPhaseFailed, HelmTestJobPhaseSkipped, HelmTestJobPhaseCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
 type TestResult struct {
 	Name string `json:"name"`
 
 	// Phase is per-test result.
-	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed;Skipped
 	Phase HelmTestJobPhase `json:"phase"`
 
+	// Message explains why the test failed or was skipped.
+	// +optional
+	Message string `json:"message,omitempty"`
+
 	// +optional
 	StartedAt *metav1.Time `json:"startedAt,omitempty"`
 	// +optional
 	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+
+	// JobName is the Job the test ran in.
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// PodName is the Pod the test ran in.
+	// +optional
+	PodName string `json:"podName,omitempty"`
+
+	// ImageID is the image the test ran, including its digest.
+	// +optional
+	ImageID string `json:"imageID,omitempty"`
+
+	// Logs is the tail of the test container logs.
 	// +optional
 	Logs string `json:"logs,omitempty"`
+
+	// LogRef is the key of the full log in the log sink of the operator.
+	// The web server serves it.
+	// +optional
+	LogRef string `json:"logRef,omitempty"`
+
+	// Counts summarizes the test cases parsed from the output.
+	// +optional
+	Counts *TestCaseCounts `json:"counts,omitempty"`
+
+	// Cases are the test cases parsed from the output, see
+	// spec.test.resultFormat. Only the first cases are kept for large
+	// outputs; Counts covers all of them.
+	// +optional
+	Cases []TestCaseResult `json:"cases,omitempty"`
+
+	// Attempts is the number of the current attempt with spec.test.retries.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// PreviousAttempts records the failed attempts before the current one.
+	// +optional
+	PreviousAttempts []TestAttempt `json:"previousAttempts,omitempty"`
+}
+
+// TestAttempt is a failed attempt of a test.
+type TestAttempt struct {
+	JobName string `json:"jobName"`
+	// +optional
+	PodName string `json:"podName,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	LogRef string `json:"logRef,omitempty"`
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+type TestCaseCounts struct {
+	Total   int32 `json:"total"`
+	Passed  int32 `json:"passed"`
+	Failed  int32 `json:"failed"`
+	Skipped int32 `json:"skipped"`
+}
+
+// TestFlakiness counts how often a test only passed after a retry.
+type TestFlakiness struct {
+	Name string `json:"name"`
+	// Runs is the number of runs the test ran in.
+	Runs int32 `json:"runs"`
+	// FlakyRuns is the number of runs the test only passed after a retry.
+	FlakyRuns int32 `json:"flakyRuns"`
+	// Score is the percentage of flaky runs among the recent runs.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Score int32 `json:"score"`
+}
+
+// TestCaseResult is a single test case of a test pod.
+type TestCaseResult struct {
+	Name string `json:"name"`
+	// Suite is the suite, class or package of the case.
+	// +optional
+	Suite string `json:"suite,omitempty"`
+	// +kubebuilder:validation:Enum=Succeeded;Failed;Skipped
+	Phase HelmTestJobPhase `json:"phase"`
+	// Message is the failure or skip message, truncated.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// Attempts is how often a retried case ran. A case that succeeded after
+	// more than one attempt is flaky.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
 }
 
 type HookResult struct {
 	Name string `json:"name"`
-	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed;Skipped
 	Phase HelmTestJobPhase `json:"phase"`
 	// +optional
 	Message string `json:"message,omitempty"`
+
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+
+	// ExitCode of the hook container, once it has terminated.
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// JobName is the Job (or embedded object) the hook ran in.
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// PodName is the Pod the hook ran in.
+	// +optional
+	PodName string `json:"podName,omitempty"`
+
+	// ImageID is the image the hook ran, including its digest.
+	// +optional
+	ImageID string `json:"imageID,omitempty"`
+
+	// Logs is the tail of the hook container logs.
+	// +optional
+	Logs string `json:"logs,omitempty"`
+
+	// LogRef is the key of the full log in the log sink of the operator.
+	// +optional
+	LogRef string `json:"logRef,omitempty"`
+
+	// OutputKeys are the keys of the key/value pairs the hook wrote to its
+	// termination message, harvested once it succeeded. The values may be
+	// credentials: they are kept in the Secret <run>-outputs owned by the
+	// run, under <stage>-<index>.<key>, e.g. preTest-0.token.
+	// +optional
+	OutputKeys []string `json:"outputKeys,omitempty"`
+
+	// Attempts is the number of attempts of a hook the controller executes
+	// itself, e.g. an http probe or a waitFor check.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// LastAttemptAt is when the last of those attempts was made.
+	// +optional
+	LastAttemptAt *metav1.Time `json:"lastAttemptAt,omitempty"`
+
+	// Disruption records what a chaos hook disrupted.
+	// +optional
+	Disruption *HookDisruption `json:"disruption,omitempty"`
+}
+
+// HookDisruption records the objects a chaos hook disrupted.
+type HookDisruption struct {
+	Action    ChaosAction `json:"action"`
+	Namespace string      `json:"namespace"`
+
+	// Targets are the names of the deleted pods or the scaled Deployment.
+	// +optional
+	Targets []string `json:"targets,omitempty"`
+
+	// OriginalReplicas is the replica count a scaled Deployment is restored to.
+	// +optional
+	OriginalReplicas *int32 `json:"originalReplicas,omitempty"`
+
+	// +optional
+	DisruptedAt *metav1.Time `json:"disruptedAt,omitempty"`
+
+	// RestoredAt is when a scaled Deployment was scaled back.
+	// +optional
+	RestoredAt *metav1.Time `json:"restoredAt,omitempty"`
 }
 
 type HookResults struct {
@@ -233,7 +881,9 @@ const (
 	HelmTestJobStagePostTest HelmTestJobStage = "PostTest"
 )
 
-// HelmTestJobStatus defines the observed state of HelmTestJob
+// HelmTestJobStatus defines the observed state of HelmTestJob.
+// Each execution is recorded in its own HelmTestRun; the phase, times and
+// message here summarize the latest one.
 type HelmTestJobStatus struct {
 	// Phase indicates current state.
 	// +optional
@@ -244,12 +894,6 @@ type HelmTestJobStatus struct {
 	// +optional
 	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 
-	// +optional
-	TestResults []TestResult `json:"testResults,omitempty"`
-
-	// +optional
-	HookResults *HookResults `json:"hookResults,omitempty"`
-
 	// +optional
 	Message string `json:"message,omitempty"`
 
@@ -262,19 +906,45 @@ type HelmTestJobStatus struct {
 	// +optional
 	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
 
-	// CurrentStage indicates which stage is being executed.
+	// LastRunName is the name of the latest HelmTestRun.
+	// +optional
+	LastRunName string `json:"lastRunName,omitempty"`
+
+	// TestCounts summarizes the test cases of the latest run when its test
+	// output was parsed.
+	// +optional
+	TestCounts *TestCaseCounts `json:"testCounts,omitempty"`
+
+	// Flakiness scores the tests of the job across its runs. Test cases are
+	// named after their suite and name; tests without parsed cases are
+	// named "test".
+	// +optional
+	Flakiness []TestFlakiness `json:"flakiness,omitempty"`
+
+	// FlakinessRuns are the retained runs already counted in Flakiness.
+	// +optional
+	FlakinessRuns []string `json:"flakinessRuns,omitempty"`
+
+	// LastTestedRevision is the last HelmRelease revision a run was started
+	// for. Only meaningful for onRelease schedules.
+	// +optional
+	LastTestedRevision int64 `json:"lastTestedRevision,omitempty"`
+
+	// LastRunRequest is the last handled value of the
+	// steer.io/run-requested-at annotation.
 	// +optional
-	CurrentStage HelmTestJobStage `json:"currentStage,omitempty"`
+	LastRunRequest string `json:"lastRunRequest,omitempty"`
 
-	// CurrentIndex is the index of the current hook being executed within the stage.
-	// Only meaningful for PreTest/PostTest.
+	// LastCancelRequest is the last handled value of the
+	// steer.io/cancel-requested-at annotation.
 	// +optional
-	CurrentIndex int32 `json:"currentIndex,omitempty"`
+	LastCancelRequest string `json:"lastCancelRequest,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Last Run",type=string,JSONPath=`.status.lastRunName`
 //+kubebuilder:printcolumn:name="Next",type=string,JSONPath=`.status.nextScheduleTime`,priority=1
 //+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
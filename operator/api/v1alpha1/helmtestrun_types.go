@@ -0,0 +1,220 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HelmTestRunTrigger describes what started a run.
+// +kubebuilder:validation:Enum=Schedule;Manual;Release
+type HelmTestRunTrigger string
+
+const (
+	// HelmTestRunTriggerSchedule is a run started by the job schedule.
+	HelmTestRunTriggerSchedule HelmTestRunTrigger = "Schedule"
+	// HelmTestRunTriggerManual is a run requested through the
+	// steer.io/run-requested-at annotation.
+	HelmTestRunTriggerManual HelmTestRunTrigger = "Manual"
+	// HelmTestRunTriggerRelease is a run started by a new HelmRelease
+	// revision (schedule type onRelease).
+	HelmTestRunTriggerRelease HelmTestRunTrigger = "Release"
+)
+
+// HelmTestRunSpec defines a single execution of a HelmTestJob.
+// It is written by the controller when the run starts.
+type HelmTestRunSpec struct {
+	// HelmTestJobName is the HelmTestJob this run belongs to.
+	HelmTestJobName string `json:"helmTestJobName"`
+
+	// RunKey identifies the run. Child objects are labeled with it.
+	RunKey string `json:"runKey"`
+
+	// +optional
+	Trigger HelmTestRunTrigger `json:"trigger,omitempty"`
+
+	// ScheduledTime is the time the run was due, or requested for manual runs.
+	// +optional
+	ScheduledTime *metav1.Time `json:"scheduledTime,omitempty"`
+
+	// ReleaseRevision is the Helm revision of the referenced HelmRelease
+	// that was tested, i.e. the revision when the run started.
+	// +optional
+	ReleaseRevision int64 `json:"releaseRevision,omitempty"`
+}
+
+// HelmTestRunRBACStatus describes the ServiceAccount a run's pods use and
+// where its Role and RoleBinding live.
+type HelmTestRunRBACStatus struct {
+	// ServiceAccountName is the ServiceAccount in the HelmTestJob namespace.
+	ServiceAccountName string `json:"serviceAccountName"`
+	// Namespace holds the Role and RoleBinding, i.e. the release's target
+	// namespace.
+	Namespace string `json:"namespace"`
+}
+
+// HelmTestRunEnvironmentStatus describes the ephemeral environment of a run.
+type HelmTestRunEnvironmentStatus struct {
+	// Namespace is the generated namespace the release is installed into.
+	Namespace string `json:"namespace"`
+	// ReleaseName is the name of the Helm release.
+	ReleaseName string `json:"releaseName"`
+	// Revision is the installed revision of the Helm release.
+	// +optional
+	Revision int64 `json:"revision,omitempty"`
+	// +optional
+	InstalledAt *metav1.Time `json:"installedAt,omitempty"`
+	// UninstalledAt is set once the cleanup uninstalled the release.
+	// +optional
+	UninstalledAt *metav1.Time `json:"uninstalledAt,omitempty"`
+}
+
+// HelmTestRunCleanupStatus tracks the cleanup of the release's target
+// namespace, or of the ephemeral environment, after a run. See spec.cleanup
+// of the HelmTestJob.
+type HelmTestRunCleanupStatus struct {
+	// Namespace is the namespace that is cleaned up.
+	Namespace string `json:"namespace"`
+	// +optional
+	DeleteNamespace bool `json:"deleteNamespace,omitempty"`
+	// +optional
+	DeleteImages bool `json:"deleteImages,omitempty"`
+	// Images are the images of the pods in the namespace, recorded when the
+	// cleanup starts so they are still known once the environment is
+	// uninstalled.
+	// +optional
+	Images []string `json:"images,omitempty"`
+
+	// Phase is Skipped when spec.cleanup.when keeps the environment of the
+	// run.
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed;Skipped
+	Phase HelmTestJobPhase `json:"phase"`
+	// Message explains why the cleanup is still running, failed or was
+	// skipped, e.g. the finalizers a terminating namespace is stuck on.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ScheduledAt is when a Pending cleanup starts, after spec.cleanup.delay.
+	// +optional
+	ScheduledAt *metav1.Time `json:"scheduledAt,omitempty"`
+
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// HelmTestRunStatus defines the observed state of HelmTestRun.
+type HelmTestRunStatus struct {
+	// +optional
+	Phase HelmTestJobPhase `json:"phase,omitempty"`
+
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// CancelledAt is set once cancellation was requested. The run then only
+	// executes post-test hooks with runPolicy always before it is Cancelled.
+	// +optional
+	CancelledAt *metav1.Time `json:"cancelledAt,omitempty"`
+	// CancelledBy records who requested the cancellation.
+	// +optional
+	CancelledBy string `json:"cancelledBy,omitempty"`
+
+	// TimedOutAt is set once the run exceeded spec.runTimeout of its
+	// HelmTestJob. Like a cancelled run, it then only executes post-test
+	// hooks with runPolicy always before it fails.
+	// +optional
+	TimedOutAt *metav1.Time `json:"timedOutAt,omitempty"`
+
+	// Environment is the ephemeral environment of the run, with
+	// spec.environment ephemeral on the HelmTestJob.
+	// +optional
+	Environment *HelmTestRunEnvironmentStatus `json:"environment,omitempty"`
+
+	// RBAC records the objects created for spec.rbac of the HelmTestJob.
+	// +optional
+	RBAC *HelmTestRunRBACStatus `json:"rbac,omitempty"`
+
+	// CurrentStage indicates which stage is being executed.
+	// +optional
+	CurrentStage HelmTestJobStage `json:"currentStage,omitempty"`
+
+	// CurrentIndex is the index of the first hook of the current step within
+	// the stage; a parallel group runs as one step. The state of each hook is
+	// tracked in HookResults. Only meaningful for PreTest/PostTest.
+	// +optional
+	CurrentIndex int32 `json:"currentIndex,omitempty"`
+
+	// +optional
+	TestResults []TestResult `json:"testResults,omitempty"`
+
+	// +optional
+	HookResults *HookResults `json:"hookResults,omitempty"`
+
+	// Reports references the JUnit and JSON reports of the finished run in
+	// the log sink of the operator.
+	// +optional
+	Reports *HelmTestRunReports `json:"reports,omitempty"`
+
+	// Cleanup tracks the cleanup after the run finished.
+	// +optional
+	Cleanup *HelmTestRunCleanupStatus `json:"cleanup,omitempty"`
+}
+
+type HelmTestRunReports struct {
+	// JUnit is the key of the JUnit XML report.
+	// +optional
+	JUnit string `json:"junit,omitempty"`
+	// JSON is the key of the JSON report.
+	// +optional
+	JSON string `json:"json,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Job",type=string,JSONPath=`.spec.helmTestJobName`
+//+kubebuilder:printcolumn:name="Trigger",type=string,JSONPath=`.spec.trigger`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.spec.releaseRevision`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// HelmTestRun is the Schema for the helmtestruns API
+type HelmTestRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HelmTestRunSpec   `json:"spec,omitempty"`
+	Status HelmTestRunStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HelmTestRunList contains a list of HelmTestRun
+type HelmTestRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HelmTestRun `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HelmTestRun{}, &HelmTestRunList{})
+}
@@ -21,9 +21,33 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChaosHookSpec) DeepCopyInto(out *ChaosHookSpec) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChaosHookSpec.
+func (in *ChaosHookSpec) DeepCopy() *ChaosHookSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ChaosHookSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ChartSpec) DeepCopyInto(out *ChartSpec) {
 	*out = *in
@@ -117,6 +141,48 @@ func (in *GitChartSpec) DeepCopy() *GitChartSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPHeader) DeepCopyInto(out *HTTPHeader) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPHeader.
+func (in *HTTPHeader) DeepCopy() *HTTPHeader {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPHeader)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPHookSpec) DeepCopyInto(out *HTTPHookSpec) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]HTTPHeader, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExpectedStatus != nil {
+		in, out := &in.ExpectedStatus, &out.ExpectedStatus
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	out.Interval = in.Interval
+	out.RequestTimeout = in.RequestTimeout
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPHookSpec.
+func (in *HTTPHookSpec) DeepCopy() *HTTPHookSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPHookSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HelmRelease) DeepCopyInto(out *HelmRelease) {
 	*out = *in
@@ -241,6 +307,11 @@ func (in *HelmReleaseStatus) DeepCopyInto(out *HelmReleaseStatus) {
 		*out = new(HelmReleaseInfo)
 		**out = **in
 	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmReleaseStatus.
@@ -293,6 +364,11 @@ func (in *HelmTestJobCleanupSpec) DeepCopyInto(out *HelmTestJobCleanupSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.Delay != nil {
+		in, out := &in.Delay, &out.Delay
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestJobCleanupSpec.
@@ -337,6 +413,28 @@ func (in *HelmTestJobList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmTestJobRBACSpec) DeepCopyInto(out *HelmTestJobRBACSpec) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]rbacv1.PolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestJobRBACSpec.
+func (in *HelmTestJobRBACSpec) DeepCopy() *HelmTestJobRBACSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmTestJobRBACSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HelmTestJobSpec) DeepCopyInto(out *HelmTestJobSpec) {
 	*out = *in
@@ -344,11 +442,41 @@ func (in *HelmTestJobSpec) DeepCopyInto(out *HelmTestJobSpec) {
 	out.Schedule = in.Schedule
 	in.Test.DeepCopyInto(&out.Test)
 	in.Hooks.DeepCopyInto(&out.Hooks)
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(PodTemplateOverlay)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RBAC != nil {
+		in, out := &in.RBAC, &out.RBAC
+		*out = new(HelmTestJobRBACSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Cleanup != nil {
 		in, out := &in.Cleanup, &out.Cleanup
 		*out = new(HelmTestJobCleanupSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RunTimeout != nil {
+		in, out := &in.RunTimeout, &out.RunTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.StartingDeadlineSeconds != nil {
+		in, out := &in.StartingDeadlineSeconds, &out.StartingDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.SuccessfulRunsHistoryLimit != nil {
+		in, out := &in.SuccessfulRunsHistoryLimit, &out.SuccessfulRunsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailedRunsHistoryLimit != nil {
+		in, out := &in.FailedRunsHistoryLimit, &out.FailedRunsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestJobSpec.
@@ -372,6 +500,233 @@ func (in *HelmTestJobStatus) DeepCopyInto(out *HelmTestJobStatus) {
 		in, out := &in.CompletionTime, &out.CompletionTime
 		*out = (*in).DeepCopy()
 	}
+	if in.NextScheduleTime != nil {
+		in, out := &in.NextScheduleTime, &out.NextScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.TestCounts != nil {
+		in, out := &in.TestCounts, &out.TestCounts
+		*out = new(TestCaseCounts)
+		**out = **in
+	}
+	if in.Flakiness != nil {
+		in, out := &in.Flakiness, &out.Flakiness
+		*out = make([]TestFlakiness, len(*in))
+		copy(*out, *in)
+	}
+	if in.FlakinessRuns != nil {
+		in, out := &in.FlakinessRuns, &out.FlakinessRuns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestJobStatus.
+func (in *HelmTestJobStatus) DeepCopy() *HelmTestJobStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmTestJobStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmTestRun) DeepCopyInto(out *HelmTestRun) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestRun.
+func (in *HelmTestRun) DeepCopy() *HelmTestRun {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmTestRun)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HelmTestRun) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmTestRunCleanupStatus) DeepCopyInto(out *HelmTestRunCleanupStatus) {
+	*out = *in
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ScheduledAt != nil {
+		in, out := &in.ScheduledAt, &out.ScheduledAt
+		*out = (*in).DeepCopy()
+	}
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestRunCleanupStatus.
+func (in *HelmTestRunCleanupStatus) DeepCopy() *HelmTestRunCleanupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmTestRunCleanupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmTestRunEnvironmentStatus) DeepCopyInto(out *HelmTestRunEnvironmentStatus) {
+	*out = *in
+	if in.InstalledAt != nil {
+		in, out := &in.InstalledAt, &out.InstalledAt
+		*out = (*in).DeepCopy()
+	}
+	if in.UninstalledAt != nil {
+		in, out := &in.UninstalledAt, &out.UninstalledAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestRunEnvironmentStatus.
+func (in *HelmTestRunEnvironmentStatus) DeepCopy() *HelmTestRunEnvironmentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmTestRunEnvironmentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmTestRunList) DeepCopyInto(out *HelmTestRunList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HelmTestRun, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestRunList.
+func (in *HelmTestRunList) DeepCopy() *HelmTestRunList {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmTestRunList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HelmTestRunList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmTestRunRBACStatus) DeepCopyInto(out *HelmTestRunRBACStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestRunRBACStatus.
+func (in *HelmTestRunRBACStatus) DeepCopy() *HelmTestRunRBACStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmTestRunRBACStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmTestRunReports) DeepCopyInto(out *HelmTestRunReports) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestRunReports.
+func (in *HelmTestRunReports) DeepCopy() *HelmTestRunReports {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmTestRunReports)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmTestRunSpec) DeepCopyInto(out *HelmTestRunSpec) {
+	*out = *in
+	if in.ScheduledTime != nil {
+		in, out := &in.ScheduledTime, &out.ScheduledTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestRunSpec.
+func (in *HelmTestRunSpec) DeepCopy() *HelmTestRunSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmTestRunSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmTestRunStatus) DeepCopyInto(out *HelmTestRunStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CancelledAt != nil {
+		in, out := &in.CancelledAt, &out.CancelledAt
+		*out = (*in).DeepCopy()
+	}
+	if in.TimedOutAt != nil {
+		in, out := &in.TimedOutAt, &out.TimedOutAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Environment != nil {
+		in, out := &in.Environment, &out.Environment
+		*out = new(HelmTestRunEnvironmentStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RBAC != nil {
+		in, out := &in.RBAC, &out.RBAC
+		*out = new(HelmTestRunRBACStatus)
+		**out = **in
+	}
 	if in.TestResults != nil {
 		in, out := &in.TestResults, &out.TestResults
 		*out = make([]TestResult, len(*in))
@@ -384,22 +739,24 @@ func (in *HelmTestJobStatus) DeepCopyInto(out *HelmTestJobStatus) {
 		*out = new(HookResults)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.NextScheduleTime != nil {
-		in, out := &in.NextScheduleTime, &out.NextScheduleTime
-		*out = (*in).DeepCopy()
+	if in.Reports != nil {
+		in, out := &in.Reports, &out.Reports
+		*out = new(HelmTestRunReports)
+		**out = **in
 	}
-	if in.LastScheduleTime != nil {
-		in, out := &in.LastScheduleTime, &out.LastScheduleTime
-		*out = (*in).DeepCopy()
+	if in.Cleanup != nil {
+		in, out := &in.Cleanup, &out.Cleanup
+		*out = new(HelmTestRunCleanupStatus)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestJobStatus.
-func (in *HelmTestJobStatus) DeepCopy() *HelmTestJobStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmTestRunStatus.
+func (in *HelmTestRunStatus) DeepCopy() *HelmTestRunStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HelmTestJobStatus)
+	out := new(HelmTestRunStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -407,6 +764,16 @@ func (in *HelmTestJobStatus) DeepCopy() *HelmTestJobStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Hook) DeepCopyInto(out *Hook) {
 	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(PodTemplateOverlay)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Env != nil {
 		in, out := &in.Env, &out.Env
 		*out = make([]HookEnvVar, len(*in))
@@ -419,6 +786,21 @@ func (in *Hook) DeepCopyInto(out *Hook) {
 		*out = new(KubernetesHookSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(HTTPHookSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WaitFor != nil {
+		in, out := &in.WaitFor, &out.WaitFor
+		*out = new(WaitForHookSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Chaos != nil {
+		in, out := &in.Chaos, &out.Chaos
+		*out = new(ChaosHookSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hook.
@@ -431,6 +813,39 @@ func (in *Hook) DeepCopy() *Hook {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HookDisruption) DeepCopyInto(out *HookDisruption) {
+	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OriginalReplicas != nil {
+		in, out := &in.OriginalReplicas, &out.OriginalReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DisruptedAt != nil {
+		in, out := &in.DisruptedAt, &out.DisruptedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.RestoredAt != nil {
+		in, out := &in.RestoredAt, &out.RestoredAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HookDisruption.
+func (in *HookDisruption) DeepCopy() *HookDisruption {
+	if in == nil {
+		return nil
+	}
+	out := new(HookDisruption)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HookEnvVar) DeepCopyInto(out *HookEnvVar) {
 	*out = *in
@@ -466,6 +881,21 @@ func (in *HookEnvVarHelmReleaseRefSource) DeepCopy() *HookEnvVarHelmReleaseRefSo
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HookEnvVarHookOutputSource) DeepCopyInto(out *HookEnvVarHookOutputSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HookEnvVarHookOutputSource.
+func (in *HookEnvVarHookOutputSource) DeepCopy() *HookEnvVarHookOutputSource {
+	if in == nil {
+		return nil
+	}
+	out := new(HookEnvVarHookOutputSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HookEnvVarSource) DeepCopyInto(out *HookEnvVarSource) {
 	*out = *in
@@ -474,6 +904,11 @@ func (in *HookEnvVarSource) DeepCopyInto(out *HookEnvVarSource) {
 		*out = new(HookEnvVarHelmReleaseRefSource)
 		**out = **in
 	}
+	if in.HookOutput != nil {
+		in, out := &in.HookOutput, &out.HookOutput
+		*out = new(HookEnvVarHookOutputSource)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HookEnvVarSource.
@@ -489,6 +924,33 @@ func (in *HookEnvVarSource) DeepCopy() *HookEnvVarSource {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HookResult) DeepCopyInto(out *HookResult) {
 	*out = *in
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		*out = new(int32)
+		**out = **in
+	}
+	if in.OutputKeys != nil {
+		in, out := &in.OutputKeys, &out.OutputKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastAttemptAt != nil {
+		in, out := &in.LastAttemptAt, &out.LastAttemptAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Disruption != nil {
+		in, out := &in.Disruption, &out.Disruption
+		*out = new(HookDisruption)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HookResult.
@@ -507,12 +969,16 @@ func (in *HookResults) DeepCopyInto(out *HookResults) {
 	if in.PreTest != nil {
 		in, out := &in.PreTest, &out.PreTest
 		*out = make([]HookResult, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.PostTest != nil {
 		in, out := &in.PostTest, &out.PostTest
 		*out = make([]HookResult, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -586,6 +1052,83 @@ func (in *LocalChartSpec) DeepCopy() *LocalChartSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTemplateOverlay) DeepCopyInto(out *PodTemplateOverlay) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]v1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTemplateOverlay.
+func (in *PodTemplateOverlay) DeepCopy() *PodTemplateOverlay {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTemplateOverlay)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepositoryChartSpec) DeepCopyInto(out *RepositoryChartSpec) {
 	*out = *in
@@ -632,6 +1175,75 @@ func (in *SecretKeySelector) DeepCopy() *SecretKeySelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestAttempt) DeepCopyInto(out *TestAttempt) {
+	*out = *in
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestAttempt.
+func (in *TestAttempt) DeepCopy() *TestAttempt {
+	if in == nil {
+		return nil
+	}
+	out := new(TestAttempt)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestCaseCounts) DeepCopyInto(out *TestCaseCounts) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestCaseCounts.
+func (in *TestCaseCounts) DeepCopy() *TestCaseCounts {
+	if in == nil {
+		return nil
+	}
+	out := new(TestCaseCounts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestCaseResult) DeepCopyInto(out *TestCaseResult) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestCaseResult.
+func (in *TestCaseResult) DeepCopy() *TestCaseResult {
+	if in == nil {
+		return nil
+	}
+	out := new(TestCaseResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestFlakiness) DeepCopyInto(out *TestFlakiness) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestFlakiness.
+func (in *TestFlakiness) DeepCopy() *TestFlakiness {
+	if in == nil {
+		return nil
+	}
+	out := new(TestFlakiness)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TestResult) DeepCopyInto(out *TestResult) {
 	*out = *in
@@ -643,6 +1255,25 @@ func (in *TestResult) DeepCopyInto(out *TestResult) {
 		in, out := &in.CompletedAt, &out.CompletedAt
 		*out = (*in).DeepCopy()
 	}
+	if in.Counts != nil {
+		in, out := &in.Counts, &out.Counts
+		*out = new(TestCaseCounts)
+		**out = **in
+	}
+	if in.Cases != nil {
+		in, out := &in.Cases, &out.Cases
+		*out = make([]TestCaseResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PreviousAttempts != nil {
+		in, out := &in.PreviousAttempts, &out.PreviousAttempts
+		*out = make([]TestAttempt, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestResult.
@@ -664,6 +1295,13 @@ func (in *TestSpec) DeepCopyInto(out *TestSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]HookEnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestSpec.
@@ -722,3 +1360,24 @@ func (in *ValuesSpec) DeepCopy() *ValuesSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WaitForHookSpec) DeepCopyInto(out *WaitForHookSpec) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Interval = in.Interval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WaitForHookSpec.
+func (in *WaitForHookSpec) DeepCopy() *WaitForHookSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WaitForHookSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -25,10 +25,13 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -37,6 +40,12 @@ import (
 	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
 	"github.com/MrLYC/steer/operator/internal/controller"
 	"github.com/MrLYC/steer/operator/internal/web"
+	webhooksteerv1alpha1 "github.com/MrLYC/steer/operator/internal/webhook/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/cleanup"
+	"github.com/MrLYC/steer/operator/pkg/config"
+	"github.com/MrLYC/steer/operator/pkg/helm"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+	"github.com/MrLYC/steer/operator/pkg/logsink"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -60,10 +69,15 @@ func main() {
 	var enableHTTP2 bool
 	var webAddr string
 	var webStaticDir string
+	var webTrustedProxies string
+	var configFile string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.StringVar(&webAddr, "web", "", "If set, start the embedded test web server on the given address (e.g. :8082)")
 	flag.StringVar(&webStaticDir, "web-static-dir", "/static", "Static UI directory for the embedded web server")
+	flag.StringVar(&webTrustedProxies, "web-trusted-proxies", "",
+		"Comma separated CIDRs of reverse proxies whose X-Forwarded-User header the web server trusts")
+	flag.StringVar(&configFile, "config", "", "Path to the operator config file (default images, image and RBAC policy)")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -79,6 +93,21 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	operatorConfig, err := config.Load(configFile)
+	if err != nil {
+		setupLog.Error(err, "unable to load operator config")
+		os.Exit(1)
+	}
+	if image := os.Getenv("STEER_JOB_IMAGE"); image != "" && operatorConfig.DefaultImages.Test == "" {
+		setupLog.Info("STEER_JOB_IMAGE is deprecated, set defaultImages.test in the operator config instead")
+		operatorConfig.DefaultImages.Test = image
+	}
+	logSink, err := logsink.New(operatorConfig.LogSink)
+	if err != nil {
+		setupLog.Error(err, "unable to create log sink")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancelation and
@@ -101,6 +130,11 @@ func main() {
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		// Secrets, e.g. the hook outputs of runs, are read when needed
+		// rather than watched across the cluster.
+		Client: client.Options{
+			Cache: &client.CacheOptions{DisableFor: []client.Object{&corev1.Secret{}}},
+		},
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -127,9 +161,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create kubernetes clientset")
+		os.Exit(1)
+	}
+
 	if webAddr != "" {
 		setupLog.Info("starting embedded test web server", "addr", webAddr, "staticDir", webStaticDir)
-		if err := mgr.Add(web.NewServer(webAddr, webStaticDir, mgr.GetClient())); err != nil {
+		webServer := web.NewServer(webAddr, webStaticDir, mgr.GetClient(), clientset, logSink)
+		if webServer.TrustedProxies, err = web.ParseTrustedProxies(webTrustedProxies); err != nil {
+			setupLog.Error(err, "unable to parse --web-trusted-proxies")
+			os.Exit(1)
+		}
+		if err := mgr.Add(webServer); err != nil {
 			setupLog.Error(err, "unable to add web server")
 			os.Exit(1)
 		}
@@ -138,20 +183,51 @@ func main() {
 		setupLog.Info("web server disabled (set --web to enable)")
 	}
 
+	if operatorConfig.Cleanup.Namespace == "" {
+		operatorConfig.Cleanup.Namespace = os.Getenv("POD_NAMESPACE")
+	}
+	cleanupRunner := cleanup.New(mgr.GetClient(), operatorConfig.Cleanup)
+	// The Helm client installs ephemeral environments; it is nil unless
+	// helm.enabled is set in the operator config, and HelmTestJobs with
+	// spec.environment ephemeral are rejected then. Values are read
+	// uncached so the operator doesn't watch every Secret.
+	helmClient, err := helm.New(operatorConfig.Helm, mgr.GetConfig(), mgr.GetAPIReader())
+	if err != nil {
+		setupLog.Error(err, "unable to create helm client")
+		os.Exit(1)
+	}
+
+	// HelmReleases are not installed by the operator: their reconciler
+	// would upgrade the release on every status update.
 	if err = (&controller.HelmReleaseReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		Cleanup: cleanupRunner,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "HelmRelease")
 		os.Exit(1)
 	}
+	hookExecutor := hooks.NewJobExecutor(mgr.GetClient(), mgr.GetScheme(), clientset)
+	hookExecutor.LogSink = logSink
 	if err = (&controller.HelmTestJobReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Clientset: clientset,
+		Hooks:     hookExecutor,
+		Config:    operatorConfig,
+		LogSink:   logSink,
+		Cleanup:   cleanupRunner,
+		Helm:      helmClient,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "HelmTestJob")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = webhooksteerv1alpha1.SetupHelmTestJobWebhookWithManager(mgr, operatorConfig, helmClient != nil); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "HelmTestJob")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -18,27 +18,44 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/cleanup"
 	"github.com/MrLYC/steer/operator/pkg/helm"
 )
 
+// helmReleaseFinalizer keeps a deleted HelmRelease until it is uninstalled
+// and cleaned up.
+const helmReleaseFinalizer = "steer.io/cleanup"
+
+// cleanupPollInterval is how often an unfinished cleanup is checked.
+const cleanupPollInterval = 5 * time.Second
+
 // HelmReleaseReconciler reconciles a HelmRelease object
 type HelmReleaseReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	Helm   helm.Client
+	// Cleanup deletes the target namespace and images of a deleted
+	// HelmRelease as spec.cleanup asks for. No cleanup is done when nil.
+	Cleanup cleanup.Runner
 }
 
 //+kubebuilder:rbac:groups=steer.steer.io,resources=helmreleases,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=steer.steer.io,resources=helmreleases/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=steer.steer.io,resources=helmreleases/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=create;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -57,6 +74,15 @@ func (r *HelmReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	if !hr.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &hr)
+	}
+	if r.needsFinalizer(&hr) && controllerutil.AddFinalizer(&hr, helmReleaseFinalizer) {
+		if err := r.Update(ctx, &hr); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	if r.Helm == nil {
 		logger.Info("helm client not configured")
 		return ctrl.Result{}, nil
@@ -68,6 +94,7 @@ func (r *HelmReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		Namespace:       hr.Spec.Deployment.Namespace,
 		Chart:           hr.Spec.Chart,
 		Values:          hr.Spec.Values,
+		ValuesNamespace: hr.Namespace,
 		CreateNamespace: hr.Spec.Deployment.CreateNamespace,
 		Timeout:         hr.Spec.Deployment.Timeout,
 	}
@@ -96,6 +123,92 @@ func (r *HelmReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	return ctrl.Result{}, nil
 }
 
+// needsFinalizer reports whether deleting the HelmRelease requires work:
+// uninstalling it or cleaning up after it.
+func (r *HelmReleaseReconciler) needsFinalizer(hr *steerv1alpha1.HelmRelease) bool {
+	opts := cleanupOptionsOf(hr.Spec.Cleanup)
+	return r.Helm != nil || (r.Cleanup != nil && (opts.DeleteNamespace || opts.DeleteImages))
+}
+
+// finalize uninstalls a deleted HelmRelease, cleans up its target namespace
+// and then removes the finalizer.
+func (r *HelmReleaseReconciler) finalize(ctx context.Context, hr *steerv1alpha1.HelmRelease) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	if !controllerutil.ContainsFinalizer(hr, helmReleaseFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	namespace := releaseNamespace(hr)
+	opts := cleanupOptionsOf(hr.Spec.Cleanup)
+	if hr.Status.Phase != steerv1alpha1.HelmReleasePhaseUninstalling {
+		// The images can only be told from the pods before they are gone.
+		if r.Cleanup != nil && opts.DeleteImages {
+			images, err := r.Cleanup.NamespaceImages(ctx, namespace)
+			if err != nil {
+				return ctrl.Result{}, fmt.Errorf("record images of namespace %s: %w", namespace, err)
+			}
+			hr.Status.Images = images
+		}
+		if r.Helm != nil {
+			err := r.Helm.Uninstall(ctx, helm.UninstallRequest{
+				ReleaseName: hr.Name,
+				Namespace:   namespace,
+				Timeout:     hr.Spec.Deployment.Timeout,
+			})
+			if err != nil {
+				hr.Status.Message = fmt.Sprintf("uninstall: %v", err)
+				_ = r.Status().Update(ctx, hr)
+				return ctrl.Result{}, err
+			}
+		}
+		hr.Status.Phase = steerv1alpha1.HelmReleasePhaseUninstalling
+		hr.Status.Message = ""
+		if err := r.Status().Update(ctx, hr); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	opts.Images = hr.Status.Images
+	if opts.DeleteNamespace && namespace == hr.Namespace {
+		// The namespace would wait for this finalizer and the other way round.
+		logger.Info("not deleting the namespace of the HelmRelease itself", "namespace", namespace)
+		opts.DeleteNamespace = false
+	}
+	if r.Cleanup != nil && (opts.DeleteNamespace || opts.DeleteImages) {
+		err := r.Cleanup.CleanupNamespace(ctx, namespace, opts)
+		if err != nil {
+			hr.Status.Message = err.Error()
+			if updateErr := r.Status().Update(ctx, hr); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			if errors.Is(err, cleanup.ErrInProgress) {
+				return ctrl.Result{RequeueAfter: cleanupPollInterval}, nil
+			}
+			return ctrl.Result{}, err
+		}
+	}
+
+	hr.Status.Phase = steerv1alpha1.HelmReleasePhaseUninstalled
+	hr.Status.Message = ""
+	if err := r.Status().Update(ctx, hr); err != nil {
+		return ctrl.Result{}, err
+	}
+	controllerutil.RemoveFinalizer(hr, helmReleaseFinalizer)
+	return ctrl.Result{}, client.IgnoreNotFound(r.Update(ctx, hr))
+}
+
+// releaseNamespace returns the namespace a HelmRelease deploys into.
+func releaseNamespace(hr *steerv1alpha1.HelmRelease) string {
+	if hr.Spec.Deployment.Namespace == "" {
+		return hr.Namespace
+	}
+	return hr.Spec.Deployment.Namespace
+}
+
+func cleanupOptionsOf(spec steerv1alpha1.CleanupSpec) cleanup.Options {
+	return cleanup.Options{DeleteNamespace: spec.DeleteNamespace, DeleteImages: spec.DeleteImages}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *HelmReleaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -18,6 +18,7 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -28,6 +29,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/cleanup"
 	"github.com/MrLYC/steer/operator/pkg/helm"
 )
 
@@ -104,5 +106,77 @@ var _ = Describe("HelmRelease Controller", func() {
 			Expect(updated.Status.HelmRelease).NotTo(BeNil())
 			Expect(updated.Status.HelmRelease.Name).To(Equal(resourceName))
 		})
+
+		It("should uninstall and clean up a deleted resource", func() {
+			deletedName := types.NamespacedName{Name: "deleted-resource", Namespace: "default"}
+			resource := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: deletedName.Name, Namespace: deletedName.Namespace},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "release-target"},
+					Cleanup:    steerv1alpha1.CleanupSpec{DeleteNamespace: true, DeleteImages: true},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+
+			var uninstalled []string
+			var cleaned []string
+			var cleanedOpts cleanup.Options
+			terminating := true
+			controllerReconciler := &HelmReleaseReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Helm: &helm.FakeClient{
+					UninstallFunc: func(ctx context.Context, req helm.UninstallRequest) error {
+						uninstalled = append(uninstalled, req.Namespace+"/"+req.ReleaseName)
+						return nil
+					},
+				},
+				Cleanup: &cleanup.FakeRunner{
+					// The pods go away with the release.
+					NamespaceImagesFunc: func(ctx context.Context, namespace string) ([]string, error) {
+						if len(uninstalled) > 0 {
+							return nil, nil
+						}
+						return []string{"example/app:1.0"}, nil
+					},
+					CleanupNamespaceFunc: func(ctx context.Context, namespace string, opts cleanup.Options) error {
+						cleaned, cleanedOpts = append(cleaned, namespace), opts
+						if terminating {
+							return fmt.Errorf("%w: namespace %s is terminating", cleanup.ErrInProgress, namespace)
+						}
+						return nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deletedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deletedName, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(ContainElement(helmReleaseFinalizer))
+
+			By("Deleting the resource")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			res, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deletedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.RequeueAfter).NotTo(BeZero())
+			Expect(uninstalled).To(Equal([]string{"release-target/deleted-resource"}))
+			Expect(cleaned).To(Equal([]string{"release-target"}))
+			Expect(cleanedOpts).To(Equal(cleanup.Options{DeleteNamespace: true, DeleteImages: true, Images: []string{"example/app:1.0"}}))
+			Expect(k8sClient.Get(ctx, deletedName, resource)).To(Succeed())
+			Expect(resource.Status.Phase).To(Equal(steerv1alpha1.HelmReleasePhaseUninstalling))
+			Expect(resource.Status.Message).To(ContainSubstring("terminating"))
+
+			By("Finishing the cleanup")
+			terminating = false
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deletedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uninstalled).To(HaveLen(1))
+			Expect(cleaned).To(HaveLen(2))
+			err = k8sClient.Get(ctx, deletedName, resource)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
 	})
 })
@@ -0,0 +1,218 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/cleanup"
+)
+
+// cleanupPolicy is how the environment of the runs of a HelmTestJob is
+// cleaned up.
+type cleanupPolicy struct {
+	cleanup.Options
+	When  steerv1alpha1.CleanupWhen
+	Delay time.Duration
+}
+
+// effectiveCleanup merges spec.cleanup of a HelmTestJob over spec.cleanup of
+// its HelmRelease for ephemeral environments, whose namespace is deleted
+// unless the HelmTestJob says otherwise. The shared environment belongs to
+// the HelmRelease: its spec.cleanup only applies when the release is
+// deleted, so runs only clean it up when the HelmTestJob asks for it.
+func effectiveCleanup(release steerv1alpha1.CleanupSpec, override *steerv1alpha1.HelmTestJobCleanupSpec, ephemeral bool) cleanupPolicy {
+	policy := cleanupPolicy{When: steerv1alpha1.CleanupWhenOnSuccess}
+	if ephemeral {
+		policy.Options = cleanupOptionsOf(release)
+		policy.DeleteNamespace = true
+	}
+	if override == nil {
+		return policy
+	}
+	if override.DeleteNamespace != nil {
+		policy.DeleteNamespace = *override.DeleteNamespace
+	}
+	if override.DeleteImages != nil {
+		policy.DeleteImages = *override.DeleteImages
+	}
+	if override.When != "" {
+		policy.When = override.When
+	}
+	if override.Delay != nil {
+		policy.Delay = override.Delay.Duration
+	}
+	return policy
+}
+
+// appliesTo reports whether the policy cleans up after a run that finished
+// in phase.
+func (p cleanupPolicy) appliesTo(phase steerv1alpha1.HelmTestJobPhase) bool {
+	switch p.When {
+	case steerv1alpha1.CleanupWhenAlways:
+		return true
+	case steerv1alpha1.CleanupWhenOnFailure:
+		return phase != steerv1alpha1.HelmTestJobPhaseSucceeded
+	default:
+		return phase == steerv1alpha1.HelmTestJobPhaseSucceeded
+	}
+}
+
+// reconcileRunCleanup starts or advances the cleanup of a finished run. It
+// returns when the cleanup should be checked again, or zero when it is done.
+func (r *HelmTestJobReconciler) reconcileRunCleanup(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun, now time.Time) time.Duration {
+	if r.Cleanup == nil {
+		return 0
+	}
+	status := run.Status.Cleanup
+	if status == nil {
+		if status = r.newRunCleanup(ctx, job, run, now); status == nil {
+			return 0
+		}
+		run.Status.Cleanup = status
+	}
+	if status.Phase == steerv1alpha1.HelmTestJobPhasePending {
+		if status.ScheduledAt != nil && now.Before(status.ScheduledAt.Time) {
+			return status.ScheduledAt.Sub(now)
+		}
+		startedAt := metav1.NewTime(now)
+		status.Phase = steerv1alpha1.HelmTestJobPhaseRunning
+		status.StartedAt = &startedAt
+		// The pods are gone once the environment is uninstalled.
+		if status.DeleteImages {
+			images, err := r.Cleanup.NamespaceImages(ctx, status.Namespace)
+			if err != nil {
+				finishCleanup(status, steerv1alpha1.HelmTestJobPhaseFailed, fmt.Sprintf("record images: %v", err), now)
+				return 0
+			}
+			status.Images = images
+		}
+	}
+	if status.Phase.IsFinished() {
+		return 0
+	}
+
+	if env := run.Status.Environment; env != nil {
+		if r.installs.running(client.ObjectKeyFromObject(run)) {
+			// Uninstalling now would race the install.
+			status.Message = "waiting for the install of the environment to finish"
+			return cleanupPollInterval
+		}
+		if status.DeleteNamespace {
+			if err := r.checkEnvironmentNamespace(ctx, job, run); err != nil {
+				finishCleanup(status, steerv1alpha1.HelmTestJobPhaseFailed, err.Error(), now)
+				return 0
+			}
+		}
+		if err := r.uninstallEnvironment(ctx, env, now); err != nil {
+			log.FromContext(ctx).Error(err, "failed to uninstall ephemeral environment", "run", run.Name, "namespace", env.Namespace)
+			finishCleanup(status, steerv1alpha1.HelmTestJobPhaseFailed, fmt.Sprintf("uninstall: %v", err), now)
+			return 0
+		}
+	}
+	err := r.Cleanup.CleanupNamespace(ctx, status.Namespace, cleanup.Options{
+		DeleteNamespace: status.DeleteNamespace,
+		DeleteImages:    status.DeleteImages,
+		Images:          status.Images,
+	})
+	switch {
+	case err == nil:
+		finishCleanup(status, steerv1alpha1.HelmTestJobPhaseSucceeded, "", now)
+		return 0
+	case errors.Is(err, cleanup.ErrInProgress):
+		status.Message = err.Error()
+		return cleanupPollInterval
+	default:
+		log.FromContext(ctx).Error(err, "failed to clean up after run", "run", run.Name, "namespace", status.Namespace)
+		finishCleanup(status, steerv1alpha1.HelmTestJobPhaseFailed, err.Error(), now)
+		return 0
+	}
+}
+
+// newRunCleanup applies the cleanup policy to a run that just finished. It
+// returns nil when the policy doesn't clean up anything.
+func (r *HelmTestJobReconciler) newRunCleanup(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun, now time.Time) *steerv1alpha1.HelmTestRunCleanupStatus {
+	release, err := r.releaseOf(ctx, job)
+	var releaseCleanup steerv1alpha1.CleanupSpec
+	if err == nil {
+		releaseCleanup = release.Spec.Cleanup
+	}
+	env := run.Status.Environment
+	policy := effectiveCleanup(releaseCleanup, job.Spec.Cleanup, env != nil)
+	// An ephemeral environment is always uninstalled.
+	if !policy.DeleteNamespace && !policy.DeleteImages && env == nil {
+		return nil
+	}
+
+	status := &steerv1alpha1.HelmTestRunCleanupStatus{
+		DeleteNamespace: policy.DeleteNamespace,
+		DeleteImages:    policy.DeleteImages,
+		Phase:           steerv1alpha1.HelmTestJobPhasePending,
+	}
+	switch {
+	case env != nil:
+		status.Namespace = env.Namespace
+	case err != nil:
+		finishCleanup(status, steerv1alpha1.HelmTestJobPhaseFailed, err.Error(), now)
+		return status
+	default:
+		status.Namespace = releaseNamespace(release)
+	}
+	// The namespace of an ephemeral environment belongs to the run.
+	var targetErr error
+	if env == nil {
+		targetErr = r.Config.CheckTargetNamespace(job.Namespace, status.Namespace)
+	}
+	switch {
+	case !policy.appliesTo(run.Status.Phase):
+		finishCleanup(status, steerv1alpha1.HelmTestJobPhaseSkipped, fmt.Sprintf("kept after the %s run, spec.cleanup.when is %s", strings.ToLower(string(run.Status.Phase)), policy.When), now)
+	case policy.DeleteNamespace && status.Namespace == job.Namespace:
+		// Deleting it would delete the HelmTestJob and its runs as well.
+		finishCleanup(status, steerv1alpha1.HelmTestJobPhaseFailed, fmt.Sprintf("refusing to delete namespace %s: it holds the HelmTestJob", status.Namespace), now)
+	case targetErr != nil:
+		finishCleanup(status, steerv1alpha1.HelmTestJobPhaseFailed, fmt.Sprintf("refusing to clean up: %v", targetErr), now)
+	case policy.Delay > 0:
+		finishedAt := now
+		if run.Status.CompletionTime != nil {
+			finishedAt = run.Status.CompletionTime.Time
+		}
+		scheduledAt := metav1.NewTime(finishedAt.Add(policy.Delay))
+		status.ScheduledAt = &scheduledAt
+	}
+	return status
+}
+
+func finishCleanup(status *steerv1alpha1.HelmTestRunCleanupStatus, phase steerv1alpha1.HelmTestJobPhase, message string, now time.Time) {
+	completed := metav1.NewTime(now)
+	status.Phase = phase
+	status.Message = message
+	status.CompletedAt = &completed
+}
+
+// cleanupPending reports whether a run is still being cleaned up.
+func cleanupPending(run *steerv1alpha1.HelmTestRun) bool {
+	return run.Status.Cleanup != nil && !run.Status.Cleanup.Phase.IsFinished()
+}
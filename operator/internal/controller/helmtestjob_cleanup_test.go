@@ -0,0 +1,250 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/cleanup"
+	"github.com/MrLYC/steer/operator/pkg/config"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+var _ = Describe("HelmTestJob Controller", func() {
+	Context("When cleaning up after a run", func() {
+		withHelmTestJob()
+
+		It("should clean up the release namespace after a run as spec.cleanup asks", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "cleanup-target"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			deleteNamespace := true
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Cleanup = &steerv1alpha1.HelmTestJobCleanupSpec{DeleteNamespace: &deleteNamespace}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			var cleaned []string
+			var cleanedOpts cleanup.Options
+			terminating := true
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: &config.Config{AllowedTargetNamespaces: []string{"cleanup-target"}},
+				Hooks:  &hooks.FakeExecutor{},
+				Cleanup: &cleanup.FakeRunner{
+					CleanupNamespaceFunc: func(ctx context.Context, namespace string, opts cleanup.Options) error {
+						cleaned, cleanedOpts = append(cleaned, namespace), opts
+						if terminating {
+							return fmt.Errorf("%w: namespace %s is terminating", cleanup.ErrInProgress, namespace)
+						}
+						return nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cleaned).To(BeEmpty())
+
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, testJob)).To(Succeed())
+			testJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, testJob)).To(Succeed())
+
+			By("Waiting for the namespace to terminate")
+			res, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.RequeueAfter).NotTo(BeZero())
+			Expect(cleaned).To(Equal([]string{"cleanup-target"}))
+			Expect(cleanedOpts).To(Equal(cleanup.Options{DeleteNamespace: true}))
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(run.Status.Cleanup).NotTo(BeNil())
+			Expect(run.Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			Expect(run.Status.Cleanup.Message).To(ContainSubstring("terminating"))
+
+			By("Finishing the cleanup")
+			terminating = false
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cleaned).To(HaveLen(2))
+			run = latestRun()
+			Expect(run.Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(run.Status.Cleanup.CompletedAt).NotTo(BeNil())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cleaned).To(HaveLen(2))
+		})
+
+		It("should keep the environment of a passed run and clean up a failed one after the delay", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "cleanup-target"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			yes := true
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Cleanup = &steerv1alpha1.HelmTestJobCleanupSpec{
+				DeleteNamespace: &yes,
+				DeleteImages:    &yes,
+				When:            steerv1alpha1.CleanupWhenOnFailure,
+				Delay:           &metav1.Duration{Duration: time.Hour},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			var cleanedOpts []cleanup.Options
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: &config.Config{AllowedTargetNamespaces: []string{"cleanup-target"}},
+				Hooks:  &hooks.FakeExecutor{},
+				Cleanup: &cleanup.FakeRunner{
+					NamespaceImagesFunc: func(ctx context.Context, namespace string) ([]string, error) {
+						return []string{"example/app:1.0"}, nil
+					},
+					CleanupNamespaceFunc: func(ctx context.Context, namespace string, opts cleanup.Options) error {
+						cleanedOpts = append(cleanedOpts, opts)
+						return nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Failing the run")
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, testJob)).To(Succeed())
+			testJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "exit 1"}}
+			Expect(k8sClient.Status().Update(ctx, testJob)).To(Succeed())
+			res, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.RequeueAfter).To(BeNumerically(">", 59*time.Minute))
+			Expect(cleanedOpts).To(BeEmpty())
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Cleanup).NotTo(BeNil())
+			Expect(run.Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhasePending))
+			Expect(run.Status.Cleanup.Namespace).To(Equal("cleanup-target"))
+			Expect(run.Status.Cleanup.ScheduledAt).NotTo(BeNil())
+
+			By("Cleaning up once the delay has passed")
+			past := metav1.NewTime(time.Now().Add(-time.Minute))
+			run.Status.Cleanup.ScheduledAt = &past
+			Expect(k8sClient.Status().Update(ctx, run)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cleanedOpts).To(Equal([]cleanup.Options{{DeleteNamespace: true, DeleteImages: true, Images: []string{"example/app:1.0"}}}))
+			Expect(latestRun().Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(latestRun().Status.Cleanup.Images).To(Equal([]string{"example/app:1.0"}))
+
+			By("Keeping the environment of a passed run")
+			passed := run.DeepCopy()
+			passed.Status.Phase = steerv1alpha1.HelmTestJobPhaseSucceeded
+			passed.Status.Cleanup = nil
+			Expect(controllerReconciler.reconcileRunCleanup(ctx, resource, passed, time.Now())).To(BeZero())
+			Expect(passed.Status.Cleanup).NotTo(BeNil())
+			Expect(passed.Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSkipped))
+			Expect(passed.Status.Cleanup.Message).To(ContainSubstring("onFailure"))
+			Expect(cleanedOpts).To(HaveLen(1))
+		})
+
+		It("should only clean up the shared environment when the HelmTestJob asks for it in an allowed namespace", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "cleanup-target"},
+					Cleanup:    steerv1alpha1.CleanupSpec{DeleteNamespace: true, DeleteImages: true},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			var cleaned []string
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+				Cleanup: &cleanup.FakeRunner{
+					CleanupNamespaceFunc: func(ctx context.Context, namespace string, opts cleanup.Options) error {
+						cleaned = append(cleaned, namespace)
+						return nil
+					},
+				},
+			}
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			passed := &steerv1alpha1.HelmTestRun{Status: steerv1alpha1.HelmTestRunStatus{Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}}
+
+			By("Ignoring spec.cleanup of the HelmRelease")
+			Expect(controllerReconciler.reconcileRunCleanup(ctx, resource, passed, time.Now())).To(BeZero())
+			Expect(passed.Status.Cleanup).To(BeNil())
+
+			By("Refusing a namespace the operator config doesn't allow")
+			yes := true
+			resource.Spec.Cleanup = &steerv1alpha1.HelmTestJobCleanupSpec{DeleteNamespace: &yes}
+			Expect(controllerReconciler.reconcileRunCleanup(ctx, resource, passed, time.Now())).To(BeZero())
+			Expect(passed.Status.Cleanup).NotTo(BeNil())
+			Expect(passed.Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(passed.Status.Cleanup.Message).To(ContainSubstring("allowedTargetNamespaces"))
+			Expect(cleaned).To(BeEmpty())
+
+			By("Cleaning up once it is allowed")
+			passed.Status.Cleanup = nil
+			controllerReconciler.Config = &config.Config{AllowedTargetNamespaces: []string{"cleanup-target"}}
+			Expect(controllerReconciler.reconcileRunCleanup(ctx, resource, passed, time.Now())).To(BeZero())
+			Expect(passed.Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(passed.Status.Cleanup.DeleteImages).To(BeFalse())
+			Expect(cleaned).To(Equal([]string{"cleanup-target"}))
+		})
+	})
+})
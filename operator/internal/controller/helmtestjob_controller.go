@@ -19,7 +19,7 @@ package controller
 import (
 	"context"
 	"fmt"
-	"os"
+	"io"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -29,25 +29,64 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/cleanup"
+	"github.com/MrLYC/steer/operator/pkg/config"
+	"github.com/MrLYC/steer/operator/pkg/helm"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+	"github.com/MrLYC/steer/operator/pkg/logsink"
+	"github.com/MrLYC/steer/operator/pkg/results"
 )
 
 // HelmTestJobReconciler reconciles a HelmTestJob object
 type HelmTestJobReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// Hooks runs pre/post test hooks. Defaults to a hooks.JobExecutor.
+	Hooks hooks.Executor
+	// Config provides default images and the image and RBAC policy. May be
+	// nil.
+	Config *config.Config
+	// Clientset reads the logs of test pods. Test logs are not collected
+	// when nil.
+	Clientset kubernetes.Interface
+	// LogSink stores full test logs; status only keeps their tail. May be nil.
+	LogSink logsink.Sink
+	// Cleanup cleans up the release's target namespace or the ephemeral
+	// environment after a run as spec.cleanup asks for. No cleanup is done
+	// when nil; ephemeral environments are kept as well.
+	Cleanup cleanup.Runner
+	// Helm installs and uninstalls the ephemeral environments of runs. Runs
+	// of jobs with spec.environment ephemeral fail when nil, which is the
+	// case for the operator binary until a Helm SDK client is wired up.
+	Helm helm.Client
+
+	installs environmentInstalls
 }
 
 //+kubebuilder:rbac:groups=steer.steer.io,resources=helmtestjobs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=steer.steer.io,resources=helmtestjobs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=steer.steer.io,resources=helmtestjobs/finalizers,verbs=update
+//+kubebuilder:rbac:groups=steer.steer.io,resources=helmtestruns,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=steer.steer.io,resources=helmtestruns/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;delete;bind;escalate
+//+kubebuilder:rbac:groups="",resources=services;endpoints;configmaps;persistentvolumeclaims,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets;replicasets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -67,233 +106,367 @@ func (r *HelmTestJobReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	now := time.Now()
-	nowMeta := metav1.Now()
+
+	if !job.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &job, now)
+	}
+	if needsFinalizer(&job) && !controllerutil.ContainsFinalizer(&job, helmTestJobFinalizer) {
+		controllerutil.AddFinalizer(&job, helmTestJobFinalizer)
+		if err := r.Update(ctx, &job); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	if job.Status.Phase == "" {
 		job.Status.Phase = steerv1alpha1.HelmTestJobPhasePending
 	}
 
-	res, next, err := computeNextScheduleTime(now, job.CreationTimestamp.Time, job.Spec.Schedule, job.Status.NextScheduleTime, job.Status.LastScheduleTime)
+	runs, err := r.listRuns(ctx, &job)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Cancel before scheduling, so runs started by this reconcile are kept.
+	if err := r.handleCancelRequest(ctx, &job, runs, now); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	res, started, note, err := r.scheduleRuns(ctx, &job, runs, now)
 	if err != nil {
-		logger.Error(err, "failed to compute next schedule time")
+		logger.Error(err, "failed to schedule run")
 		job.Status.Phase = steerv1alpha1.HelmTestJobPhaseFailed
 		job.Status.Message = err.Error()
 		_ = r.Status().Update(ctx, &job)
 		return ctrl.Result{}, err
 	}
-	// NextScheduleTime is cleared when a cron run is started.
-	if job.Status.NextScheduleTime == nil {
-		job.Status.NextScheduleTime = &metav1.Time{Time: next}
+	runs = appendRun(runs, started)
+
+	requested, requestNote, err := r.handleRunRequest(ctx, &job, runs, now)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
-	if job.Spec.Schedule.Type == steerv1alpha1.ScheduleTypeCron {
-		job.Status.NextScheduleTime = &metav1.Time{Time: next}
+	runs = appendRun(runs, requested)
+	if requestNote != "" {
+		note = requestNote
 	}
 
-	// Determine run key and if we should execute now.
-	shouldRun := false
-	runKey := "once"
+	image := r.Config.TestImage(&job)
 
-	switch job.Spec.Schedule.Type {
-	case steerv1alpha1.ScheduleTypeOnce:
-		// Run at (creationTimestamp + delay). Don't rerun after terminal.
-		if job.Status.Phase != steerv1alpha1.HelmTestJobPhaseSucceeded && job.Status.Phase != steerv1alpha1.HelmTestJobPhaseFailed {
-			if job.Status.NextScheduleTime != nil && !now.Before(job.Status.NextScheduleTime.Time) {
-				shouldRun = true
+	waiting := false
+	var cleanupAfter time.Duration
+	for _, run := range runs {
+		if !run.Status.Phase.IsFinished() {
+			if r.reconcileRun(ctx, &job, run, image, now) {
+				waiting = true
 			}
+			if run.Status.Phase.IsFinished() {
+				// Pruning retries the teardown if it fails here.
+				if err := r.teardownRunRBAC(ctx, &job, run); err != nil {
+					logger.Error(err, "failed to tear down run rbac", "run", run.Name)
+				}
+				r.storeRunReports(ctx, run)
+			}
+		} else if !cleanupPending(run) && !disrupted(run) {
+			continue
 		}
-	case steerv1alpha1.ScheduleTypeCron:
-		// While running, keep executing current run.
-		if job.Status.Phase == steerv1alpha1.HelmTestJobPhaseRunning {
-			shouldRun = true
-			if job.Status.LastScheduleTime != nil {
-				runKey = fmt.Sprintf("r%d", job.Status.LastScheduleTime.Time.Unix())
+		if run.Status.Phase.IsFinished() {
+			if err := r.restoreDisruptions(ctx, run, now); err != nil {
+				logger.Error(err, "failed to restore chaos disruptions", "run", run.Name)
+				waiting = true
+			}
+			if after := r.reconcileRunCleanup(ctx, &job, run, now); after > 0 && (cleanupAfter == 0 || after < cleanupAfter) {
+				cleanupAfter = after
 			}
-			break
 		}
+		if err := r.Status().Update(ctx, run); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
-		// Start a new run when due.
-		if job.Status.NextScheduleTime != nil && !now.Before(job.Status.NextScheduleTime.Time) {
-			due := job.Status.NextScheduleTime.DeepCopy()
-			job.Status.LastScheduleTime = due
-			runKey = fmt.Sprintf("r%d", due.Time.Unix())
-			job.Status.NextScheduleTime = nil
-			job.Status.Phase = steerv1alpha1.HelmTestJobPhasePending
-			job.Status.StartTime = nil
-			job.Status.CompletionTime = nil
-			job.Status.CurrentStage = ""
-			job.Status.CurrentIndex = 0
-			shouldRun = true
+	recordRunsFlakiness(&job.Status, runs)
+	summarizeLatestRun(&job.Status, runs)
+	if note != "" {
+		job.Status.Message = note
+	}
+	if err := r.pruneRuns(ctx, &job, runs); err != nil {
+		logger.Error(err, "failed to prune run history")
+	}
+
+	if err := r.Status().Update(ctx, &job); err != nil {
+		return ctrl.Result{}, err
+	}
+	if waiting && (res.RequeueAfter == 0 || res.RequeueAfter > 2*time.Second) {
+		res.RequeueAfter = 2 * time.Second
+	}
+	if cleanupAfter > 0 && (res.RequeueAfter == 0 || res.RequeueAfter > cleanupAfter) {
+		res.RequeueAfter = cleanupAfter
+	}
+	return res, nil
+}
+
+// scheduleRuns starts the run that is due, if any, and keeps
+// NextScheduleTime up to date. The returned note explains a due run that was
+// not started.
+func (r *HelmTestJobReconciler) scheduleRuns(ctx context.Context, job *steerv1alpha1.HelmTestJob, runs []*steerv1alpha1.HelmTestRun, now time.Time) (ctrl.Result, *steerv1alpha1.HelmTestRun, string, error) {
+	res, next, err := computeNextScheduleTime(now, job.CreationTimestamp.Time, job.Spec.Schedule, job.Status.NextScheduleTime, job.Status.LastScheduleTime)
+	if err != nil {
+		return ctrl.Result{}, nil, "", err
+	}
+	if job.Spec.Schedule.Type != steerv1alpha1.ScheduleTypeOnRelease {
+		job.Status.NextScheduleTime = &metav1.Time{Time: next}
+		if now.Before(next) {
+			return res, nil, "", nil
 		}
 	}
 
-	if !shouldRun {
-		job.Status.Message = ""
-		if err := r.Status().Update(ctx, &job); err != nil {
-			return ctrl.Result{}, err
+	switch job.Spec.Schedule.Type {
+	case steerv1alpha1.ScheduleTypeOnce:
+		// Run at (creationTimestamp + delay). Don't rerun after the run started.
+		res.RequeueAfter = 0
+		if job.Status.LastScheduleTime != nil {
+			return res, nil, "", nil
+		}
+		run, err := r.startRun(ctx, job, "once", steerv1alpha1.HelmTestRunTriggerSchedule, next)
+		if err != nil {
+			return ctrl.Result{}, nil, "", err
+		}
+		job.Status.LastScheduleTime = &metav1.Time{Time: next}
+		return res, run, "", nil
+
+	case steerv1alpha1.ScheduleTypeCron:
+		runKey := fmt.Sprintf("r%d", next.Unix())
+		var started *steerv1alpha1.HelmTestRun
+		note := ""
+		if job.Spec.StartingDeadlineSeconds != nil && now.Sub(next) > time.Duration(*job.Spec.StartingDeadlineSeconds)*time.Second {
+			note = fmt.Sprintf("missed starting deadline for run %s scheduled at %s", runKey, next.UTC().Format(time.RFC3339))
+			log.FromContext(ctx).Info(note)
+		} else {
+			started, note, err = r.admitRun(ctx, job, runs, runKey, steerv1alpha1.HelmTestRunTriggerSchedule, next, now)
+			if err != nil {
+				return ctrl.Result{}, nil, "", err
+			}
+			job.Status.LastScheduleTime = &metav1.Time{Time: next}
+		}
+
+		// Missed schedules collapse into the one handled above.
+		following, err := nextCronTime(job.Spec.Schedule, now)
+		if err != nil {
+			return ctrl.Result{}, nil, "", err
 		}
-		return res, nil
+		job.Status.NextScheduleTime = &metav1.Time{Time: following}
+		return ctrl.Result{RequeueAfter: following.Sub(now)}, started, note, nil
+
+	case steerv1alpha1.ScheduleTypeOnRelease:
+		// Runs are driven by HelmRelease updates rather than time.
+		job.Status.NextScheduleTime = nil
+		res.RequeueAfter = 0
+		revision := r.releaseRevision(ctx, job)
+		if revision == 0 || revision <= job.Status.LastTestedRevision {
+			return res, nil, "", nil
+		}
+		// A revision is not dropped while a run is active; it is tested once
+		// the active run finishes.
+		if active := activeRuns(runs); len(active) > 0 && concurrencyPolicyOf(job) == steerv1alpha1.ConcurrencyPolicyForbid {
+			return res, nil, fmt.Sprintf("revision %d waits for run %s to finish", revision, active[0].Spec.RunKey), nil
+		}
+		started, note, err := r.admitRun(ctx, job, runs, fmt.Sprintf("v%d", revision), steerv1alpha1.HelmTestRunTriggerRelease, now, now)
+		if err != nil {
+			return ctrl.Result{}, nil, "", err
+		}
+		job.Status.LastTestedRevision = revision
+		job.Status.LastScheduleTime = &metav1.Time{Time: now}
+		return res, started, note, nil
 	}
+	return res, nil, "", nil
+}
 
-	if job.Status.Phase != steerv1alpha1.HelmTestJobPhaseRunning {
-		job.Status.StartTime = &nowMeta
-		job.Status.Phase = steerv1alpha1.HelmTestJobPhaseRunning
-		job.Status.CompletionTime = nil
-		job.Status.Message = ""
+// handleRunRequest starts a manual run when the steer.io/run-requested-at
+// annotation carries a value that has not been handled yet. Under the Forbid
+// policy the value is only marked handled once the run could start.
+func (r *HelmTestJobReconciler) handleRunRequest(ctx context.Context, job *steerv1alpha1.HelmTestJob, runs []*steerv1alpha1.HelmTestRun, now time.Time) (*steerv1alpha1.HelmTestRun, string, error) {
+	value := job.Annotations[steerv1alpha1.AnnotationRunRequestedAt]
+	if value == "" || value == job.Status.LastRunRequest {
+		return nil, "", nil
+	}
+	requestedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		job.Status.LastRunRequest = value
+		return nil, fmt.Sprintf("invalid %s annotation %q: expected an RFC3339 time", steerv1alpha1.AnnotationRunRequestedAt, value), nil
 	}
 
-	// Initialize stage for new runs.
-	if job.Status.CurrentStage == "" {
-		job.Status.CurrentStage = steerv1alpha1.HelmTestJobStagePreTest
-		job.Status.CurrentIndex = 0
+	// Requests within the same second share a run key and start a single run.
+	runKey := fmt.Sprintf("m%d", requestedAt.Unix())
+	// A request is not dropped while a run is active; it stays pending and
+	// starts once the active run finishes.
+	if active := activeRuns(runs); len(active) > 0 && concurrencyPolicyOf(job) == steerv1alpha1.ConcurrencyPolicyForbid {
+		return nil, fmt.Sprintf("run %s waits for run %s to finish", runKey, active[0].Spec.RunKey), nil
+	}
+	run, note, err := r.admitRun(ctx, job, runs, runKey, steerv1alpha1.HelmTestRunTriggerManual, requestedAt, now)
+	if err != nil {
+		return nil, "", err
 	}
+	job.Status.LastRunRequest = value
+	return run, note, nil
+}
 
-	// Resolve image for all Jobs.
-	image := job.Spec.Test.Image
-	if image == "" {
-		image = os.Getenv("STEER_JOB_IMAGE")
+// handleCancelRequest cancels the active runs when the
+// steer.io/cancel-requested-at annotation carries a new value.
+func (r *HelmTestJobReconciler) handleCancelRequest(ctx context.Context, job *steerv1alpha1.HelmTestJob, runs []*steerv1alpha1.HelmTestRun, now time.Time) error {
+	value := job.Annotations[steerv1alpha1.AnnotationCancelRequestedAt]
+	if value == "" || value == job.Status.LastCancelRequest {
+		return nil
 	}
-	if image == "" {
-		err := fmt.Errorf("missing test image: set spec.test.image or env STEER_JOB_IMAGE")
-		job.Status.Phase = steerv1alpha1.HelmTestJobPhaseFailed
-		job.Status.Message = err.Error()
-		job.Status.CompletionTime = &nowMeta
-		_ = r.Status().Update(ctx, &job)
-		return ctrl.Result{}, err
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		at = now
+	}
+	by := job.Annotations[steerv1alpha1.AnnotationCancelRequestedBy]
+	if by == "" {
+		by = "unknown"
 	}
+	for _, run := range activeRuns(runs) {
+		if err := r.cancelRun(ctx, job, run, by, at); err != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("cancelling run", "run", run.Name, "by", by)
+	}
+	job.Status.LastCancelRequest = value
+	return nil
+}
 
-	// State machine: execute one stage/hook at a time.
-	// We allow a few fast transitions (e.g., no hooks) in a single reconcile.
-	for step := 0; step < 4; step++ {
-		switch job.Status.CurrentStage {
-		case steerv1alpha1.HelmTestJobStagePreTest:
-			if int(job.Status.CurrentIndex) >= len(job.Spec.Hooks.PreTest) {
-				job.Status.CurrentStage = steerv1alpha1.HelmTestJobStageTest
-				job.Status.CurrentIndex = 0
+// runFailure describes the first failure of a run, or returns "" when
+// nothing has failed so far. Hooks with continueOnError don't count.
+func runFailure(spec steerv1alpha1.HooksSpec, run *steerv1alpha1.HelmTestRunStatus) string {
+	hookFailure := func(stage string, specHooks []steerv1alpha1.Hook, results []steerv1alpha1.HookResult) string {
+		for i, hr := range results {
+			if hr.Phase != steerv1alpha1.HelmTestJobPhaseFailed {
 				continue
 			}
-			h := job.Spec.Hooks.PreTest[job.Status.CurrentIndex]
-			name := jobNameForHook(job.Name, runKey, "pre", int(job.Status.CurrentIndex))
-			phase, msg, err := r.ensureHookJob(ctx, &job, name, image, h)
-			if err != nil {
-				job.Status.Phase = steerv1alpha1.HelmTestJobPhaseFailed
-				job.Status.Message = err.Error()
-				job.Status.CompletionTime = &nowMeta
-				_ = r.Status().Update(ctx, &job)
-				return ctrl.Result{}, err
-			}
-			if phase == steerv1alpha1.HelmTestJobPhaseSucceeded {
-				job.Status.CurrentIndex++
+			if i < len(specHooks) && specHooks[i].ContinueOnError {
 				continue
 			}
-			if phase == steerv1alpha1.HelmTestJobPhaseFailed {
-				job.Status.Phase = steerv1alpha1.HelmTestJobPhaseFailed
-				job.Status.Message = msg
-				job.Status.CompletionTime = &nowMeta
-				_ = r.Status().Update(ctx, &job)
-				return ctrl.Result{}, nil
-			}
-			job.Status.Message = msg
-			_ = r.Status().Update(ctx, &job)
-			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
+			return fmt.Sprintf("%s hook %q failed: %s", stage, hr.Name, hr.Message)
+		}
+		return ""
+	}
 
-		case steerv1alpha1.HelmTestJobStageTest:
-			name := jobNameForTest(job.Name, runKey)
-			phase, msg, err := r.ensureTestJob(ctx, &job, name, image)
-			if err != nil {
-				job.Status.Phase = steerv1alpha1.HelmTestJobPhaseFailed
-				job.Status.Message = err.Error()
-				job.Status.CompletionTime = &nowMeta
-				_ = r.Status().Update(ctx, &job)
-				return ctrl.Result{}, err
-			}
-			if phase == steerv1alpha1.HelmTestJobPhaseSucceeded {
-				job.Status.CurrentStage = steerv1alpha1.HelmTestJobStagePostTest
-				job.Status.CurrentIndex = 0
-				continue
-			}
-			if phase == steerv1alpha1.HelmTestJobPhaseFailed {
-				job.Status.Phase = steerv1alpha1.HelmTestJobPhaseFailed
-				job.Status.Message = msg
-				job.Status.CompletionTime = &nowMeta
-				_ = r.Status().Update(ctx, &job)
-				return ctrl.Result{}, nil
-			}
-			job.Status.Message = msg
-			_ = r.Status().Update(ctx, &job)
-			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
-
-		case steerv1alpha1.HelmTestJobStagePostTest:
-			if int(job.Status.CurrentIndex) >= len(job.Spec.Hooks.PostTest) {
-				job.Status.Phase = steerv1alpha1.HelmTestJobPhaseSucceeded
-				job.Status.CompletionTime = &nowMeta
-				job.Status.Message = ""
-				if err := r.Status().Update(ctx, &job); err != nil {
-					return ctrl.Result{}, err
-				}
-				return res, nil
-			}
-			h := job.Spec.Hooks.PostTest[job.Status.CurrentIndex]
-			name := jobNameForHook(job.Name, runKey, "post", int(job.Status.CurrentIndex))
-			phase, msg, err := r.ensureHookJob(ctx, &job, name, image, h)
-			if err != nil {
-				job.Status.Phase = steerv1alpha1.HelmTestJobPhaseFailed
-				job.Status.Message = err.Error()
-				job.Status.CompletionTime = &nowMeta
-				_ = r.Status().Update(ctx, &job)
-				return ctrl.Result{}, err
-			}
-			if phase == steerv1alpha1.HelmTestJobPhaseSucceeded {
-				job.Status.CurrentIndex++
-				continue
-			}
-			if phase == steerv1alpha1.HelmTestJobPhaseFailed {
-				job.Status.Phase = steerv1alpha1.HelmTestJobPhaseFailed
-				job.Status.Message = msg
-				job.Status.CompletionTime = &nowMeta
-				_ = r.Status().Update(ctx, &job)
-				return ctrl.Result{}, nil
+	var results steerv1alpha1.HookResults
+	if run.HookResults != nil {
+		results = *run.HookResults
+	}
+	if msg := hookFailure("preTest", spec.PreTest, results.PreTest); msg != "" {
+		return msg
+	}
+	for _, tr := range run.TestResults {
+		if tr.Phase == steerv1alpha1.HelmTestJobPhaseFailed {
+			if tr.Message != "" {
+				return fmt.Sprintf("test %q failed: %s", tr.Name, tr.Message)
 			}
-			job.Status.Message = msg
-			_ = r.Status().Update(ctx, &job)
-			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
-
-		default:
-			job.Status.CurrentStage = steerv1alpha1.HelmTestJobStagePreTest
-			job.Status.CurrentIndex = 0
-			continue
+			return fmt.Sprintf("test %q failed", tr.Name)
 		}
 	}
+	return hookFailure("postTest", spec.PostTest, results.PostTest)
+}
 
-	// If we reached here, we made progress but didn't create a blocking Job.
-	if err := r.Status().Update(ctx, &job); err != nil {
-		return ctrl.Result{}, err
+func runPolicyOf(h steerv1alpha1.Hook) steerv1alpha1.HookRunPolicy {
+	if h.RunPolicy == "" {
+		return steerv1alpha1.HookRunPolicyOnSuccess
+	}
+	return h.RunPolicy
+}
+
+// shouldRunHook applies the hook's runPolicy to the outcome of the run so far.
+func shouldRunHook(h steerv1alpha1.Hook, failed bool) bool {
+	switch runPolicyOf(h) {
+	case steerv1alpha1.HookRunPolicyAlways:
+		return true
+	case steerv1alpha1.HookRunPolicyOnFailure:
+		return failed
+	default:
+		return !failed
 	}
-	return ctrl.Result{RequeueAfter: 0}, nil
 }
 
-func jobNameForHook(parentName, runKey, stage string, idx int) string {
-	base := fmt.Sprintf("%s-%s-%s-%d", parentName, runKey, stage, idx)
-	if len(validation.IsDNS1123Label(base)) == 0 && len(base) <= 63 {
-		return base
+// newHookResults returns a Pending result for every hook of a new run.
+func newHookResults(spec steerv1alpha1.HooksSpec) *steerv1alpha1.HookResults {
+	results := &steerv1alpha1.HookResults{}
+	for _, h := range spec.PreTest {
+		results.PreTest = append(results.PreTest, steerv1alpha1.HookResult{Name: h.Name, Phase: steerv1alpha1.HelmTestJobPhasePending})
 	}
-	// Fall back to truncation.
-	if len(base) > 63 {
-		base = base[:63]
-		base = trimTrailingHyphen(base)
+	for _, h := range spec.PostTest {
+		results.PostTest = append(results.PostTest, steerv1alpha1.HookResult{Name: h.Name, Phase: steerv1alpha1.HelmTestJobPhasePending})
 	}
-	return base
+	return results
 }
 
-func jobNameForTest(parentName, runKey string) string {
-	base := fmt.Sprintf("%s-%s-test", parentName, runKey)
-	if len(validation.IsDNS1123Label(base)) == 0 && len(base) <= 63 {
-		return base
+// setHookResult records the result of the idx-th hook of a stage.
+func setHookResult(run *steerv1alpha1.HelmTestRunStatus, stage hooks.Stage, idx int, result steerv1alpha1.HookResult) {
+	if run.HookResults == nil {
+		run.HookResults = &steerv1alpha1.HookResults{}
 	}
-	if len(base) > 63 {
-		base = base[:63]
-		base = trimTrailingHyphen(base)
+	results := &run.HookResults.PreTest
+	if stage == hooks.StagePostTest {
+		results = &run.HookResults.PostTest
 	}
-	return base
+	for len(*results) <= idx {
+		*results = append(*results, steerv1alpha1.HookResult{Phase: steerv1alpha1.HelmTestJobPhasePending})
+	}
+	(*results)[idx] = result
+}
+
+// hookResultAt returns the result of the idx-th hook of a stage.
+func hookResultAt(run *steerv1alpha1.HelmTestRunStatus, stage hooks.Stage, idx int) steerv1alpha1.HookResult {
+	if run.HookResults == nil {
+		return steerv1alpha1.HookResult{}
+	}
+	results := run.HookResults.PreTest
+	if stage == hooks.StagePostTest {
+		results = run.HookResults.PostTest
+	}
+	if idx >= len(results) {
+		return steerv1alpha1.HookResult{}
+	}
+	return results[idx]
+}
+
+func hookResultFrom(res hooks.Result) steerv1alpha1.HookResult {
+	out := steerv1alpha1.HookResult{
+		Name:     res.Name,
+		Phase:    res.Phase,
+		Message:  res.Message,
+		ExitCode: res.ExitCode,
+		JobName:  res.ObjectName,
+		PodName:  res.PodName,
+		ImageID:  res.ImageID,
+		Logs:     res.Logs,
+		LogRef:   res.LogRef,
+		Attempts: res.Attempts,
+
+		OutputKeys: outputKeys(res.Outputs),
+
+		Disruption: res.Disruption,
+	}
+	if res.StartedAt != nil {
+		out.StartedAt = &metav1.Time{Time: *res.StartedAt}
+	}
+	if res.CompletedAt != nil {
+		out.CompletedAt = &metav1.Time{Time: *res.CompletedAt}
+	}
+	if res.LastAttemptAt != nil {
+		out.LastAttemptAt = &metav1.Time{Time: *res.LastAttemptAt}
+	}
+	return out
+}
+
+func (r *HelmTestJobReconciler) hookExecutor() hooks.Executor {
+	if r.Hooks == nil {
+		return hooks.NewJobExecutor(r.Client, r.Scheme, nil)
+	}
+	return r.Hooks
+}
+
+func jobNameForTest(parentName, runKey string) string {
+	return hooks.ShortName(fmt.Sprintf("%s-%s-test", parentName, runKey))
 }
 
 func trimTrailingHyphen(s string) string {
@@ -306,87 +479,165 @@ func trimTrailingHyphen(s string) string {
 	return s
 }
 
-func (r *HelmTestJobReconciler) ensureHookJob(ctx context.Context, parent *steerv1alpha1.HelmTestJob, jobName, image string, hook steerv1alpha1.Hook) (steerv1alpha1.HelmTestJobPhase, string, error) {
+// ensureTestJob creates or observes a test Job. previous is the result of
+// the test so far; a retry carries over its test cases. extraEnv is added to
+// the env of spec.test.
+func (r *HelmTestJobReconciler) ensureTestJob(ctx context.Context, parent *steerv1alpha1.HelmTestJob, runKey, jobName, image, serviceAccountName, outputsSecret string, previous *steerv1alpha1.TestResult, extraEnv []corev1.EnvVar) (steerv1alpha1.TestResult, string, error) {
 	var kjob batchv1.Job
 	key := types.NamespacedName{Name: jobName, Namespace: parent.Namespace}
 	if err := r.Get(ctx, key, &kjob); err != nil {
 		if !errors.IsNotFound(err) {
-			return steerv1alpha1.HelmTestJobPhaseFailed, "", err
+			return steerv1alpha1.TestResult{}, "", err
 		}
-		newJob := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: jobName, Namespace: parent.Namespace}}
+		labels := hooks.RunLabels(parent, runKey)
+		newJob := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: jobName, Namespace: parent.Namespace, Labels: labels}}
 		if err := controllerutil.SetControllerReference(parent, &newJob, r.Scheme); err != nil {
-			return steerv1alpha1.HelmTestJobPhaseFailed, "", err
-		}
-
-		container := corev1.Container{Name: "hook", Image: image, ImagePullPolicy: corev1.PullIfNotPresent}
-		switch hook.Type {
-		case steerv1alpha1.HookTypeScript:
-			container.Command = []string{"/bin/sh", "-c", hook.Script}
-		default:
-			return steerv1alpha1.HelmTestJobPhaseFailed, "", fmt.Errorf("unsupported hook.type %q", hook.Type)
+			return steerv1alpha1.TestResult{}, "", err
 		}
 
-		newJob.Spec.Template.Spec = corev1.PodSpec{
-			RestartPolicy: corev1.RestartPolicyNever,
-			Containers:    []corev1.Container{container},
-		}
-		newJob.Spec.BackoffLimit = ptrInt32(0)
-		if err := r.Create(ctx, &newJob); err != nil {
-			return steerv1alpha1.HelmTestJobPhaseFailed, "", err
-		}
-		return steerv1alpha1.HelmTestJobPhasePending, "hook job created", nil
-	}
-
-	return phaseFromJob(&kjob)
-}
-
-func (r *HelmTestJobReconciler) ensureTestJob(ctx context.Context, parent *steerv1alpha1.HelmTestJob, jobName, image string) (steerv1alpha1.HelmTestJobPhase, string, error) {
-	var kjob batchv1.Job
-	key := types.NamespacedName{Name: jobName, Namespace: parent.Namespace}
-	if err := r.Get(ctx, key, &kjob); err != nil {
-		if !errors.IsNotFound(err) {
-			return steerv1alpha1.HelmTestJobPhaseFailed, "", err
-		}
-		newJob := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: jobName, Namespace: parent.Namespace}}
-		if err := controllerutil.SetControllerReference(parent, &newJob, r.Scheme); err != nil {
-			return steerv1alpha1.HelmTestJobPhaseFailed, "", err
+		env, err := hooks.ResolveEnv(ctx, r.Client, parent, parent.Spec.Test.Env, outputsSecret)
+		if err != nil {
+			return steerv1alpha1.TestResult{}, "", err
 		}
-
+		env = append(env, extraEnv...)
+		env = append(env, retryEnv(previous)...)
 		// Minimal placeholder command. Real helm execution can be wired later.
 		container := corev1.Container{
 			Name:            "test",
 			Image:           image,
 			ImagePullPolicy: corev1.PullIfNotPresent,
 			Command:         []string{"/bin/sh", "-c", "echo helm test placeholder"},
+			Env:             env,
 		}
 		newJob.Spec.Template.Spec = corev1.PodSpec{
 			RestartPolicy: corev1.RestartPolicyNever,
 			Containers:    []corev1.Container{container},
 		}
+		hooks.ApplyPodTemplate(&newJob.Spec.Template, parent.Spec.PodTemplate)
+		if serviceAccountName != "" {
+			newJob.Spec.Template.Spec.ServiceAccountName = serviceAccountName
+		}
+		// Run labels win over the overlay, they are used to find the run's objects.
+		if newJob.Spec.Template.Labels == nil {
+			newJob.Spec.Template.Labels = map[string]string{}
+		}
+		for k, v := range labels {
+			newJob.Spec.Template.Labels[k] = v
+		}
 		newJob.Spec.BackoffLimit = ptrInt32(0)
+		newJob.Spec.ActiveDeadlineSeconds = hooks.DeadlineSeconds(&parent.Spec.Test.Timeout)
 		if err := r.Create(ctx, &newJob); err != nil {
-			return steerv1alpha1.HelmTestJobPhaseFailed, "", err
+			return steerv1alpha1.TestResult{}, "", err
 		}
-		return steerv1alpha1.HelmTestJobPhasePending, "test job created", nil
+		result := steerv1alpha1.TestResult{Name: jobName, Phase: steerv1alpha1.HelmTestJobPhasePending, JobName: jobName}
+		carryCases(&result, previous)
+		return result, "test job created", nil
 	}
 
-	return phaseFromJob(&kjob)
-}
-
-func phaseFromJob(job *batchv1.Job) (steerv1alpha1.HelmTestJobPhase, string, error) {
-	for _, c := range job.Status.Conditions {
-		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
-			return steerv1alpha1.HelmTestJobPhaseFailed, "job failed", nil
+	phase, msg := hooks.PhaseFromJob(&kjob)
+	result := steerv1alpha1.TestResult{Name: jobName, Phase: phase, StartedAt: kjob.Status.StartTime, JobName: jobName}
+	carryCases(&result, previous)
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(kjob.Namespace), client.MatchingLabels{batchv1.JobNameLabel: kjob.Name}); err != nil {
+		return steerv1alpha1.TestResult{}, "", err
+	}
+	pod := hooks.NewestPod(pods.Items)
+	if pod != nil {
+		result.PodName = pod.Name
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Name == "test" {
+				result.ImageID = cs.ImageID
+			}
 		}
-		if c.Type == batchv1.JobComplete && c.Status == corev1.ConditionTrue {
-			return steerv1alpha1.HelmTestJobPhaseSucceeded, "job succeeded", nil
+		// Don't wait for the deadline when the pod can never start.
+		if reason := hooks.UnrecoverablePodReason(pod); reason != "" && !phase.IsFinished() {
+			phase, msg = steerv1alpha1.HelmTestJobPhaseFailed, reason
+			result.Phase = phase
 		}
 	}
-	if job.Status.Active > 0 {
-		return steerv1alpha1.HelmTestJobPhaseRunning, "job running", nil
+	if phase == steerv1alpha1.HelmTestJobPhaseFailed {
+		result.Message = msg
+	}
+	if phase.IsFinished() {
+		result.CompletedAt = kjob.Status.CompletionTime
+		if result.CompletedAt == nil {
+			result.CompletedAt = &metav1.Time{Time: time.Now()}
+		}
+		if r.Clientset != nil && result.PodName != "" {
+			r.collectTestLogs(ctx, parent, runKey, &result)
+		}
+		if format := resultFormatOf(parent, pod); format != "" && pod != nil {
+			r.parseTestOutput(ctx, format, pod, &result)
+		}
 	}
-	// If it exists but hasn't started yet.
-	return steerv1alpha1.HelmTestJobPhasePending, "job pending", nil
+	return result, msg, nil
+}
+
+// resultFormatOf returns how the output of a test pod is parsed.
+func resultFormatOf(job *steerv1alpha1.HelmTestJob, pod *corev1.Pod) steerv1alpha1.ResultFormat {
+	if job.Spec.Test.ResultFormat != "" {
+		return job.Spec.Test.ResultFormat
+	}
+	if pod == nil {
+		return ""
+	}
+	return steerv1alpha1.ResultFormat(pod.Annotations[steerv1alpha1.AnnotationResultFormat])
+}
+
+// maxTestCases bounds the test cases kept in a TestResult.
+const maxTestCases = 200
+
+// parseTestOutput fills in the test cases of a finished test from its full
+// output, read from the log sink or the pod, merged into the cases of earlier
+// attempts. A test with failed cases fails.
+func (r *HelmTestJobReconciler) parseTestOutput(ctx context.Context, format steerv1alpha1.ResultFormat, pod *corev1.Pod, result *steerv1alpha1.TestResult) {
+	logger := log.FromContext(ctx)
+	var (
+		output io.ReadCloser
+		err    error
+	)
+	switch {
+	case result.LogRef != "" && r.LogSink != nil:
+		output, err = r.LogSink.Open(ctx, result.LogRef)
+	case r.Clientset != nil:
+		output, err = r.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: "test"}).Stream(ctx)
+	default:
+		return
+	}
+	if err != nil {
+		logger.Error(err, "failed to read test output", "pod", pod.Name)
+		return
+	}
+	defer output.Close()
+
+	cases, counts, err := results.Parse(format, output, maxTestCases)
+	if err != nil {
+		logger.Error(err, "failed to parse test output", "pod", pod.Name, "format", format)
+	}
+	if counts.Total == 0 {
+		return
+	}
+	result.Cases, counts = mergeCases(result.Cases, result.Counts, cases, counts)
+	result.Counts = &counts
+	if counts.Failed > 0 && result.Phase == steerv1alpha1.HelmTestJobPhaseSucceeded {
+		result.Phase = steerv1alpha1.HelmTestJobPhaseFailed
+		result.Message = fmt.Sprintf("%d of %d test cases failed", counts.Failed, counts.Total)
+	}
+}
+
+// collectTestLogs stores the logs of a finished test pod in the log sink
+// and keeps their tail in the result. Logs are best effort.
+func (r *HelmTestJobReconciler) collectTestLogs(ctx context.Context, parent *steerv1alpha1.HelmTestJob, runKey string, result *steerv1alpha1.TestResult) {
+	key := logsink.Key(parent.Namespace, parent.Name, runKey, "test", result.Name)
+	tail, ref, err := logsink.Collect(ctx, r.Clientset, r.LogSink, parent.Namespace, result.PodName, "test", key)
+	if err != nil && r.LogSink != nil {
+		log.FromContext(ctx).Error(err, "failed to store test logs", "key", key)
+		tail, ref, err = logsink.Collect(ctx, r.Clientset, nil, parent.Namespace, result.PodName, "test", key)
+	}
+	if err != nil {
+		return
+	}
+	result.Logs, result.LogRef = tail, ref
 }
 
 func ptrInt32(v int32) *int32 { return &v }
@@ -401,51 +652,88 @@ func computeNextScheduleTime(now time.Time, creationTime time.Time, spec steerv1
 		}
 		return ctrl.Result{RequeueAfter: requeueAfter}, next, nil
 	case steerv1alpha1.ScheduleTypeCron:
-		if spec.Cron == "" {
-			return ctrl.Result{}, time.Time{}, fmt.Errorf("schedule.cron is required when type=cron")
-		}
-		loc, err := time.LoadLocation(spec.Timezone)
-		if err != nil {
-			return ctrl.Result{}, time.Time{}, fmt.Errorf("invalid schedule.timezone %q: %w", spec.Timezone, err)
+		anchor := now
+		if lastScheduleTime != nil {
+			// Avoid scheduling immediately after a run by anchoring to lastScheduleTime.
+			anchor = lastScheduleTime.Time
 		}
-
-		// Standard 5-field cron with descriptors.
-		parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
-		schedule, err := parser.Parse(spec.Cron)
+		next, err := nextCronTime(spec, anchor)
 		if err != nil {
-			return ctrl.Result{}, time.Time{}, fmt.Errorf("invalid schedule.cron %q: %w", spec.Cron, err)
+			return ctrl.Result{}, time.Time{}, err
 		}
-
 		// If we already have a next schedule time, keep it until it is due.
 		if currentNext != nil {
-			next := currentNext.Time
-			requeueAfter := next.Sub(now)
-			if requeueAfter < 0 {
-				requeueAfter = 0
-			}
-			return ctrl.Result{RequeueAfter: requeueAfter}, next, nil
-		}
-
-		nowInLoc := now.In(loc)
-		anchor := nowInLoc
-		if lastScheduleTime != nil {
-			// Avoid scheduling immediately after a run by anchoring to lastScheduleTime.
-			anchor = lastScheduleTime.Time.In(loc)
+			next = currentNext.Time
 		}
-		next := schedule.Next(anchor)
-		requeueAfter := next.Sub(nowInLoc)
+		requeueAfter := next.Sub(now)
 		if requeueAfter < 0 {
 			requeueAfter = 0
 		}
 		return ctrl.Result{RequeueAfter: requeueAfter}, next, nil
+	case steerv1alpha1.ScheduleTypeOnRelease:
+		return ctrl.Result{}, time.Time{}, nil
 	default:
 		return ctrl.Result{}, time.Time{}, fmt.Errorf("unsupported schedule.type %q", spec.Type)
 	}
 }
 
+// nextCronTime returns the first schedule time of a cron schedule after t.
+func nextCronTime(spec steerv1alpha1.ScheduleSpec, t time.Time) (time.Time, error) {
+	if spec.Cron == "" {
+		return time.Time{}, fmt.Errorf("schedule.cron is required when type=cron")
+	}
+	loc, err := time.LoadLocation(spec.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid schedule.timezone %q: %w", spec.Timezone, err)
+	}
+
+	// Standard 5-field cron with descriptors.
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	schedule, err := parser.Parse(spec.Cron)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid schedule.cron %q: %w", spec.Cron, err)
+	}
+	return schedule.Next(t.In(loc)), nil
+}
+
+// helmReleaseRefIndex indexes HelmTestJobs by "<namespace>/<name>" of
+// the HelmRelease they test.
+const helmReleaseRefIndex = "spec.helmReleaseRef"
+
+// jobsForRelease enqueues the onRelease HelmTestJobs that test a HelmRelease.
+func (r *HelmTestJobReconciler) jobsForRelease(ctx context.Context, obj client.Object) []reconcile.Request {
+	var jobs steerv1alpha1.HelmTestJobList
+	key := obj.GetNamespace() + "/" + obj.GetName()
+	if err := r.List(ctx, &jobs, client.MatchingFields{helmReleaseRefIndex: key}); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list HelmTestJobs for HelmRelease", "helmRelease", key)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, job := range jobs.Items {
+		if job.Spec.Schedule.Type != steerv1alpha1.ScheduleTypeOnRelease {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: job.Name, Namespace: job.Namespace}})
+	}
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *HelmTestJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &steerv1alpha1.HelmTestJob{}, helmReleaseRefIndex, func(obj client.Object) []string {
+		job := obj.(*steerv1alpha1.HelmTestJob)
+		ns := job.Spec.HelmReleaseRef.Namespace
+		if ns == "" {
+			ns = job.Namespace
+		}
+		return []string{ns + "/" + job.Spec.HelmReleaseRef.Name}
+	}); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&steerv1alpha1.HelmTestJob{}).
+		Owns(&steerv1alpha1.HelmTestRun{}).
+		Watches(&steerv1alpha1.HelmRelease{}, handler.EnqueueRequestsFromMapFunc(r.jobsForRelease)).
 		Complete(r)
 }
@@ -23,73 +23,171 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/config"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
 )
 
-var _ = Describe("HelmTestJob Controller", func() {
-	Context("When reconciling a resource", func() {
-		const resourceName = "test-resource"
+const resourceName = "test-resource"
 
-		ctx := context.Background()
+var (
+	ctx                = context.Background()
+	typeNamespacedName = types.NamespacedName{Name: resourceName, Namespace: "default"}
+)
 
-		typeNamespacedName := types.NamespacedName{
-			Name:      resourceName,
-			Namespace: "default", // TODO(user):Modify as needed
-		}
-		helmtestjob := &steerv1alpha1.HelmTestJob{}
-
-		BeforeEach(func() {
-			By("creating the custom resource for the Kind HelmTestJob")
-			err := k8sClient.Get(ctx, typeNamespacedName, helmtestjob)
-			if err != nil && errors.IsNotFound(err) {
-				resource := &steerv1alpha1.HelmTestJob{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      resourceName,
+// createRun creates a run of owner in the given phase.
+func createRun(owner *steerv1alpha1.HelmTestJob, runKey string, phase steerv1alpha1.HelmTestJobPhase) {
+	run := &steerv1alpha1.HelmTestRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      runName(owner.Name, runKey),
+			Namespace: owner.Namespace,
+			Labels:    hooks.RunLabels(owner, runKey),
+		},
+		Spec: steerv1alpha1.HelmTestRunSpec{
+			HelmTestJobName: owner.Name,
+			RunKey:          runKey,
+			Trigger:         steerv1alpha1.HelmTestRunTriggerSchedule,
+			ScheduledTime:   &metav1.Time{Time: time.Now().Add(-time.Hour)},
+		},
+	}
+	Expect(k8sClient.Create(ctx, run)).To(Succeed())
+	run.Status.Phase = phase
+	if phase == steerv1alpha1.HelmTestJobPhaseRunning {
+		run.Status.CurrentStage = steerv1alpha1.HelmTestJobStagePreTest
+	}
+	Expect(k8sClient.Status().Update(ctx, run)).To(Succeed())
+}
+
+// latestRun returns the run the HelmTestJob's status points to.
+func latestRun() *steerv1alpha1.HelmTestRun {
+	job := &steerv1alpha1.HelmTestJob{}
+	Expect(k8sClient.Get(ctx, typeNamespacedName, job)).To(Succeed())
+	Expect(job.Status.LastRunName).NotTo(BeEmpty())
+	run := &steerv1alpha1.HelmTestRun{}
+	Expect(k8sClient.Get(ctx, types.NamespacedName{Name: job.Status.LastRunName, Namespace: "default"}, run)).To(Succeed())
+	return run
+}
+
+// withHelmTestJob creates the HelmTestJob the specs of a container reconcile
+// before each of them and deletes it, its runs and its test Job afterwards.
+func withHelmTestJob() {
+	helmtestjob := &steerv1alpha1.HelmTestJob{}
+
+	BeforeEach(func() {
+		By("creating the custom resource for the Kind HelmTestJob")
+		err := k8sClient.Get(ctx, typeNamespacedName, helmtestjob)
+		if err != nil && errors.IsNotFound(err) {
+			resource := &steerv1alpha1.HelmTestJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: steerv1alpha1.HelmTestJobSpec{
+					HelmReleaseRef: steerv1alpha1.HelmReleaseRef{
+						Name:      "example-release",
 						Namespace: "default",
 					},
-					Spec: steerv1alpha1.HelmTestJobSpec{
-						HelmReleaseRef: steerv1alpha1.HelmReleaseRef{
-							Name:      "example-release",
-							Namespace: "default",
-						},
-						Schedule: steerv1alpha1.ScheduleSpec{
-							Type: steerv1alpha1.ScheduleTypeOnce,
-							Delay: metav1.Duration{
-								Duration: 0,
-							},
-							Timezone: "Asia/Shanghai",
-						},
-						Test: steerv1alpha1.TestSpec{
-							Image:   "busybox:1.36",
-							Timeout: metav1.Duration{Duration: 0},
-							Logs:    nil,
-							Filter:  "",
+					Schedule: steerv1alpha1.ScheduleSpec{
+						Type: steerv1alpha1.ScheduleTypeOnce,
+						Delay: metav1.Duration{
+							Duration: 0,
 						},
+						Timezone: "Asia/Shanghai",
 					},
-				}
-				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+					Test: steerv1alpha1.TestSpec{
+						Image:   "busybox:1.36",
+						Timeout: metav1.Duration{Duration: 0},
+						Logs:    nil,
+						Filter:  "",
+					},
+				},
 			}
-		})
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		}
+	})
 
-		AfterEach(func() {
-			// TODO(user): Cleanup logic after each test, like removing the resource instance.
-			resource := &steerv1alpha1.HelmTestJob{}
-			err := k8sClient.Get(ctx, typeNamespacedName, resource)
-			Expect(err).NotTo(HaveOccurred())
+	AfterEach(func() {
+		// TODO(user): Cleanup logic after each test, like removing the resource instance.
+		resource := &steerv1alpha1.HelmTestJob{}
+		err := k8sClient.Get(ctx, typeNamespacedName, resource)
+		Expect(client.IgnoreNotFound(err)).NotTo(HaveOccurred())
 
+		if err == nil {
 			By("Cleanup the specific resource instance HelmTestJob")
-			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			if len(resource.Finalizers) > 0 {
+				resource.Finalizers = nil
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			}
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, resource))).To(Succeed())
+		}
+
+		// envtest runs no garbage collector, so delete what the runs created.
+		Expect(k8sClient.DeleteAllOf(ctx, &batchv1.Job{}, client.InNamespace("default"), client.PropagationPolicy(metav1.DeletePropagationBackground))).To(Succeed())
+		Expect(k8sClient.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace("default"))).To(Succeed())
+		Expect(k8sClient.DeleteAllOf(ctx, &steerv1alpha1.HelmTestRun{}, client.InNamespace("default"))).To(Succeed())
+		owned := client.MatchingLabels{steerv1alpha1.LabelHelmTestJob: resourceName}
+		for _, list := range []client.ObjectList{&corev1.SecretList{}, &corev1.ServiceAccountList{}, &rbacv1.RoleList{}, &rbacv1.RoleBindingList{}} {
+			Expect(k8sClient.List(ctx, list, owned)).To(Succeed())
+			Expect(meta.EachListItem(list, func(obj runtime.Object) error {
+				return client.IgnoreNotFound(k8sClient.Delete(ctx, obj.(client.Object)))
+			})).To(Succeed())
+		}
+		var namespaces corev1.NamespaceList
+		Expect(k8sClient.List(ctx, &namespaces, owned)).To(Succeed())
+		for _, ns := range namespaces.Items {
+			removeNamespace(ns.Name)
+		}
+	})
+}
+
+// removeNamespace deletes a namespace and finalizes it. envtest runs no
+// namespace controller, so a deleted namespace would stay terminating and
+// later specs could not create it again.
+func removeNamespace(name string) {
+	ns := &corev1.Namespace{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: name}, ns)
+	if errors.IsNotFound(err) {
+		return
+	}
+	Expect(err).NotTo(HaveOccurred())
+	if ns.DeletionTimestamp == nil {
+		Expect(k8sClient.Delete(ctx, ns)).To(Succeed())
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: name}, ns); errors.IsNotFound(err) {
+			return
+		}
+	}
+	ns.Spec.Finalizers = nil
+	Expect(client.IgnoreNotFound(k8sClient.SubResource("finalize").Update(ctx, ns))).To(Succeed())
+}
+
+// expectNamespaceDeleted asserts that the controller deleted a namespace.
+func expectNamespaceDeleted(name string) {
+	ns := &corev1.Namespace{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: name}, ns)
+	if errors.IsNotFound(err) {
+		return
+	}
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.DeletionTimestamp).NotTo(BeNil(), "namespace %s was not deleted", name)
+	removeNamespace(name)
+}
+
+var _ = Describe("HelmTestJob Controller", func() {
+	Context("When reconciling a resource", func() {
+		withHelmTestJob()
 
-			// Best-effort cleanup for Jobs created by the controller.
-			j := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}}
-			_ = k8sClient.Delete(ctx, j)
-		})
 		It("should successfully reconcile the once schedule resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &HelmTestJobReconciler{
@@ -113,29 +211,259 @@ var _ = Describe("HelmTestJob Controller", func() {
 			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, createdJob)).To(Succeed())
 		})
 
-		It("should successfully reconcile the cron schedule resource", func() {
-			By("Updating the resource to use cron schedule")
+		It("should stop a run that exceeds its runTimeout", func() {
 			resource := &steerv1alpha1.HelmTestJob{}
 			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
-			resource.Spec.Schedule.Type = steerv1alpha1.ScheduleTypeCron
-			resource.Spec.Schedule.Cron = "* * * * *"
-			resource.Spec.Schedule.Timezone = "Asia/Shanghai"
+			resource.Spec.RunTimeout = &metav1.Duration{Duration: time.Minute}
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "slow", Type: steerv1alpha1.HookTypeScript, Script: "sleep 600"}}
+			resource.Spec.Hooks.PostTest = []steerv1alpha1.Hook{
+				{Name: "teardown", Type: steerv1alpha1.HookTypeScript, Script: "true", RunPolicy: steerv1alpha1.HookRunPolicyAlways},
+			}
 			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
 
+			var executed []string
 			controllerReconciler := &HelmTestJobReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						executed = append(executed, req.Hook.Name)
+						phase := steerv1alpha1.HelmTestJobPhaseSucceeded
+						if req.Stage == hooks.StagePreTest {
+							phase = steerv1alpha1.HelmTestJobPhaseRunning
+						}
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: phase}, nil
+					},
+				},
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
 			Expect(err).NotTo(HaveOccurred())
 
-			updated := &steerv1alpha1.HelmTestJob{}
-			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
-			Expect(updated.Status.NextScheduleTime).NotTo(BeNil())
-			// Cron should schedule the next minute boundary (or later) in the specified timezone.
-			// Allow small clock skew to avoid flakiness.
-			Expect(updated.Status.NextScheduleTime.Time.After(time.Now().Add(-5 * time.Second))).To(BeTrue())
+			By("Moving the run start past the runTimeout")
+			run := latestRun()
+			run.Status.StartTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			Expect(k8sClient.Status().Update(ctx, run)).To(Succeed())
+
+			executed = nil
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(executed).To(Equal([]string{"teardown"}))
+
+			run = latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Message).To(ContainSubstring("runTimeout"))
+			Expect(run.Status.TimedOutAt).NotTo(BeNil())
+			Expect(run.Status.HookResults.PreTest[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSkipped))
+			Expect(run.Status.HookResults.PreTest[0].Message).To(Equal("run timed out"))
+			Expect(run.Status.HookResults.PostTest[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+		})
+
+		It("should fail the test quickly when its pod can't pull the image", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Test.Timeout = metav1.Duration{Duration: 5 * time.Minute}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			jobName := jobNameForTest(resourceName, "once")
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobName, Namespace: "default"}, testJob)).To(Succeed())
+			Expect(testJob.Spec.ActiveDeadlineSeconds).NotTo(BeNil())
+			Expect(*testJob.Spec.ActiveDeadlineSeconds).To(Equal(int64(300)))
+
+			By("Reporting ImagePullBackOff for the test pod")
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:      jobName + "-abcde",
+				Namespace: "default",
+				Labels:    map[string]string{batchv1.JobNameLabel: jobName},
+			}}
+			pod.Spec.Containers = []corev1.Container{{Name: "test", Image: "busybox:1.36"}}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name: "test",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+					Reason:  "ImagePullBackOff",
+					Message: "Back-off pulling image",
+				}},
+			}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+			defer func() { _ = k8sClient.Delete(ctx, pod) }()
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.TestResults[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Message).To(ContainSubstring("ImagePullBackOff"))
+		})
+
+		It("should observe the newest pod of the test Job", func() {
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Creating a stuck pod and its replacement, which is listed first")
+			jobName := jobNameForTest(resourceName, "once")
+			startedAt := metav1.NewTime(time.Now().Add(-time.Minute))
+			for _, p := range []struct {
+				name    string
+				started metav1.Time
+				waiting string
+			}{
+				{name: jobName + "-zzzzz", started: startedAt, waiting: "ImagePullBackOff"},
+				{name: jobName + "-aaaaa", started: metav1.NewTime(startedAt.Add(30 * time.Second))},
+			} {
+				pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+					Name:      p.name,
+					Namespace: "default",
+					Labels:    map[string]string{batchv1.JobNameLabel: jobName},
+				}}
+				pod.Spec.Containers = []corev1.Container{{Name: "test", Image: "busybox:1.36"}}
+				Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+				defer func() { _ = k8sClient.Delete(ctx, pod) }()
+				pod.Status.StartTime = &p.started
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "test", ImageID: "image-of-" + p.name}}
+				if p.waiting != "" {
+					pod.Status.ContainerStatuses[0].State.Waiting = &corev1.ContainerStateWaiting{Reason: p.waiting}
+				} else {
+					pod.Status.ContainerStatuses[0].State.Running = &corev1.ContainerStateRunning{StartedAt: p.started}
+				}
+				Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+			}
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			Expect(run.Status.TestResults[0].PodName).To(Equal(jobName + "-aaaaa"))
+			Expect(run.Status.TestResults[0].ImageID).To(Equal("image-of-" + jobName + "-aaaaa"))
+		})
+
+		It("should merge the pod template into the test Job", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.PodTemplate = &steerv1alpha1.PodTemplateOverlay{
+				Labels: map[string]string{
+					"team":                    "qa",
+					steerv1alpha1.LabelRunKey: "overridden",
+				},
+				ServiceAccountName: "tester",
+				Resources: &corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceCPU: apiresource.MustParse("500m")},
+				},
+				NodeSelector:     map[string]string{"pool": "test"},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+				Config: &config.Config{PodTemplate: config.PodTemplatePolicy{AllowedServiceAccounts: []string{"tester"}}},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, testJob)).To(Succeed())
+			tmpl := testJob.Spec.Template
+			Expect(tmpl.Labels).To(HaveKeyWithValue("team", "qa"))
+			Expect(tmpl.Labels).To(HaveKeyWithValue(steerv1alpha1.LabelRunKey, "once"))
+			Expect(tmpl.Spec.ServiceAccountName).To(Equal("tester"))
+			Expect(tmpl.Spec.NodeSelector).To(HaveKeyWithValue("pool", "test"))
+			Expect(tmpl.Spec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "registry"}))
+			Expect(tmpl.Spec.Containers[0].Resources.Limits.Cpu().String()).To(Equal("500m"))
+		})
+
+		It("should fail the run when the pod template asks for more than the operator config allows", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.PodTemplate = &steerv1alpha1.PodTemplateOverlay{
+				ServiceAccountName: "cluster-admin",
+				Volumes: []corev1.Volume{{
+					Name:         "host",
+					VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}},
+				}},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Message).To(ContainSubstring("podTemplate.allowedServiceAccounts"))
+			Expect(run.Status.Message).To(ContainSubstring("podTemplate.allowHostPath"))
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, &batchv1.Job{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should fail the run when an image is not from an allowed registry", func() {
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+				Config: &config.Config{AllowedRegistries: []string{"ghcr.io/steer"}},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Message).To(ContainSubstring(`image "busybox:1.36" is not from an allowed registry`))
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, &batchv1.Job{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should use the default images of the operator config", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Test.Image = ""
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "check", Type: steerv1alpha1.HookTypeScript, Script: "true"}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			var hookImage string
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						hookImage = req.Image
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}, nil
+					},
+				},
+				Config: &config.Config{DefaultImages: config.DefaultImages{
+					Test:  "alpine/helm:3.14.0",
+					Hooks: map[steerv1alpha1.HookType]string{steerv1alpha1.HookTypeScript: "bitnami/kubectl:1.29"},
+				}},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hookImage).To(Equal("bitnami/kubectl:1.29"))
+
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, testJob)).To(Succeed())
+			Expect(testJob.Spec.Template.Spec.Containers[0].Image).To(Equal("alpine/helm:3.14.0"))
 		})
 	})
 })
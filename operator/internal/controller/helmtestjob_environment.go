@@ -0,0 +1,348 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/helm"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+// Env vars of test pods in an ephemeral environment.
+const (
+	releaseNameEnvVar      = "STEER_RELEASE_NAME"
+	releaseNamespaceEnvVar = "STEER_RELEASE_NAMESPACE"
+)
+
+// helmTestJobFinalizer tears down the ephemeral environments of a deleted
+// HelmTestJob and restores what its chaos hooks disrupted.
+const helmTestJobFinalizer = "steer.io/environments"
+
+// needsFinalizer reports whether a HelmTestJob leaves anything behind that
+// owner references don't clean up.
+func needsFinalizer(job *steerv1alpha1.HelmTestJob) bool {
+	if job.Spec.Environment == steerv1alpha1.EnvironmentEphemeral {
+		return true
+	}
+	for _, h := range append(append([]steerv1alpha1.Hook{}, job.Spec.Hooks.PreTest...), job.Spec.Hooks.PostTest...) {
+		if h.Type == steerv1alpha1.HookTypeChaos {
+			return true
+		}
+	}
+	return false
+}
+
+// errNoHelm fails ephemeral runs of an operator without a Helm client.
+var errNoHelm = errors.New("ephemeral environments are not supported: the operator has no Helm client configured")
+
+// environmentNamespace returns the namespace of the ephemeral environment of
+// a run, <release>-<hash>-<runKey>. The hash of the HelmTestJob keeps the
+// environments of jobs testing the same release apart. Long release names
+// are shortened.
+func environmentNamespace(job *steerv1alpha1.HelmTestJob, release, runKey string) string {
+	h := fnv.New32a()
+	h.Write([]byte(job.Namespace + "/" + job.Name))
+	release = strings.ReplaceAll(release, ".", "-")
+	suffix := fmt.Sprintf("-%08x-%s", h.Sum32(), runKey)
+	if len(release)+len(suffix) > 63 {
+		release = trimTrailingHyphen(release[:max(63-len(suffix), 1)])
+	}
+	return release + suffix
+}
+
+// environmentLabels mark the namespace of an ephemeral environment as
+// created for a run. Namespaces without them are never used or deleted.
+func environmentLabels(job *steerv1alpha1.HelmTestJob, runKey string) map[string]string {
+	labels := hooks.RunLabels(job, runKey)
+	labels[steerv1alpha1.LabelHelmTestJobNamespace] = job.Namespace
+	return labels
+}
+
+// ensureEnvironmentNamespace creates the namespace of an ephemeral
+// environment. An existing namespace is only used if it was created for the
+// run.
+func (r *HelmTestJobReconciler) ensureEnvironmentNamespace(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun) error {
+	name := run.Status.Environment.Namespace
+	ns := &corev1.Namespace{}
+	err := r.Get(ctx, client.ObjectKey{Name: name}, ns)
+	switch {
+	case apierrors.IsNotFound(err):
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: environmentLabels(job, run.Spec.RunKey)}}
+		if err := r.Create(ctx, ns); err != nil {
+			return fmt.Errorf("create namespace %s: %w", name, err)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("get namespace %s: %w", name, err)
+	case !ownsEnvironmentNamespace(job, run, ns):
+		return fmt.Errorf("namespace %s already exists and was not created for this run", name)
+	case ns.DeletionTimestamp != nil:
+		return fmt.Errorf("namespace %s is being deleted", name)
+	}
+	return nil
+}
+
+func ownsEnvironmentNamespace(job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun, ns *corev1.Namespace) bool {
+	for k, v := range environmentLabels(job, run.Spec.RunKey) {
+		if ns.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// checkEnvironmentNamespace returns an error unless the namespace of the
+// ephemeral environment of a run is gone or was created for it.
+func (r *HelmTestJobReconciler) checkEnvironmentNamespace(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun) error {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, client.ObjectKey{Name: run.Status.Environment.Namespace}, ns); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !ownsEnvironmentNamespace(job, run, ns) {
+		return fmt.Errorf("refusing to delete namespace %s: it was not created for this run", ns.Name)
+	}
+	return nil
+}
+
+// installEnvironment installs the spec of the referenced HelmRelease into
+// the ephemeral environment of a run. The install runs in the background;
+// installEnvironment reports whether it is done and is called again until
+// it is. The environment status is set as soon as the namespace is known,
+// so a failed install can still be cleaned up.
+func (r *HelmTestJobReconciler) installEnvironment(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun, now time.Time) (bool, error) {
+	if r.Helm == nil {
+		return false, errNoHelm
+	}
+	release, err := r.releaseOf(ctx, job)
+	if err != nil {
+		return false, err
+	}
+	env := run.Status.Environment
+	if env == nil {
+		env = &steerv1alpha1.HelmTestRunEnvironmentStatus{
+			Namespace:   environmentNamespace(job, release.Name, run.Spec.RunKey),
+			ReleaseName: release.Name,
+		}
+		run.Status.Environment = env
+	}
+	if err := r.ensureEnvironmentNamespace(ctx, job, run); err != nil {
+		return false, err
+	}
+	req := helm.InstallOrUpgradeRequest{
+		ReleaseName:     env.ReleaseName,
+		Namespace:       env.Namespace,
+		Chart:           release.Spec.Chart,
+		Values:          release.Spec.Values,
+		ValuesNamespace: release.Namespace,
+		Timeout:         release.Spec.Deployment.Timeout,
+	}
+	info, done, err := r.installs.poll(client.ObjectKeyFromObject(run), func() (helm.ReleaseInfo, error) {
+		// The install outlives the reconcile that started it; Helm bounds
+		// it with the timeout of the HelmRelease.
+		return r.Helm.InstallOrUpgrade(context.Background(), req)
+	})
+	if !done || err != nil {
+		return done, err
+	}
+	installedAt := metav1.NewTime(now)
+	env.Revision = info.Version
+	env.InstalledAt = &installedAt
+	return true, nil
+}
+
+// uninstallEnvironment uninstalls the release of an ephemeral environment,
+// once. It doesn't matter whether the install finished: a failed or
+// interrupted install can leave a release behind, and Helm treats a release
+// that doesn't exist as uninstalled.
+func (r *HelmTestJobReconciler) uninstallEnvironment(ctx context.Context, env *steerv1alpha1.HelmTestRunEnvironmentStatus, now time.Time) error {
+	if env.UninstalledAt != nil {
+		return nil
+	}
+	if r.Helm == nil {
+		return errNoHelm
+	}
+	err := r.Helm.Uninstall(ctx, helm.UninstallRequest{
+		ReleaseName: env.ReleaseName,
+		Namespace:   env.Namespace,
+	})
+	if err != nil {
+		return err
+	}
+	uninstalledAt := metav1.NewTime(now)
+	env.UninstalledAt = &uninstalledAt
+	return nil
+}
+
+// teardownEnvironment uninstalls the ephemeral environment of a run and
+// deletes its namespace, whatever spec.cleanup says. It is used before a run
+// is pruned and when its HelmTestJob is deleted. It returns false while the
+// environment is still being installed.
+func (r *HelmTestJobReconciler) teardownEnvironment(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun, now time.Time) (bool, error) {
+	env := run.Status.Environment
+	if env == nil {
+		return true, nil
+	}
+	if r.installs.running(client.ObjectKeyFromObject(run)) {
+		return false, nil
+	}
+	if err := r.checkEnvironmentNamespace(ctx, job, run); err != nil {
+		// Someone else's namespace is left alone.
+		log.FromContext(ctx).Info("not tearing down the environment", "run", run.Name, "reason", err.Error())
+		return true, nil
+	}
+	if err := r.uninstallEnvironment(ctx, env, now); err != nil {
+		return false, fmt.Errorf("uninstall environment of run %s: %w", run.Name, err)
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: env.Namespace}}
+	if err := r.Delete(ctx, ns); err != nil && !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("delete namespace %s: %w", env.Namespace, err)
+	}
+	return true, nil
+}
+
+// finalize restores the chaos disruptions and tears down the ephemeral
+// environments of a deleted HelmTestJob and then removes the finalizer.
+func (r *HelmTestJobReconciler) finalize(ctx context.Context, job *steerv1alpha1.HelmTestJob, now time.Time) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(job, helmTestJobFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	runs, err := r.listRuns(ctx, job)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	pending := false
+	for _, run := range runs {
+		if !disrupted(run) && run.Status.Environment == nil {
+			continue
+		}
+		err := r.restoreDisruptions(ctx, run, now)
+		done := err == nil
+		if err == nil {
+			done, err = r.teardownEnvironment(ctx, job, run, now)
+		}
+		if updateErr := r.Status().Update(ctx, run); updateErr != nil && !apierrors.IsNotFound(updateErr) {
+			return ctrl.Result{}, updateErr
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		pending = pending || !done
+	}
+	if pending {
+		return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
+	}
+	controllerutil.RemoveFinalizer(job, helmTestJobFinalizer)
+	return ctrl.Result{}, client.IgnoreNotFound(r.Update(ctx, job))
+}
+
+// environmentInstalls runs the installs of ephemeral environments in the
+// background, so a slow chart doesn't hold up the reconciles of other
+// HelmTestJobs. Installs are keyed by their run and forgotten once their
+// result was polled; after a restart an unfinished install is started
+// again.
+type environmentInstalls struct {
+	mu       sync.Mutex
+	installs map[types.NamespacedName]*environmentInstall
+}
+
+type environmentInstall struct {
+	done chan struct{}
+	info helm.ReleaseInfo
+	err  error
+}
+
+// poll starts install unless an install of the run is running and returns
+// its result once it finished.
+func (e *environmentInstalls) poll(run types.NamespacedName, install func() (helm.ReleaseInfo, error)) (helm.ReleaseInfo, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	in, ok := e.installs[run]
+	if !ok {
+		if e.installs == nil {
+			e.installs = map[types.NamespacedName]*environmentInstall{}
+		}
+		in = &environmentInstall{done: make(chan struct{})}
+		e.installs[run] = in
+		go func() {
+			defer close(in.done)
+			in.info, in.err = install()
+		}()
+	}
+	select {
+	case <-in.done:
+		delete(e.installs, run)
+		return in.info, true, in.err
+	default:
+		return helm.ReleaseInfo{}, false, nil
+	}
+}
+
+// running reports whether an install of the run is in progress. A finished
+// install nobody polls any more, as its run ended, is forgotten.
+func (e *environmentInstalls) running(run types.NamespacedName) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	in, ok := e.installs[run]
+	if !ok {
+		return false
+	}
+	select {
+	case <-in.done:
+		delete(e.installs, run)
+		return false
+	default:
+		return true
+	}
+}
+
+// environmentEnv tells the test pod where the release under test is
+// installed, for runs in an ephemeral environment.
+func environmentEnv(env *steerv1alpha1.HelmTestRunEnvironmentStatus) []corev1.EnvVar {
+	if env == nil {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{Name: releaseNameEnvVar, Value: env.ReleaseName},
+		{Name: releaseNamespaceEnvVar, Value: env.Namespace},
+	}
+}
+
+// environmentNamespaceOf returns the namespace of the ephemeral environment
+// of a run, or "" for a shared one.
+func environmentNamespaceOf(run *steerv1alpha1.HelmTestRunStatus) string {
+	if run.Environment == nil {
+		return ""
+	}
+	return run.Environment.Namespace
+}
@@ -0,0 +1,354 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/cleanup"
+	"github.com/MrLYC/steer/operator/pkg/helm"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+var _ = Describe("HelmTestJob Controller", func() {
+	Context("When running in an ephemeral environment", func() {
+		withHelmTestJob()
+
+		It("should run the tests in an ephemeral environment and tear it down", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Values:     steerv1alpha1.ValuesSpec{Inline: "replicas: 1"},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "shared-target"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Environment = steerv1alpha1.EnvironmentEphemeral
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "wait", Type: steerv1alpha1.HookTypeWaitFor}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			envNamespace := environmentNamespace(resource, "example-release", "once")
+			Expect(envNamespace).To(MatchRegexp(`^example-release-[0-9a-f]{8}-once$`))
+
+			var mu sync.Mutex
+			var installed []helm.InstallOrUpgradeRequest
+			var uninstalled []helm.UninstallRequest
+			var cleaned []string
+			var cleanedOpts cleanup.Options
+			var hookNamespace string
+			release.Status.Phase = steerv1alpha1.HelmReleasePhaseInstalled
+			installing := make(chan struct{})
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						hookNamespace = req.ReleaseNamespace
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}, nil
+					},
+				},
+				Helm: &helm.FakeClient{
+					InstallOrUpgradeFunc: func(ctx context.Context, req helm.InstallOrUpgradeRequest) (helm.ReleaseInfo, error) {
+						<-installing
+						mu.Lock()
+						defer mu.Unlock()
+						installed = append(installed, req)
+						return helm.ReleaseInfo{Name: req.ReleaseName, Namespace: req.Namespace, Version: 1, Status: "deployed"}, nil
+					},
+					UninstallFunc: func(ctx context.Context, req helm.UninstallRequest) error {
+						uninstalled = append(uninstalled, req)
+						return nil
+					},
+				},
+				Cleanup: &cleanup.FakeRunner{
+					CleanupNamespaceFunc: func(ctx context.Context, namespace string, opts cleanup.Options) error {
+						cleaned, cleanedOpts = append(cleaned, namespace), opts
+						return nil
+					},
+				},
+			}
+			res, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Waiting for the install without blocking the reconcile")
+			Expect(res.RequeueAfter).NotTo(BeZero())
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			Expect(run.Status.Environment).NotTo(BeNil())
+			Expect(run.Status.Environment.Namespace).To(Equal(envNamespace))
+			Expect(run.Status.Environment.InstalledAt).To(BeNil())
+			Expect(run.Status.Message).To(ContainSubstring("installing ephemeral environment"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(ContainElement(helmTestJobFinalizer))
+
+			By("Creating and labeling the run's namespace")
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: envNamespace}, ns)).To(Succeed())
+			Expect(ns.Labels).To(HaveKeyWithValue(steerv1alpha1.LabelHelmTestJob, resourceName))
+			Expect(ns.Labels).To(HaveKeyWithValue(steerv1alpha1.LabelHelmTestJobNamespace, "default"))
+			Expect(ns.Labels).To(HaveKeyWithValue(steerv1alpha1.LabelRunKey, "once"))
+
+			By("Installing the release into the run's namespace")
+			close(installing)
+			Eventually(func() *metav1.Time {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				return latestRun().Status.Environment.InstalledAt
+			}).ShouldNot(BeNil())
+			mu.Lock()
+			Expect(installed).To(HaveLen(1))
+			Expect(installed[0].ReleaseName).To(Equal("example-release"))
+			Expect(installed[0].Namespace).To(Equal(envNamespace))
+			Expect(installed[0].Values.Inline).To(Equal("replicas: 1"))
+			Expect(installed[0].ValuesNamespace).To(Equal("default"))
+			mu.Unlock()
+			run = latestRun()
+			Expect(run.Status.Environment.Revision).To(Equal(int64(1)))
+			Expect(hookNamespace).To(Equal(envNamespace))
+
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, testJob)).To(Succeed())
+			Expect(testJob.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "STEER_RELEASE_NAMESPACE", Value: envNamespace}))
+
+			By("Tearing the environment down once the run passed")
+			testJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, testJob)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(installed).To(HaveLen(1))
+			Expect(uninstalled).To(Equal([]helm.UninstallRequest{{ReleaseName: "example-release", Namespace: envNamespace}}))
+			Expect(cleaned).To(Equal([]string{envNamespace}))
+			Expect(cleanedOpts).To(Equal(cleanup.Options{DeleteNamespace: true}))
+			run = latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(run.Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(run.Status.Environment.UninstalledAt).NotTo(BeNil())
+
+			By("Deleting the namespace before the HelmTestJob goes away")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uninstalled).To(HaveLen(1))
+			expectNamespaceDeleted(envNamespace)
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
+		})
+
+		It("should not use or delete a namespace it didn't create", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Environment = steerv1alpha1.EnvironmentEphemeral
+			resource.Spec.Cleanup = &steerv1alpha1.HelmTestJobCleanupSpec{When: steerv1alpha1.CleanupWhenAlways}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			existing := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: environmentNamespace(resource, "example-release", "once")}}
+			Expect(k8sClient.Create(ctx, existing)).To(Succeed())
+			defer removeNamespace(existing.Name)
+
+			var installs, cleanups int
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+				Helm: &helm.FakeClient{
+					InstallOrUpgradeFunc: func(ctx context.Context, req helm.InstallOrUpgradeRequest) (helm.ReleaseInfo, error) {
+						installs++
+						return helm.ReleaseInfo{}, nil
+					},
+				},
+				Cleanup: &cleanup.FakeRunner{
+					CleanupNamespaceFunc: func(ctx context.Context, namespace string, opts cleanup.Options) error {
+						cleanups++
+						return nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Message).To(ContainSubstring("already exists and was not created for this run"))
+			Expect(run.Status.Cleanup).NotTo(BeNil())
+			Expect(run.Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Cleanup.Message).To(ContainSubstring("refusing to delete namespace"))
+			Expect(installs).To(BeZero())
+			Expect(cleanups).To(BeZero())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: existing.Name}, &corev1.Namespace{})).To(Succeed())
+		})
+
+		It("should fail ephemeral runs without a Helm client", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Environment = steerv1alpha1.EnvironmentEphemeral
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Message).To(ContainSubstring(errNoHelm.Error()))
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: environmentNamespace(resource, "example-release", "once")}, &corev1.Namespace{}))).To(BeTrue())
+		})
+
+		It("should uninstall an environment whose install failed", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Environment = steerv1alpha1.EnvironmentEphemeral
+			resource.Spec.Cleanup = &steerv1alpha1.HelmTestJobCleanupSpec{When: steerv1alpha1.CleanupWhenAlways}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			envNamespace := environmentNamespace(resource, "example-release", "once")
+
+			var uninstalled []helm.UninstallRequest
+			var cleaned []string
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+				Helm: &helm.FakeClient{
+					InstallOrUpgradeFunc: func(ctx context.Context, req helm.InstallOrUpgradeRequest) (helm.ReleaseInfo, error) {
+						return helm.ReleaseInfo{}, fmt.Errorf("timed out waiting for the condition")
+					},
+					UninstallFunc: func(ctx context.Context, req helm.UninstallRequest) error {
+						uninstalled = append(uninstalled, req)
+						return nil
+					},
+				},
+				Cleanup: &cleanup.FakeRunner{
+					CleanupNamespaceFunc: func(ctx context.Context, namespace string, opts cleanup.Options) error {
+						cleaned = append(cleaned, namespace)
+						return nil
+					},
+				},
+			}
+			Eventually(func() steerv1alpha1.HelmTestJobPhase {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				return latestRun().Status.Phase
+			}).Should(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+
+			run := latestRun()
+			Expect(run.Status.Message).To(ContainSubstring("timed out waiting for the condition"))
+			Expect(run.Status.Environment.InstalledAt).To(BeNil())
+			Expect(run.Status.Environment.UninstalledAt).NotTo(BeNil())
+			Expect(run.Status.Cleanup.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(uninstalled).To(Equal([]helm.UninstallRequest{{ReleaseName: "example-release", Namespace: envNamespace}}))
+			Expect(cleaned).To(Equal([]string{envNamespace}))
+		})
+
+		It("should tear down a kept environment before pruning its run", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			keep := int32(0)
+			resource.Spec.SuccessfulRunsHistoryLimit = &keep
+			resource.Spec.Schedule = steerv1alpha1.ScheduleSpec{Type: steerv1alpha1.ScheduleTypeOnRelease}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			createRun(resource, "old", steerv1alpha1.HelmTestJobPhaseSucceeded)
+			old := &steerv1alpha1.HelmTestRun{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: runName(resourceName, "old"), Namespace: "default"}, old)).To(Succeed())
+			installedAt := metav1.Now()
+			envNamespace := environmentNamespace(resource, "example-release", "old")
+			old.Status.Environment = &steerv1alpha1.HelmTestRunEnvironmentStatus{Namespace: envNamespace, ReleaseName: "example-release", InstalledAt: &installedAt}
+			// spec.cleanup.when kept the environment.
+			old.Status.Cleanup = &steerv1alpha1.HelmTestRunCleanupStatus{Namespace: envNamespace, Phase: steerv1alpha1.HelmTestJobPhaseSkipped}
+			Expect(k8sClient.Status().Update(ctx, old)).To(Succeed())
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: envNamespace, Labels: environmentLabels(resource, "old")}})).To(Succeed())
+
+			var uninstalled []helm.UninstallRequest
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+				Helm: &helm.FakeClient{
+					UninstallFunc: func(ctx context.Context, req helm.UninstallRequest) error {
+						uninstalled = append(uninstalled, req)
+						return nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uninstalled).To(Equal([]helm.UninstallRequest{{ReleaseName: "example-release", Namespace: envNamespace}}))
+			expectNamespaceDeleted(envNamespace)
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(old), &steerv1alpha1.HelmTestRun{}))).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,813 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/config"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+var _ = Describe("HelmTestJob Controller", func() {
+	Context("When running hooks", func() {
+		withHelmTestJob()
+
+		It("should run pre-test hooks through the hook executor", func() {
+			By("Adding a pre-test hook to the resource")
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "check", Type: steerv1alpha1.HookTypeScript, Script: "true"}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			var requests []hooks.ExecuteRequest
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						requests = append(requests, req)
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}, nil
+					},
+				},
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].Stage).To(Equal(hooks.StagePreTest))
+			Expect(requests[0].Index).To(Equal(0))
+			Expect(requests[0].RunKey).To(Equal("once"))
+			Expect(requests[0].Hook.Name).To(Equal("check"))
+
+			run := latestRun()
+			Expect(run.Spec.RunKey).To(Equal("once"))
+			Expect(run.Spec.Trigger).To(Equal(steerv1alpha1.HelmTestRunTriggerSchedule))
+			Expect(run.Status.CurrentStage).NotTo(Equal(steerv1alpha1.HelmTestJobStagePreTest))
+			Expect(run.Status.HookResults).NotTo(BeNil())
+			Expect(run.Status.HookResults.PreTest).To(HaveLen(1))
+			Expect(run.Status.HookResults.PreTest[0].Name).To(Equal("check"))
+			Expect(run.Status.HookResults.PreTest[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+		})
+
+		It("should fail the job when a pre-test hook fails", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "check", Type: steerv1alpha1.HookTypeScript, Script: "false"}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						exitCode := int32(1)
+						return hooks.Result{
+							Name:       req.Hook.Name,
+							Stage:      req.Stage,
+							Phase:      steerv1alpha1.HelmTestJobPhaseFailed,
+							ObjectName: "check-job",
+							PodName:    "check-pod",
+							Message:    "exit 1",
+							ExitCode:   &exitCode,
+							Logs:       "boom\n",
+						}, nil
+					},
+				},
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(updated.Status.Message).To(ContainSubstring("exit 1"))
+			Expect(updated.Status.CompletionTime).NotTo(BeNil())
+
+			By("Recording the hook outcome in the run")
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.TestResults).To(HaveLen(1))
+			Expect(run.Status.TestResults[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSkipped))
+			Expect(run.Status.HookResults).NotTo(BeNil())
+			Expect(run.Status.HookResults.PreTest).To(HaveLen(1))
+			hookResult := run.Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(hookResult.JobName).To(Equal("check-job"))
+			Expect(hookResult.PodName).To(Equal("check-pod"))
+			Expect(hookResult.ExitCode).To(HaveValue(Equal(int32(1))))
+			Expect(hookResult.Logs).To(Equal("boom\n"))
+		})
+
+		It("should apply hook run policies after a failure", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{
+				{Name: "optional", Type: steerv1alpha1.HookTypeScript, Script: "false", ContinueOnError: true},
+				{Name: "check", Type: steerv1alpha1.HookTypeScript, Script: "false"},
+			}
+			resource.Spec.Hooks.PostTest = []steerv1alpha1.Hook{
+				{Name: "notify", Type: steerv1alpha1.HookTypeScript, Script: "true"},
+				{Name: "teardown", Type: steerv1alpha1.HookTypeScript, Script: "true", RunPolicy: steerv1alpha1.HookRunPolicyAlways},
+				{Name: "report-failure", Type: steerv1alpha1.HookTypeScript, Script: "true", RunPolicy: steerv1alpha1.HookRunPolicyOnFailure},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			var executed []string
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						executed = append(executed, req.Hook.Name)
+						phase := steerv1alpha1.HelmTestJobPhaseSucceeded
+						if req.Stage == hooks.StagePreTest {
+							phase = steerv1alpha1.HelmTestJobPhaseFailed
+						}
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: phase}, nil
+					},
+				},
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(executed).To(Equal([]string{"optional", "check", "teardown", "report-failure"}))
+
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(updated.Status.Message).To(ContainSubstring(`"check"`))
+			run := latestRun()
+			Expect(run.Status.HookResults.PostTest).To(HaveLen(3))
+			Expect(run.Status.HookResults.PostTest[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSkipped))
+			Expect(run.Status.HookResults.PostTest[1].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(run.Status.HookResults.PostTest[2].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+		})
+
+		It("should run hooks of a parallel group together", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{
+				{Name: "seed-db", Type: steerv1alpha1.HookTypeScript, Script: "true", ParallelGroup: "setup"},
+				{Name: "warm-cache", Type: steerv1alpha1.HookTypeScript, Script: "true", ParallelGroup: "setup"},
+				{Name: "smoke", Type: steerv1alpha1.HookTypeScript, Script: "true"},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			var executed []string
+			seedPhase := steerv1alpha1.HelmTestJobPhaseRunning
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						executed = append(executed, req.Hook.Name)
+						phase := steerv1alpha1.HelmTestJobPhaseSucceeded
+						if req.Hook.Name == "seed-db" {
+							phase = seedPhase
+						}
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: phase}, nil
+					},
+				},
+			}
+
+			By("Starting every hook of the group in one reconcile")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(executed).To(Equal([]string{"seed-db", "warm-cache"}))
+			run := latestRun()
+			Expect(run.Status.CurrentStage).To(Equal(steerv1alpha1.HelmTestJobStagePreTest))
+			Expect(run.Status.CurrentIndex).To(Equal(int32(0)))
+			Expect(run.Status.HookResults.PreTest[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			Expect(run.Status.HookResults.PreTest[1].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+
+			By("Moving on once the last hook of the group fails")
+			executed = nil
+			seedPhase = steerv1alpha1.HelmTestJobPhaseFailed
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(executed).To(Equal([]string{"seed-db"}))
+			run = latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Message).To(ContainSubstring(`"seed-db"`))
+			Expect(run.Status.HookResults.PreTest[1].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(run.Status.HookResults.PreTest[2].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSkipped))
+		})
+
+		It("should pass hook outputs to later hooks and the test", func() {
+			outputRef := func(hook, key string) *steerv1alpha1.HookEnvVarSource {
+				return &steerv1alpha1.HookEnvVarSource{HookOutput: &steerv1alpha1.HookEnvVarHookOutputSource{Hook: hook, Key: key}}
+			}
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{
+				{Name: "login", Type: steerv1alpha1.HookTypeScript, Script: `echo "token=abc" > "$STEER_OUTPUT"`},
+				{Name: "seed", Type: steerv1alpha1.HookTypeScript, Script: "true", Env: []steerv1alpha1.HookEnvVar{
+					{Name: "TOKEN", ValueFrom: outputRef("login", "token")},
+				}},
+			}
+			resource.Spec.Test.Env = []steerv1alpha1.HookEnvVar{
+				{Name: "TOKEN", ValueFrom: outputRef("login", "token")},
+				{Name: "MISSING", ValueFrom: outputRef("login", "nope")},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			var seedOutputs hooks.Outputs
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						res := hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}
+						if req.Hook.Name == "login" {
+							res.Outputs = hooks.ParseOutputs("token=abc\n")
+						} else {
+							seedOutputs = req.Outputs
+						}
+						return res, nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(seedOutputs.Value("login", "token")).To(Equal("abc"))
+			run := latestRun()
+			Expect(run.Status.HookResults.PreTest[0].OutputKeys).To(Equal([]string{"token"}))
+
+			By("Keeping the values out of the run status")
+			status, err := json.Marshal(run.Status)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(status)).NotTo(ContainSubstring("abc"))
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: run.Name + "-outputs", Namespace: "default"}, secret)).To(Succeed())
+			Expect(secret.Data).To(Equal(map[string][]byte{"preTest-0.token": []byte("abc")}))
+			Expect(metav1.IsControlledBy(secret, run)).To(BeTrue())
+
+			By("Passing them to the test by reference")
+			optional := true
+			outputRefEnv := func(name, key string) corev1.EnvVar {
+				return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: run.Name + "-outputs"},
+					Key:                  key,
+					Optional:             &optional,
+				}}}
+			}
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, testJob)).To(Succeed())
+			Expect(testJob.Spec.Template.Spec.Containers[0].Env).To(ConsistOf(
+				outputRefEnv("TOKEN", "preTest-0.token"),
+				outputRefEnv("MISSING", "preTest-0.nope"),
+			))
+		})
+
+		It("should probe http hooks from the controller until they pass", func() {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Host != "web.default.svc" || r.URL.Path != "/"+resourceName+"/healthz" || r.Header.Get("X-Token") != "abc" || calls.Add(1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				_, _ = w.Write([]byte(`{"status":"ok"}`))
+			}))
+			defer server.Close()
+
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}},
+			}
+			Expect(k8sClient.Create(ctx, svc)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, svc)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+				Name: "healthz",
+				Type: steerv1alpha1.HookTypeHTTP,
+				HTTP: &steerv1alpha1.HTTPHookSpec{
+					URL:       "http://web.{{ .Release.metadata.namespace }}.svc.cluster.local/{{ .Job.metadata.name }}/healthz",
+					Headers:   []steerv1alpha1.HTTPHeader{{Name: "X-Token", Value: "abc"}},
+					BodyRegex: `"status":\s*"ok"`,
+					Retries:   1,
+					Interval:  metav1.Duration{Duration: time.Millisecond},
+				},
+			}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			// The Service name doesn't resolve here; send its requests to the
+			// test server instead.
+			executor := hooks.NewJobExecutor(k8sClient, k8sClient.Scheme(), nil)
+			executor.HTTPClient = &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+				},
+			}}
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  executor,
+			}
+			By("Retrying after an unexpected status")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			run := latestRun()
+			hookResult := run.Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			Expect(hookResult.Attempts).To(Equal(int32(1)))
+			Expect(hookResult.Message).To(ContainSubstring("unexpected status 503"))
+
+			By("Passing once the endpoint answers as expected")
+			time.Sleep(5 * time.Millisecond)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			run = latestRun()
+			hookResult = run.Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(hookResult.Attempts).To(Equal(int32(2)))
+			Expect(hookResult.Logs).To(BeEmpty())
+			Expect(run.Status.CurrentStage).To(Equal(steerv1alpha1.HelmTestJobStageTest))
+		})
+
+		It("should wait for objects to match a jsonPath", func() {
+			marker := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "seed-status", Namespace: "default", Labels: map[string]string{"app": "seed"}},
+				Data:       map[string]string{"ready": "false"},
+			}
+			Expect(k8sClient.Create(ctx, marker)).To(Succeed())
+			defer func() { _ = k8sClient.Delete(ctx, marker) }()
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+				Name: "seeded",
+				Type: steerv1alpha1.HookTypeWaitFor,
+				WaitFor: &steerv1alpha1.WaitForHookSpec{
+					Kind:      "ConfigMap",
+					Namespace: "default",
+					Selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"app": "seed"}},
+					JSONPath:  "{.data.ready}",
+					Value:     "true",
+					Interval:  metav1.Duration{Duration: time.Millisecond},
+				},
+			}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			hookResult := latestRun().Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			Expect(hookResult.Message).To(ContainSubstring("{.data.ready}: condition not met"))
+			Expect(hookResult.Message).NotTo(ContainSubstring("false"))
+
+			By("Passing once the object is in the expected state")
+			marker.Data["ready"] = "true"
+			Expect(k8sClient.Update(ctx, marker)).To(Succeed())
+			time.Sleep(5 * time.Millisecond)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			hookResult = latestRun().Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			Expect(hookResult.Attempts).To(Equal(int32(2)))
+		})
+
+		It("should scale a deployment to zero and restore it when the run is cancelled", func() {
+			targetNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "chaos-target"}}
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, targetNamespace))).To(Succeed())
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "chaos-target"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+			labels := map[string]string{"app": "web"}
+			deploy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "chaos-target"},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: ptrInt32(2),
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx:1.25"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deploy)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, deploy)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+				Name: "kill-web",
+				Type: steerv1alpha1.HookTypeChaos,
+				Chaos: &steerv1alpha1.ChaosHookSpec{
+					Action:     steerv1alpha1.ChaosActionScaleToZero,
+					Deployment: "web",
+					Duration:   metav1.Duration{Duration: time.Hour},
+				},
+			}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: &config.Config{AllowedTargetNamespaces: []string{"chaos-target"}},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deploy), deploy)).To(Succeed())
+			Expect(*deploy.Spec.Replicas).To(Equal(int32(0)))
+			disruption := latestRun().Status.HookResults.PreTest[0].Disruption
+			Expect(disruption).NotTo(BeNil())
+			Expect(disruption.Namespace).To(Equal("chaos-target"))
+			Expect(disruption.Targets).To(Equal([]string{"web"}))
+			Expect(disruption.OriginalReplicas).To(HaveValue(Equal(int32(2))))
+
+			By("Restoring the deployment right away when the run is cancelled")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Annotations = map[string]string{steerv1alpha1.AnnotationCancelRequestedAt: time.Now().UTC().Format(time.RFC3339)}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deploy), deploy)).To(Succeed())
+			Expect(*deploy.Spec.Replicas).To(Equal(int32(2)))
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseCancelled))
+			hookResult := run.Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSkipped))
+			Expect(hookResult.Disruption.RestoredAt).NotTo(BeNil())
+		})
+
+		It("should keep a disruption when restoring it fails and retry", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Environment = steerv1alpha1.EnvironmentShared
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+				Name:  "kill-web",
+				Type:  steerv1alpha1.HookTypeChaos,
+				Chaos: &steerv1alpha1.ChaosHookSpec{Action: steerv1alpha1.ChaosActionScaleToZero, Deployment: "web"},
+			}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "default"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			disruptedAt := metav1.Now()
+			disruption := &steerv1alpha1.HookDisruption{
+				Action:           steerv1alpha1.ChaosActionScaleToZero,
+				Namespace:        "default",
+				Targets:          []string{"web"},
+				OriginalReplicas: ptrInt32(2),
+				DisruptedAt:      &disruptedAt,
+			}
+			calls := 0
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						calls++
+						if calls == 1 {
+							return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseRunning, Disruption: disruption}, nil
+						}
+						return hooks.Result{}, fmt.Errorf("the apiserver is unavailable")
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Keeping the disruption when the hook errors")
+			res, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.RequeueAfter).NotTo(BeZero())
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			hookResult := run.Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			Expect(hookResult.Message).To(ContainSubstring("the apiserver is unavailable"))
+			Expect(hookResult.Disruption).NotTo(BeNil())
+			Expect(hookResult.Disruption.RestoredAt).To(BeNil())
+
+			By("Retrying the restore after the run was cancelled")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Annotations = map[string]string{steerv1alpha1.AnnotationCancelRequestedAt: time.Now().UTC().Format(time.RFC3339)}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(calls).To(Equal(3))
+			hookResult = latestRun().Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			Expect(hookResult.Disruption.DisruptedAt).NotTo(BeNil())
+		})
+
+		It("should restore a scaled deployment when its run is replaced or its HelmTestJob deleted", func() {
+			targetNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "chaos-target"}}
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, targetNamespace))).To(Succeed())
+			labels := map[string]string{"app": "web"}
+			deploy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "chaos-target"},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: ptrInt32(0),
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx:1.25"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deploy)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, deploy)).To(Succeed()) }()
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "chaos-target"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+			// disrupt records a run that left the Deployment scaled to zero.
+			disrupt := func(runKey string, phase steerv1alpha1.HelmTestJobPhase) {
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deploy), deploy)).To(Succeed())
+				deploy.Spec.Replicas = ptrInt32(0)
+				Expect(k8sClient.Update(ctx, deploy)).To(Succeed())
+				run := &steerv1alpha1.HelmTestRun{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: runName(resourceName, runKey), Namespace: "default"}, run)).To(Succeed())
+				disruptedAt := metav1.Now()
+				run.Status.Phase = phase
+				run.Status.HookResults = &steerv1alpha1.HookResults{PreTest: []steerv1alpha1.HookResult{{
+					Name:  "kill-web",
+					Phase: steerv1alpha1.HelmTestJobPhaseRunning,
+					Disruption: &steerv1alpha1.HookDisruption{
+						Action:           steerv1alpha1.ChaosActionScaleToZero,
+						Namespace:        "chaos-target",
+						Targets:          []string{"web"},
+						OriginalReplicas: ptrInt32(2),
+						DisruptedAt:      &disruptedAt,
+					},
+				}}}
+				Expect(k8sClient.Status().Update(ctx, run)).To(Succeed())
+			}
+			replicas := func() int32 {
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deploy), deploy)).To(Succeed())
+				return *deploy.Spec.Replicas
+			}
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Schedule.Type = steerv1alpha1.ScheduleTypeCron
+			resource.Spec.Schedule.Cron = "* * * * *"
+			resource.Spec.ConcurrencyPolicy = steerv1alpha1.ConcurrencyPolicyReplace
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+				Name:  "kill-web",
+				Type:  steerv1alpha1.HookTypeChaos,
+				Chaos: &steerv1alpha1.ChaosHookSpec{Action: steerv1alpha1.ChaosActionScaleToZero, Deployment: "web"},
+			}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			resource.Status.NextScheduleTime = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+			createRun(resource, "r200", steerv1alpha1.HelmTestJobPhaseRunning)
+			disrupt("r200", steerv1alpha1.HelmTestJobPhaseRunning)
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: &config.Config{AllowedTargetNamespaces: []string{"chaos-target"}},
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseRunning}, nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Restoring the deployment of the replaced run")
+			replaced := &steerv1alpha1.HelmTestRun{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: runName(resourceName, "r200"), Namespace: "default"}, replaced)).To(Succeed())
+			Expect(replaced.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(replaced.Status.Message).To(ContainSubstring("replaced by run"))
+			Expect(replaced.Status.HookResults.PreTest[0].Disruption.RestoredAt).NotTo(BeNil())
+			Expect(replicas()).To(Equal(int32(2)))
+
+			By("Restoring the deployment before the HelmTestJob goes away")
+			disrupt("r200", steerv1alpha1.HelmTestJobPhaseFailed)
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(ContainElement(helmTestJobFinalizer))
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicas()).To(Equal(int32(2)))
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
+		})
+
+		It("should restore a scaled deployment before its run is pruned", func() {
+			labels := map[string]string{"app": "api"}
+			deploy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: ptrInt32(0),
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "api", Image: "nginx:1.25"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deploy)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, deploy)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			for _, runKey := range []string{"r100", "r200"} {
+				createRun(resource, runKey, steerv1alpha1.HelmTestJobPhaseFailed)
+			}
+			run := &steerv1alpha1.HelmTestRun{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: runName(resourceName, "r100"), Namespace: "default"}, run)).To(Succeed())
+			disruptedAt := metav1.Now()
+			run.Status.HookResults = &steerv1alpha1.HookResults{PreTest: []steerv1alpha1.HookResult{{
+				Name:  "kill-api",
+				Phase: steerv1alpha1.HelmTestJobPhaseFailed,
+				Disruption: &steerv1alpha1.HookDisruption{
+					Action:           steerv1alpha1.ChaosActionScaleToZero,
+					Namespace:        "default",
+					Targets:          []string{"api"},
+					OriginalReplicas: ptrInt32(2),
+					DisruptedAt:      &disruptedAt,
+				},
+			}}}
+			Expect(k8sClient.Status().Update(ctx, run)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			runs, err := controllerReconciler.listRuns(ctx, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(controllerReconciler.pruneRuns(ctx, resource, runs)).To(Succeed())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: runName(resourceName, "r100"), Namespace: "default"}, run)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deploy), deploy)).To(Succeed())
+			Expect(*deploy.Spec.Replicas).To(Equal(int32(2)))
+		})
+
+		It("should refuse chaos in a namespace the operator config doesn't allow", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "kube-system"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+				Name:  "kill-dns",
+				Type:  steerv1alpha1.HookTypeChaos,
+				Chaos: &steerv1alpha1.ChaosHookSpec{Action: steerv1alpha1.ChaosActionScaleToZero, Deployment: "coredns"},
+			}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			executed := false
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						executed = true
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}, nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(executed).To(BeFalse())
+			hookResult := latestRun().Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(hookResult.Message).To(ContainSubstring("allowedTargetNamespaces"))
+		})
+
+		It("should refuse waitFor hooks in a namespace the operator config doesn't allow", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "kube-system"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+				Name:    "dns-ready",
+				Type:    steerv1alpha1.HookTypeWaitFor,
+				WaitFor: &steerv1alpha1.WaitForHookSpec{Kind: "Pod", Condition: "Ready"},
+			}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			executed := false
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						executed = true
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}, nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(executed).To(BeFalse())
+			hookResult := latestRun().Status.HookResults.PreTest[0]
+			Expect(hookResult.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(hookResult.Message).To(ContainSubstring("allowedTargetNamespaces"))
+		})
+	})
+})
@@ -0,0 +1,104 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+// storeHookOutputs keeps the outputs of a hook in the outputs Secret of its
+// run. Outputs may be credentials, so the run status only records their
+// keys; the Secret is owned by the run and goes away with it.
+func (r *HelmTestJobReconciler) storeHookOutputs(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun, stage hooks.Stage, index int, outputs map[string]string) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: hooks.OutputsSecretName(run.Name), Namespace: run.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		if secret.ResourceVersion != "" && !metav1.IsControlledBy(secret, run) {
+			return fmt.Errorf("secret %s already exists and was not created for run %s", secret.Name, run.Name)
+		}
+		secret.Labels = hooks.RunLabels(job, run.Spec.RunKey)
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		for key, value := range outputs {
+			secret.Data[hooks.OutputKey(stage, index, key)] = []byte(value)
+		}
+		return controllerutil.SetControllerReference(run, secret, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("store hook outputs: %w", err)
+	}
+	return nil
+}
+
+// runOutputs reads the outputs of the hooks of a run that succeeded so far
+// from the outputs Secret of the run.
+func (r *HelmTestJobReconciler) runOutputs(ctx context.Context, run *steerv1alpha1.HelmTestRun, status *steerv1alpha1.HelmTestRunStatus) (hooks.Outputs, error) {
+	outputs := hooks.Outputs{}
+	if status.HookResults == nil {
+		return outputs, nil
+	}
+	var secret *corev1.Secret
+	for _, stage := range []struct {
+		stage   hooks.Stage
+		results []steerv1alpha1.HookResult
+	}{
+		{hooks.StagePreTest, status.HookResults.PreTest},
+		{hooks.StagePostTest, status.HookResults.PostTest},
+	} {
+		for i, hr := range stage.results {
+			if hr.Phase != steerv1alpha1.HelmTestJobPhaseSucceeded || len(hr.OutputKeys) == 0 {
+				continue
+			}
+			if secret == nil {
+				secret = &corev1.Secret{}
+				key := types.NamespacedName{Name: hooks.OutputsSecretName(run.Name), Namespace: run.Namespace}
+				if err := r.Get(ctx, key, secret); err != nil {
+					return nil, fmt.Errorf("get hook outputs: %w", err)
+				}
+			}
+			values := map[string]string{}
+			for _, key := range hr.OutputKeys {
+				values[key] = string(secret.Data[hooks.OutputKey(stage.stage, i, key)])
+			}
+			outputs[hr.Name] = values
+		}
+	}
+	return outputs, nil
+}
+
+// outputKeys returns the keys of the outputs of a hook, sorted.
+func outputKeys(outputs map[string]string) []string {
+	if len(outputs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(outputs))
+	for key := range outputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/config"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+// ensureRunRBAC creates the ServiceAccount, Role and RoleBinding of a run.
+// The ServiceAccount lives next to the run's pods, the Role and RoleBinding
+// in the release's target namespace or the run's ephemeral environment. A
+// target namespace other than the job's must be allowed by the operator
+// config. The returned status is set as soon as the target namespace is known, so a
+// partial setup can still be torn down.
+func (r *HelmTestJobReconciler) ensureRunRBAC(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun) (*steerv1alpha1.HelmTestRunRBACStatus, error) {
+	target := environmentNamespaceOf(&run.Status)
+	if target == "" {
+		var err error
+		if target, err = r.releaseTargetNamespace(ctx, job); err != nil {
+			return nil, err
+		}
+		if err := r.Config.CheckTargetNamespace(job.Namespace, target); err != nil {
+			return nil, err
+		}
+	}
+	name := runName(job.Name, run.Spec.RunKey)
+	status := &steerv1alpha1.HelmTestRunRBACStatus{ServiceAccountName: name, Namespace: target}
+	labels := hooks.RunLabels(job, run.Spec.RunKey)
+	rules := job.Spec.RBAC.Rules
+	if len(rules) == 0 {
+		rules = config.DefaultRBACRules
+	}
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: job.Namespace, Labels: labels}}
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: target, Labels: labels},
+		Rules:      rules,
+	}
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: target, Labels: labels},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: job.Namespace}},
+	}
+	for _, obj := range []client.Object{sa, role, binding} {
+		// Owner references cannot cross namespaces; those objects are
+		// deleted by teardownRunRBAC.
+		if obj.GetNamespace() == job.Namespace {
+			if err := controllerutil.SetControllerReference(job, obj, r.Scheme); err != nil {
+				return status, err
+			}
+		}
+		if err := r.Create(ctx, obj); err != nil && !errors.IsAlreadyExists(err) {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// teardownRunRBAC deletes the objects created by ensureRunRBAC.
+func (r *HelmTestJobReconciler) teardownRunRBAC(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun) error {
+	status := run.Status.RBAC
+	if status == nil {
+		return nil
+	}
+	objs := []client.Object{
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: status.ServiceAccountName, Namespace: status.Namespace}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: status.ServiceAccountName, Namespace: status.Namespace}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: status.ServiceAccountName, Namespace: job.Namespace}},
+	}
+	for _, obj := range objs {
+		if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseTargetNamespace returns the namespace the referenced HelmRelease
+// deploys into.
+func (r *HelmTestJobReconciler) releaseTargetNamespace(ctx context.Context, job *steerv1alpha1.HelmTestJob) (string, error) {
+	hr, err := r.releaseOf(ctx, job)
+	if err != nil {
+		return "", err
+	}
+	return releaseNamespace(hr), nil
+}
+
+// releaseOf returns the HelmRelease a HelmTestJob references, if the
+// operator config allows its namespace.
+func (r *HelmTestJobReconciler) releaseOf(ctx context.Context, job *steerv1alpha1.HelmTestJob) (*steerv1alpha1.HelmRelease, error) {
+	ref := job.Spec.HelmReleaseRef
+	if ref.Namespace == "" {
+		ref.Namespace = job.Namespace
+	}
+	if err := r.Config.CheckTargetNamespace(job.Namespace, ref.Namespace); err != nil {
+		return nil, fmt.Errorf("HelmRelease %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	var hr steerv1alpha1.HelmRelease
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, &hr); err != nil {
+		return nil, fmt.Errorf("get HelmRelease %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	return &hr, nil
+}
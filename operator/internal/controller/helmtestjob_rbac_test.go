@@ -0,0 +1,149 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/config"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+var _ = Describe("HelmTestJob Controller", func() {
+	Context("When granting the run RBAC", func() {
+		withHelmTestJob()
+
+		It("should give the run its own ServiceAccount bound in the target namespace", func() {
+			targetNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "rbac-target"}}
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, targetNamespace))).To(Succeed())
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "rbac-target"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.RBAC = &steerv1alpha1.HelmTestJobRBACSpec{
+				Rules: []rbacv1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}},
+			}
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "check", Type: steerv1alpha1.HookTypeScript, Script: "kubectl get deployment"}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			var hookServiceAccount string
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: &config.Config{AllowedTargetNamespaces: []string{"rbac-target"}},
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						hookServiceAccount = req.ServiceAccountName
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}, nil
+					},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			name := runName(resourceName, "once")
+			Expect(latestRun().Status.RBAC).To(Equal(&steerv1alpha1.HelmTestRunRBACStatus{ServiceAccountName: name, Namespace: "rbac-target"}))
+			Expect(hookServiceAccount).To(Equal(name))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, &corev1.ServiceAccount{})).To(Succeed())
+			role := &rbacv1.Role{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "rbac-target"}, role)).To(Succeed())
+			Expect(role.Rules[0].Resources).To(Equal([]string{"deployments"}))
+			binding := &rbacv1.RoleBinding{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "rbac-target"}, binding)).To(Succeed())
+			Expect(binding.Subjects).To(ConsistOf(rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: "default"}))
+
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, "once"), Namespace: "default"}, testJob)).To(Succeed())
+			Expect(testJob.Spec.Template.Spec.ServiceAccountName).To(Equal(name))
+
+			By("Finishing the test Job")
+			testJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, testJob)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latestRun().Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+
+			By("Ensuring the RBAC objects were torn down")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "rbac-target"}, &rbacv1.Role{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "rbac-target"}, &rbacv1.RoleBinding{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, &corev1.ServiceAccount{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should refuse to grant RBAC in a namespace the operator config doesn't allow", func() {
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "kube-system"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.RBAC = &steerv1alpha1.HelmTestJobRBACSpec{}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Message).To(ContainSubstring("allowedTargetNamespaces"))
+			Expect(run.Status.RBAC).To(BeNil())
+			name := runName(resourceName, "once")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "kube-system"}, &rbacv1.Role{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,176 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+	"github.com/MrLYC/steer/operator/pkg/logsink"
+	"github.com/MrLYC/steer/operator/pkg/report"
+)
+
+var _ = Describe("HelmTestJob Controller", func() {
+	Context("When collecting test results", func() {
+		withHelmTestJob()
+
+		It("should store JUnit and JSON reports of a finished run", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "check", Type: steerv1alpha1.HookTypeScript, Script: "false"}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			sink := &logsink.FakeSink{}
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseFailed, Message: "exit 1", Logs: "boom\n"}, nil
+					},
+				},
+				LogSink: sink,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(run.Status.Reports).To(Equal(&steerv1alpha1.HelmTestRunReports{
+				JUnit: report.Key("default", resourceName, "once", report.FormatJUnit),
+				JSON:  report.Key("default", resourceName, "once", report.FormatJSON),
+			}))
+			junit := string(sink.Objects[run.Status.Reports.JUnit])
+			Expect(junit).To(ContainSubstring(`<testsuite name="preTest" tests="1" failures="1" skipped="0"`))
+			Expect(junit).To(ContainSubstring(`<failure message="exit 1">boom`))
+			Expect(junit).To(ContainSubstring(`<testsuite name="test" tests="1" failures="0" skipped="1"`))
+
+			var rep report.Report
+			Expect(json.Unmarshal(sink.Objects[run.Status.Reports.JSON], &rep)).To(Succeed())
+			Expect(rep.Phase).To(Equal("Failed"))
+			Expect(rep.Tests).To(Equal(2))
+			Expect(rep.Failures).To(Equal(1))
+			Expect(rep.Suites).To(HaveLen(3))
+		})
+
+		It("should store the full test log in the log sink", func() {
+			sink := &logsink.FakeSink{}
+			controllerReconciler := &HelmTestJobReconciler{
+				Client:    k8sClient,
+				Scheme:    k8sClient.Scheme(),
+				Hooks:     &hooks.FakeExecutor{},
+				Clientset: kubefake.NewSimpleClientset(),
+				LogSink:   sink,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			jobName := jobNameForTest(resourceName, "once")
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:      jobName + "-abcde",
+				Namespace: "default",
+				Labels:    map[string]string{batchv1.JobNameLabel: jobName},
+			}}
+			pod.Spec.Containers = []corev1.Container{{Name: "test", Image: "busybox:1.36"}}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			defer func() { _ = k8sClient.Delete(ctx, pod) }()
+
+			By("Finishing the test Job")
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobName, Namespace: "default"}, testJob)).To(Succeed())
+			testJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, testJob)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			result := latestRun().Status.TestResults[0]
+			Expect(result.LogRef).To(Equal(logsink.Key("default", resourceName, "once", "test", jobName)))
+			Expect(result.Logs).To(Equal("fake logs"))
+			Expect(sink.Objects).To(HaveKeyWithValue(result.LogRef, []byte("fake logs")))
+		})
+
+		It("should parse the test output into test cases", func() {
+			jobName := jobNameForTest(resourceName, "once")
+			key := logsink.Key("default", resourceName, "once", "test", jobName)
+			sink := &presetSink{FakeSink: &logsink.FakeSink{Objects: map[string][]byte{
+				key: []byte("TAP version 13\nok 1 - install\nnot ok 2 - upgrade\nok 3 - rollback # SKIP no history\n"),
+			}}}
+			controllerReconciler := &HelmTestJobReconciler{
+				Client:    k8sClient,
+				Scheme:    k8sClient.Scheme(),
+				Hooks:     &hooks.FakeExecutor{},
+				Clientset: kubefake.NewSimpleClientset(),
+				LogSink:   sink,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:        jobName + "-abcde",
+				Namespace:   "default",
+				Labels:      map[string]string{batchv1.JobNameLabel: jobName},
+				Annotations: map[string]string{steerv1alpha1.AnnotationResultFormat: "tap"},
+			}}
+			pod.Spec.Containers = []corev1.Container{{Name: "test", Image: "busybox:1.36"}}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			defer func() { _ = k8sClient.Delete(ctx, pod) }()
+
+			By("Finishing the test Job successfully")
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobName, Namespace: "default"}, testJob)).To(Succeed())
+			testJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, testJob)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			result := latestRun().Status.TestResults[0]
+			Expect(result.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(result.Message).To(Equal("1 of 3 test cases failed"))
+			Expect(result.Counts).To(Equal(&steerv1alpha1.TestCaseCounts{Total: 3, Passed: 1, Failed: 1, Skipped: 1}))
+			Expect(result.Cases).To(HaveLen(3))
+			Expect(result.Cases[2]).To(Equal(steerv1alpha1.TestCaseResult{Name: "rollback", Phase: steerv1alpha1.HelmTestJobPhaseSkipped, Message: "no history"}))
+
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(updated.Status.TestCounts).To(Equal(result.Counts))
+		})
+	})
+})
+
+// presetSink serves preset logs and ignores writes, standing in for test
+// output the fake clientset can't produce.
+type presetSink struct {
+	*logsink.FakeSink
+}
+
+func (s *presetSink) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	return nil
+}
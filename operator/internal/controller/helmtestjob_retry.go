@@ -0,0 +1,245 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+const (
+	// Env vars of test retries.
+	testAttemptEnvVar = "STEER_TEST_ATTEMPT"
+	failedTestsEnvVar = "STEER_FAILED_TESTS"
+
+	// podTestName names a test without parsed cases in status.flakiness.
+	podTestName = "test"
+	// flakinessWindow is the number of recent runs the flakiness score
+	// averages over.
+	flakinessWindow = 20
+	// maxFlakinessEntries bounds status.flakiness; the least flaky tests are
+	// dropped first.
+	maxFlakinessEntries = 100
+)
+
+// testAttemptOf returns the attempt of the test of a run.
+func testAttemptOf(previous *steerv1alpha1.TestResult) int32 {
+	if previous == nil || previous.Attempts == 0 {
+		return 1
+	}
+	return previous.Attempts
+}
+
+// testJobName returns the Job of an attempt of a test; the first attempt
+// keeps the plain name.
+func testJobName(name string, attempt int32) string {
+	if attempt <= 1 {
+		return name
+	}
+	return hooks.ShortName(fmt.Sprintf("%s-retry%d", name, attempt-1))
+}
+
+// shouldRetryTest reports whether a finished attempt failed and may be
+// retried.
+func shouldRetryTest(job *steerv1alpha1.HelmTestJob, result steerv1alpha1.TestResult) bool {
+	return result.Phase == steerv1alpha1.HelmTestJobPhaseFailed && testAttemptOf(&result) <= job.Spec.Test.Retries
+}
+
+// nextTestAttempt records a failed attempt and resets the result for the
+// next one. Test cases are kept, the retry merges into them.
+func nextTestAttempt(result steerv1alpha1.TestResult) steerv1alpha1.TestResult {
+	attempt := testAttemptOf(&result)
+	return steerv1alpha1.TestResult{
+		Name:     result.Name,
+		Phase:    steerv1alpha1.HelmTestJobPhasePending,
+		Message:  fmt.Sprintf("retrying after attempt %d failed: %s", attempt, result.Message),
+		Counts:   result.Counts,
+		Cases:    result.Cases,
+		Attempts: attempt + 1,
+		PreviousAttempts: append(result.PreviousAttempts, steerv1alpha1.TestAttempt{
+			JobName:     result.JobName,
+			PodName:     result.PodName,
+			Message:     result.Message,
+			LogRef:      result.LogRef,
+			CompletedAt: result.CompletedAt,
+		}),
+	}
+}
+
+// retryEnv tells a retry which attempt it is and which test cases failed.
+func retryEnv(previous *steerv1alpha1.TestResult) []corev1.EnvVar {
+	attempt := testAttemptOf(previous)
+	if attempt <= 1 {
+		return nil
+	}
+	var failed []string
+	for _, tc := range previous.Cases {
+		if tc.Phase == steerv1alpha1.HelmTestJobPhaseFailed {
+			failed = append(failed, tc.Name)
+		}
+	}
+	return []corev1.EnvVar{
+		{Name: testAttemptEnvVar, Value: strconv.Itoa(int(attempt))},
+		{Name: failedTestsEnvVar, Value: strings.Join(failed, "\n")},
+	}
+}
+
+// carryCases starts the result of an attempt with the cases of the earlier
+// attempts.
+func carryCases(result *steerv1alpha1.TestResult, previous *steerv1alpha1.TestResult) {
+	if previous == nil {
+		return
+	}
+	result.Cases, result.Counts = previous.Cases, previous.Counts
+}
+
+// mergeCases merges the cases of an attempt into the ones of the earlier
+// attempts. A case replaces the earlier one of the same suite and name; a
+// case that failed before counts one more attempt.
+func mergeCases(earlier []steerv1alpha1.TestCaseResult, earlierCounts *steerv1alpha1.TestCaseCounts, cases []steerv1alpha1.TestCaseResult, counts steerv1alpha1.TestCaseCounts) ([]steerv1alpha1.TestCaseResult, steerv1alpha1.TestCaseCounts) {
+	if earlierCounts == nil {
+		return cases, counts
+	}
+	merged := append([]steerv1alpha1.TestCaseResult{}, earlier...)
+	total := *earlierCounts
+	index := make(map[string]int, len(merged))
+	for i, tc := range merged {
+		index[caseKey(tc)] = i
+	}
+	for _, tc := range cases {
+		i, ok := index[caseKey(tc)]
+		if !ok {
+			countCase(&total, tc.Phase, 1)
+			if len(merged) < maxTestCases {
+				merged = append(merged, tc)
+			}
+			continue
+		}
+		old := merged[i]
+		tc.Attempts = old.Attempts
+		if old.Phase == steerv1alpha1.HelmTestJobPhaseFailed {
+			tc.Attempts = max(old.Attempts, 1) + 1
+		}
+		countCase(&total, old.Phase, -1)
+		countCase(&total, tc.Phase, 1)
+		merged[i] = tc
+	}
+	return merged, total
+}
+
+func countCase(counts *steerv1alpha1.TestCaseCounts, phase steerv1alpha1.HelmTestJobPhase, delta int32) {
+	counts.Total += delta
+	switch phase {
+	case steerv1alpha1.HelmTestJobPhaseSucceeded:
+		counts.Passed += delta
+	case steerv1alpha1.HelmTestJobPhaseFailed:
+		counts.Failed += delta
+	default:
+		counts.Skipped += delta
+	}
+}
+
+func caseKey(tc steerv1alpha1.TestCaseResult) string {
+	if tc.Suite == "" {
+		return tc.Name
+	}
+	return tc.Suite + "/" + tc.Name
+}
+
+// recordRunsFlakiness counts the finished runs that were not counted yet.
+// The counted runs are kept in the job status next to the scores, so a run
+// is neither lost nor counted twice when saving the status fails.
+func recordRunsFlakiness(status *steerv1alpha1.HelmTestJobStatus, runs []*steerv1alpha1.HelmTestRun) {
+	recorded := make(map[string]bool, len(status.FlakinessRuns))
+	for _, name := range status.FlakinessRuns {
+		recorded[name] = true
+	}
+	var counted []string
+	for _, run := range runs {
+		if !run.Status.Phase.IsFinished() {
+			continue
+		}
+		if !recorded[run.Name] {
+			recordFlakiness(status, run)
+		}
+		counted = append(counted, run.Name)
+	}
+	status.FlakinessRuns = counted
+}
+
+// recordFlakiness updates the flakiness scores of a job with a finished run.
+// A test is flaky in a run when it only passed after a retry.
+func recordFlakiness(status *steerv1alpha1.HelmTestJobStatus, run *steerv1alpha1.HelmTestRun) {
+	observed := map[string]bool{}
+	for _, tr := range run.Status.TestResults {
+		if tr.Phase != steerv1alpha1.HelmTestJobPhaseSucceeded && tr.Phase != steerv1alpha1.HelmTestJobPhaseFailed {
+			continue
+		}
+		if len(tr.Cases) == 0 {
+			observed[podTestName] = tr.Phase == steerv1alpha1.HelmTestJobPhaseSucceeded && tr.Attempts > 1
+			continue
+		}
+		for _, tc := range tr.Cases {
+			if tc.Phase == steerv1alpha1.HelmTestJobPhaseSkipped {
+				continue
+			}
+			observed[caseKey(tc)] = tc.Phase == steerv1alpha1.HelmTestJobPhaseSucceeded && tc.Attempts > 1
+		}
+	}
+	if len(observed) == 0 {
+		return
+	}
+
+	index := make(map[string]int, len(status.Flakiness))
+	for i, f := range status.Flakiness {
+		index[f.Name] = i
+	}
+	for name, flaky := range observed {
+		i, ok := index[name]
+		if !ok {
+			status.Flakiness = append(status.Flakiness, steerv1alpha1.TestFlakiness{Name: name})
+			i = len(status.Flakiness) - 1
+		}
+		f := &status.Flakiness[i]
+		f.Runs++
+		sample := int32(0)
+		if flaky {
+			f.FlakyRuns++
+			sample = 100
+		}
+		// Moving average over the recent runs.
+		n := min(f.Runs, flakinessWindow)
+		f.Score = (f.Score*(n-1) + sample) / n
+	}
+	sort.SliceStable(status.Flakiness, func(i, j int) bool {
+		a, b := status.Flakiness[i], status.Flakiness[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Name < b.Name
+	})
+	if len(status.Flakiness) > maxFlakinessEntries {
+		status.Flakiness = status.Flakiness[:maxFlakinessEntries]
+	}
+}
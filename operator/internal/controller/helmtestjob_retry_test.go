@@ -0,0 +1,261 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+var _ = Describe("HelmTestJob test retries", func() {
+	const (
+		succeeded = steerv1alpha1.HelmTestJobPhaseSucceeded
+		failed    = steerv1alpha1.HelmTestJobPhaseFailed
+		skipped   = steerv1alpha1.HelmTestJobPhaseSkipped
+	)
+	testCase := func(name string, phase steerv1alpha1.HelmTestJobPhase, attempts int32) steerv1alpha1.TestCaseResult {
+		return steerv1alpha1.TestCaseResult{Name: name, Suite: "pkg", Phase: phase, Attempts: attempts}
+	}
+	// runOf is a finished run whose test reported cases.
+	runOf := func(cases ...steerv1alpha1.TestCaseResult) *steerv1alpha1.HelmTestRun {
+		run := &steerv1alpha1.HelmTestRun{}
+		run.Status.TestResults = []steerv1alpha1.TestResult{{Name: "main", Phase: succeeded, Cases: cases}}
+		return run
+	}
+	// podRun is a finished run whose test reported no cases.
+	podRun := func(phase steerv1alpha1.HelmTestJobPhase, attempts int32) *steerv1alpha1.HelmTestRun {
+		run := &steerv1alpha1.HelmTestRun{}
+		run.Status.TestResults = []steerv1alpha1.TestResult{{Name: "main", Phase: phase, Attempts: attempts}}
+		return run
+	}
+	repeat := func(n int, run *steerv1alpha1.HelmTestRun) []*steerv1alpha1.HelmTestRun {
+		runs := make([]*steerv1alpha1.HelmTestRun, n)
+		for i := range runs {
+			runs[i] = run
+		}
+		return runs
+	}
+
+	DescribeTable("scores flaky tests across runs",
+		func(runs []*steerv1alpha1.HelmTestRun, want []steerv1alpha1.TestFlakiness) {
+			status := &steerv1alpha1.HelmTestJobStatus{}
+			for _, run := range runs {
+				recordFlakiness(status, run)
+			}
+			Expect(status.Flakiness).To(Equal(want))
+		},
+		Entry("a case that passed after a retry",
+			[]*steerv1alpha1.HelmTestRun{runOf(testCase("TestA", succeeded, 2), testCase("TestB", succeeded, 0))},
+			[]steerv1alpha1.TestFlakiness{
+				{Name: "pkg/TestA", Runs: 1, FlakyRuns: 1, Score: 100},
+				{Name: "pkg/TestB", Runs: 1},
+			}),
+		Entry("a moving average over the runs",
+			[]*steerv1alpha1.HelmTestRun{
+				runOf(testCase("TestA", succeeded, 2)),
+				runOf(testCase("TestA", succeeded, 0)),
+				// Failing on every attempt is not flaky.
+				runOf(testCase("TestA", failed, 3)),
+			},
+			[]steerv1alpha1.TestFlakiness{{Name: "pkg/TestA", Runs: 3, FlakyRuns: 1, Score: 33}}),
+		Entry("only the recent runs",
+			append(repeat(25, runOf(testCase("TestA", succeeded, 0))), runOf(testCase("TestA", succeeded, 2))),
+			[]steerv1alpha1.TestFlakiness{{Name: "pkg/TestA", Runs: 26, FlakyRuns: 1, Score: 100 / flakinessWindow}}),
+		Entry("skipped cases",
+			[]*steerv1alpha1.HelmTestRun{runOf(testCase("TestA", skipped, 2))},
+			nil),
+		Entry("a test without cases that passed after a retry",
+			[]*steerv1alpha1.HelmTestRun{podRun(succeeded, 2), podRun(succeeded, 1)},
+			[]steerv1alpha1.TestFlakiness{{Name: podTestName, Runs: 2, FlakyRuns: 1, Score: 50}}),
+		Entry("unfinished and cancelled tests",
+			[]*steerv1alpha1.HelmTestRun{podRun(steerv1alpha1.HelmTestJobPhaseRunning, 2), podRun(steerv1alpha1.HelmTestJobPhaseCancelled, 2)},
+			nil),
+	)
+
+	It("should count each finished run once", func() {
+		run := runOf(testCase("TestA", succeeded, 2))
+		run.Name, run.Status.Phase = "r1", succeeded
+		active := runOf(testCase("TestA", succeeded, 2))
+		active.Name, active.Status.Phase = "r2", steerv1alpha1.HelmTestJobPhaseRunning
+		status := &steerv1alpha1.HelmTestJobStatus{}
+		recordRunsFlakiness(status, []*steerv1alpha1.HelmTestRun{run, active})
+		saved := status.DeepCopy()
+
+		By("Counting it again only if the status wasn't saved")
+		recordRunsFlakiness(saved, []*steerv1alpha1.HelmTestRun{run, active})
+		Expect(saved.Flakiness).To(Equal([]steerv1alpha1.TestFlakiness{{Name: "pkg/TestA", Runs: 1, FlakyRuns: 1, Score: 100}}))
+		Expect(saved.FlakinessRuns).To(Equal([]string{"r1"}))
+		lost := &steerv1alpha1.HelmTestJobStatus{}
+		recordRunsFlakiness(lost, []*steerv1alpha1.HelmTestRun{run})
+		Expect(lost.Flakiness).To(Equal(saved.Flakiness))
+
+		By("Forgetting pruned runs")
+		recordRunsFlakiness(saved, nil)
+		Expect(saved.FlakinessRuns).To(BeEmpty())
+		Expect(saved.Flakiness).To(HaveLen(1))
+	})
+
+	It("should keep the flakiest tests", func() {
+		var cases []steerv1alpha1.TestCaseResult
+		for i := 0; i < maxFlakinessEntries+5; i++ {
+			cases = append(cases, testCase(fmt.Sprintf("Test%03d", i), succeeded, 0))
+		}
+		cases[len(cases)-1].Attempts = 2
+		status := &steerv1alpha1.HelmTestJobStatus{}
+		recordFlakiness(status, runOf(cases...))
+
+		Expect(status.Flakiness).To(HaveLen(maxFlakinessEntries))
+		Expect(status.Flakiness[0].Name).To(Equal(fmt.Sprintf("pkg/Test%03d", maxFlakinessEntries+4)))
+		Expect(status.Flakiness[1].Name).To(Equal("pkg/Test000"))
+	})
+
+	DescribeTable("merges the cases of a retry",
+		func(earlier []steerv1alpha1.TestCaseResult, retry []steerv1alpha1.TestCaseResult, wantAttempts map[string]int32, wantCounts steerv1alpha1.TestCaseCounts) {
+			earlierCounts := &steerv1alpha1.TestCaseCounts{}
+			for _, tc := range earlier {
+				countCase(earlierCounts, tc.Phase, 1)
+			}
+			retryCounts := steerv1alpha1.TestCaseCounts{}
+			for _, tc := range retry {
+				countCase(&retryCounts, tc.Phase, 1)
+			}
+			merged, counts := mergeCases(earlier, earlierCounts, retry, retryCounts)
+			attempts := map[string]int32{}
+			for _, tc := range merged {
+				attempts[caseKey(tc)+" "+string(tc.Phase)] = tc.Attempts
+			}
+			Expect(attempts).To(Equal(wantAttempts))
+			Expect(counts).To(Equal(wantCounts))
+		},
+		Entry("a failed case that passes",
+			[]steerv1alpha1.TestCaseResult{testCase("TestA", failed, 0), testCase("TestB", succeeded, 0)},
+			[]steerv1alpha1.TestCaseResult{testCase("TestA", succeeded, 0)},
+			map[string]int32{"pkg/TestA Succeeded": 2, "pkg/TestB Succeeded": 0},
+			steerv1alpha1.TestCaseCounts{Total: 2, Passed: 2}),
+		Entry("a case failing again",
+			[]steerv1alpha1.TestCaseResult{testCase("TestA", failed, 2)},
+			[]steerv1alpha1.TestCaseResult{testCase("TestA", failed, 0)},
+			map[string]int32{"pkg/TestA Failed": 3},
+			steerv1alpha1.TestCaseCounts{Total: 1, Failed: 1}),
+		Entry("a new case in the retry",
+			[]steerv1alpha1.TestCaseResult{testCase("TestA", failed, 0)},
+			[]steerv1alpha1.TestCaseResult{testCase("TestA", succeeded, 0), testCase("TestC", skipped, 0)},
+			map[string]int32{"pkg/TestA Succeeded": 2, "pkg/TestC Skipped": 0},
+			steerv1alpha1.TestCaseCounts{Total: 2, Passed: 1, Skipped: 1}),
+	)
+
+	It("should retry failed tests up to spec.test.retries times", func() {
+		job := &steerv1alpha1.HelmTestJob{}
+		job.Spec.Test.Retries = 2
+		result := steerv1alpha1.TestResult{
+			Name: "main", Phase: failed, Message: "exit 1", JobName: "job-main",
+			Cases: []steerv1alpha1.TestCaseResult{testCase("TestA", failed, 0), testCase("TestB", succeeded, 0)},
+		}
+		Expect(shouldRetryTest(job, result)).To(BeTrue())
+
+		next := nextTestAttempt(result)
+		Expect(next.Attempts).To(Equal(int32(2)))
+		Expect(next.Phase).To(Equal(steerv1alpha1.HelmTestJobPhasePending))
+		Expect(next.PreviousAttempts).To(HaveLen(1))
+		Expect(next.PreviousAttempts[0].JobName).To(Equal("job-main"))
+		Expect(testJobName("job-main", next.Attempts)).To(Equal("job-main-retry1"))
+		Expect(retryEnv(&next)).To(Equal([]corev1.EnvVar{
+			{Name: testAttemptEnvVar, Value: "2"},
+			{Name: failedTestsEnvVar, Value: "TestA"},
+		}))
+
+		next.Phase = failed
+		Expect(shouldRetryTest(job, next)).To(BeTrue())
+		last := nextTestAttempt(next)
+		last.Phase = failed
+		Expect(last.Attempts).To(Equal(int32(3)))
+		Expect(shouldRetryTest(job, last)).To(BeFalse())
+
+		next.Phase = succeeded
+		Expect(shouldRetryTest(job, next)).To(BeFalse())
+	})
+
+	It("should keep retry Job names within 63 characters", func() {
+		name := testJobName(strings.Repeat("a", 62)+"-", 3)
+		Expect(len(name)).To(BeNumerically("<=", 63))
+		Expect(name).NotTo(Equal(testJobName(strings.Repeat("a", 62)+"-", 2)))
+		Expect(testJobName("job-main", 1)).To(Equal("job-main"))
+	})
+})
+
+var _ = Describe("HelmTestJob Controller", func() {
+	Context("When retrying a failed test", func() {
+		withHelmTestJob()
+
+		It("should retry a failed test and flag it as flaky", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Test.Retries = 1
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Failing the first attempt")
+			jobName := jobNameForTest(resourceName, "once")
+			testJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobName, Namespace: "default"}, testJob)).To(Succeed())
+			testJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "exit 1"}}
+			Expect(k8sClient.Status().Update(ctx, testJob)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			result := run.Status.TestResults[0]
+			Expect(result.Name).To(Equal(jobName))
+			Expect(result.Attempts).To(Equal(int32(2)))
+			Expect(result.JobName).To(Equal(jobName + "-retry1"))
+			Expect(result.PreviousAttempts).To(HaveLen(1))
+			Expect(result.PreviousAttempts[0].JobName).To(Equal(jobName))
+
+			By("Passing the retry")
+			retryJob := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobName + "-retry1", Namespace: "default"}, retryJob)).To(Succeed())
+			Expect(retryJob.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "STEER_TEST_ATTEMPT", Value: "2"}))
+			retryJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, retryJob)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(latestRun().Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Flakiness).To(Equal([]steerv1alpha1.TestFlakiness{{Name: "test", Runs: 1, FlakyRuns: 1, Score: 100}}))
+		})
+	})
+})
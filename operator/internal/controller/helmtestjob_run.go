@@ -0,0 +1,783 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+	"github.com/MrLYC/steer/operator/pkg/report"
+)
+
+const (
+	defaultSuccessfulRunsHistoryLimit int32 = 3
+	defaultFailedRunsHistoryLimit     int32 = 1
+)
+
+// reconcileRun advances a single active run as far as possible. It returns
+// true when the run is waiting on a child Job and should be polled again.
+func (r *HelmTestJobReconciler) reconcileRun(ctx context.Context, job *steerv1alpha1.HelmTestJob, testRun *steerv1alpha1.HelmTestRun, image string, now time.Time) bool {
+	logger := log.FromContext(ctx).WithValues("run", testRun.Name)
+	nowMeta := metav1.NewTime(now)
+	runKey := testRun.Spec.RunKey
+	run := &testRun.Status
+
+	if run.Phase != steerv1alpha1.HelmTestJobPhaseRunning {
+		run.Phase = steerv1alpha1.HelmTestJobPhaseRunning
+		run.StartTime = &nowMeta
+		run.CompletionTime = nil
+		run.Message = ""
+		run.CurrentStage = steerv1alpha1.HelmTestJobStagePreTest
+		run.CurrentIndex = 0
+		run.HookResults = newHookResults(job.Spec.Hooks)
+		run.TestResults = nil
+	}
+
+	if image == "" {
+		finishRun(run, steerv1alpha1.HelmTestJobPhaseFailed, "missing test image: set spec.test.image or defaultImages.test in the operator config", now)
+		return false
+	}
+	if err := r.checkPolicy(job, image); err != nil {
+		finishRun(run, steerv1alpha1.HelmTestJobPhaseFailed, err.Error(), now)
+		return false
+	}
+
+	if timeout := runTimeoutOf(job); timeout > 0 && stopReason(run) == "" && run.StartTime != nil && now.Sub(run.StartTime.Time) >= timeout {
+		if err := r.stopRunJobs(ctx, job, testRun); err != nil {
+			logger.Error(err, "failed to stop timed out run")
+			return true
+		}
+		logger.Info("run timed out", "runTimeout", timeout)
+		run.TimedOutAt = &nowMeta
+	}
+
+	if job.Spec.Environment == steerv1alpha1.EnvironmentEphemeral && (run.Environment == nil || run.Environment.InstalledAt == nil) {
+		installed, err := r.installEnvironment(ctx, job, testRun, now)
+		if err != nil {
+			logger.Error(err, "failed to install ephemeral environment")
+			finishRun(run, steerv1alpha1.HelmTestJobPhaseFailed, fmt.Sprintf("failed to install ephemeral environment: %v", err), now)
+			return false
+		}
+		if !installed {
+			run.Message = fmt.Sprintf("installing ephemeral environment in namespace %s", run.Environment.Namespace)
+			return true
+		}
+		run.Message = ""
+	}
+
+	if job.Spec.RBAC != nil && run.RBAC == nil {
+		status, err := r.ensureRunRBAC(ctx, job, testRun)
+		run.RBAC = status
+		if err != nil {
+			logger.Error(err, "failed to set up run rbac")
+			finishRun(run, steerv1alpha1.HelmTestJobPhaseFailed, fmt.Sprintf("failed to set up rbac: %v", err), now)
+			return false
+		}
+	}
+	serviceAccountName := ""
+	if run.RBAC != nil {
+		serviceAccountName = run.RBAC.ServiceAccountName
+	}
+
+	// State machine: execute one stage/hook at a time.
+	// We allow fast transitions (e.g., no hooks, skipped hooks) in a single reconcile.
+	maxSteps := len(job.Spec.Hooks.PreTest) + len(job.Spec.Hooks.PostTest) + 4
+	for step := 0; step < maxSteps; step++ {
+		switch run.CurrentStage {
+		case steerv1alpha1.HelmTestJobStagePreTest, steerv1alpha1.HelmTestJobStagePostTest:
+			stage, specHooks := hooks.StagePreTest, job.Spec.Hooks.PreTest
+			if run.CurrentStage == steerv1alpha1.HelmTestJobStagePostTest {
+				stage, specHooks = hooks.StagePostTest, job.Spec.Hooks.PostTest
+			}
+			idx := int(run.CurrentIndex)
+			if idx >= len(specHooks) {
+				if stage == hooks.StagePreTest {
+					run.CurrentStage = steerv1alpha1.HelmTestJobStageTest
+					run.CurrentIndex = 0
+					continue
+				}
+				// All post-test hooks are done: the run is complete.
+				if run.CancelledAt != nil {
+					finishRun(run, steerv1alpha1.HelmTestJobPhaseCancelled, fmt.Sprintf("cancelled by %s", run.CancelledBy), now)
+				} else if run.TimedOutAt != nil {
+					finishRun(run, steerv1alpha1.HelmTestJobPhaseFailed, fmt.Sprintf("run exceeded runTimeout of %s", runTimeoutOf(job)), now)
+				} else if failure := runFailure(job.Spec.Hooks, run); failure != "" {
+					finishRun(run, steerv1alpha1.HelmTestJobPhaseFailed, failure, now)
+				} else {
+					finishRun(run, steerv1alpha1.HelmTestJobPhaseSucceeded, "", now)
+				}
+				return false
+			}
+
+			// Hooks of a parallel group are started together and the stage
+			// only advances once all of them have finished.
+			end := groupEnd(specHooks, idx)
+			before := statusBefore(run, stage, idx, end)
+			failed := runFailure(job.Spec.Hooks, before) != ""
+			outputs, err := r.runOutputs(ctx, testRun, before)
+			if err != nil {
+				logger.Error(err, "failed to read hook outputs")
+				run.Message = err.Error()
+				return true
+			}
+			waiting, message := false, ""
+			for i := idx; i < end; i++ {
+				h := specHooks[i]
+				current := hookResultAt(run, stage, i)
+				if current.Phase.IsFinished() {
+					continue
+				}
+				// A stopped run only keeps running post-test hooks that always
+				// run, and chaos hooks until they have undone their disruption.
+				reason := stopReason(run)
+				stopped := reason != "" && (stage == hooks.StagePreTest || runPolicyOf(h) != steerv1alpha1.HookRunPolicyAlways)
+				if stopped && !restoring(current) {
+					setHookResult(run, stage, i, steerv1alpha1.HookResult{
+						Name:    h.Name,
+						Phase:   steerv1alpha1.HelmTestJobPhaseSkipped,
+						Message: reason,
+					})
+					continue
+				}
+				if !stopped && !shouldRunHook(h, failed) {
+					setHookResult(run, stage, i, steerv1alpha1.HookResult{
+						Name:    h.Name,
+						Phase:   steerv1alpha1.HelmTestJobPhaseSkipped,
+						Message: fmt.Sprintf("skipped by runPolicy %q", runPolicyOf(h)),
+					})
+					continue
+				}
+
+				var result hooks.Result
+				var err error
+				if targetsRelease(h, current) && run.Environment == nil {
+					err = r.checkHookTarget(ctx, job)
+				}
+				if err == nil {
+					result, err = r.hookExecutor().Execute(ctx, hooks.ExecuteRequest{
+						Owner:  job,
+						Stage:  stage,
+						Index:  i,
+						RunKey: runKey,
+						Image:  r.Config.HookImage(h.Type, image),
+						Hook:   h,
+
+						ReleaseNamespace:   environmentNamespaceOf(run),
+						ServiceAccountName: serviceAccountName,
+						Outputs:            outputs,
+						OutputsSecret:      hooks.OutputsSecretName(testRun.Name),
+						Status:             current,
+						Stop:               stopped,
+					})
+				}
+				if err != nil {
+					logger.Error(err, "failed to execute hook", "stage", stage, "hook", h.Name)
+					result = hooks.Result{Name: h.Name, Stage: stage, Phase: steerv1alpha1.HelmTestJobPhaseFailed, Message: err.Error(), CompletedAt: &now}
+					if restoring(current) {
+						// The disruption is kept and its restore retried.
+						result.Phase, result.CompletedAt = steerv1alpha1.HelmTestJobPhaseRunning, nil
+						result.Message = fmt.Sprintf("restoring the disruption: %v", err)
+					}
+					result.Disruption = current.Disruption
+					if current.StartedAt != nil {
+						result.StartedAt = &current.StartedAt.Time
+					}
+				}
+				if result.Phase == steerv1alpha1.HelmTestJobPhaseSucceeded && len(result.Outputs) > 0 {
+					// Later hooks may start right away and refer to the outputs.
+					if err := r.storeHookOutputs(ctx, job, testRun, stage, i, result.Outputs); err != nil {
+						logger.Error(err, "failed to store hook outputs", "stage", stage, "hook", h.Name)
+						// The outputs are harvested again from the hook pod.
+						result.Phase, result.CompletedAt = steerv1alpha1.HelmTestJobPhaseRunning, nil
+						result.Message = err.Error()
+					}
+				}
+				if stopped && !result.Phase.IsFinished() && !restoring(hookResultFrom(result)) {
+					result.Phase = steerv1alpha1.HelmTestJobPhaseSkipped
+					result.Message = fmt.Sprintf("%s: %s", reason, result.Message)
+					result.CompletedAt = &now
+				}
+				setHookResult(run, stage, i, hookResultFrom(result))
+				if !result.Phase.IsFinished() && !waiting {
+					waiting, message = true, result.Message
+				}
+			}
+			if waiting {
+				run.Message = message
+				return true
+			}
+			run.CurrentIndex = int32(end)
+			continue
+
+		case steerv1alpha1.HelmTestJobStageTest:
+			name := jobNameForTest(job.Name, runKey)
+			if reason := stopReason(run); reason != "" {
+				run.TestResults = []steerv1alpha1.TestResult{{Name: name, Phase: steerv1alpha1.HelmTestJobPhaseSkipped, Message: reason}}
+				run.CurrentStage = steerv1alpha1.HelmTestJobStagePostTest
+				run.CurrentIndex = 0
+				continue
+			}
+			if runFailure(job.Spec.Hooks, run) != "" {
+				run.TestResults = []steerv1alpha1.TestResult{{Name: name, Phase: steerv1alpha1.HelmTestJobPhaseSkipped}}
+				run.CurrentStage = steerv1alpha1.HelmTestJobStagePostTest
+				run.CurrentIndex = 0
+				continue
+			}
+			var previous *steerv1alpha1.TestResult
+			if len(run.TestResults) > 0 {
+				previous = &run.TestResults[0]
+			}
+			attempt := testAttemptOf(previous)
+			jobName := testJobName(name, attempt)
+			result, msg, err := r.ensureTestJob(ctx, job, runKey, jobName, image, serviceAccountName, hooks.OutputsSecretName(testRun.Name), previous, environmentEnv(run.Environment))
+			if err != nil {
+				logger.Error(err, "failed to run test job", "job", jobName)
+				result = steerv1alpha1.TestResult{Name: name, Phase: steerv1alpha1.HelmTestJobPhaseFailed, CompletedAt: &nowMeta}
+			}
+			// Attempts keep the name of the first one.
+			result.Name = name
+			if previous != nil {
+				result.Attempts, result.PreviousAttempts = previous.Attempts, previous.PreviousAttempts
+			}
+			if err == nil && shouldRetryTest(job, result) {
+				logger.Info("retrying failed test", "job", jobName, "attempt", attempt)
+				run.TestResults = []steerv1alpha1.TestResult{nextTestAttempt(result)}
+				continue
+			}
+			run.TestResults = []steerv1alpha1.TestResult{result}
+			if result.Phase.IsFinished() {
+				run.CurrentStage = steerv1alpha1.HelmTestJobStagePostTest
+				run.CurrentIndex = 0
+				continue
+			}
+			run.Message = msg
+			return true
+
+		default:
+			run.CurrentStage = steerv1alpha1.HelmTestJobStagePreTest
+			run.CurrentIndex = 0
+			continue
+		}
+	}
+
+	// We made progress but didn't reach a blocking Job; pick up on the next poll.
+	return true
+}
+
+// checkPolicy applies the image policy of the operator config to all images
+// a run would use, and the RBAC policy to spec.rbac.rules. The admission
+// webhook rejects most violations earlier; this covers jobs created while it
+// was disabled and default images.
+func (r *HelmTestJobReconciler) checkPolicy(job *steerv1alpha1.HelmTestJob, image string) error {
+	errs := r.Config.CheckJob(job)
+	if err := r.Config.CheckImage(image); err != nil {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "test", "image"), err.Error()))
+	}
+	for _, h := range append(append([]steerv1alpha1.Hook{}, job.Spec.Hooks.PreTest...), job.Spec.Hooks.PostTest...) {
+		if h.Type != steerv1alpha1.HookTypeScript {
+			continue
+		}
+		if err := r.Config.CheckImage(r.Config.HookImage(h.Type, image)); err != nil {
+			errs = append(errs, field.Forbidden(field.NewPath("spec", "hooks"), fmt.Sprintf("hook %q: %v", h.Name, err)))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("operator policy: %w", errs.ToAggregate())
+	}
+	return nil
+}
+
+func finishRun(run *steerv1alpha1.HelmTestRunStatus, phase steerv1alpha1.HelmTestJobPhase, message string, now time.Time) {
+	run.Phase = phase
+	run.Message = message
+	run.CompletionTime = &metav1.Time{Time: now}
+}
+
+// listRuns returns the HelmTestRuns of a job, oldest first.
+func (r *HelmTestJobReconciler) listRuns(ctx context.Context, job *steerv1alpha1.HelmTestJob) ([]*steerv1alpha1.HelmTestRun, error) {
+	var list steerv1alpha1.HelmTestRunList
+	if err := r.List(ctx, &list, client.InNamespace(job.Namespace), client.MatchingLabels{steerv1alpha1.LabelHelmTestJob: hooks.LabelValue(job.Name)}); err != nil {
+		return nil, err
+	}
+	runs := make([]*steerv1alpha1.HelmTestRun, 0, len(list.Items))
+	for i := range list.Items {
+		runs = append(runs, &list.Items[i])
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		ti, tj := runScheduledTime(runs[i]), runScheduledTime(runs[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return runs[i].Name < runs[j].Name
+	})
+	return runs, nil
+}
+
+func runScheduledTime(run *steerv1alpha1.HelmTestRun) time.Time {
+	if run.Spec.ScheduledTime != nil {
+		return run.Spec.ScheduledTime.Time
+	}
+	return run.CreationTimestamp.Time
+}
+
+// startRun creates the HelmTestRun for a new run; it is picked up by
+// reconcileRun. A run that already exists for the same key is returned
+// as is.
+func (r *HelmTestJobReconciler) startRun(ctx context.Context, job *steerv1alpha1.HelmTestJob, runKey string, trigger steerv1alpha1.HelmTestRunTrigger, scheduled time.Time) (*steerv1alpha1.HelmTestRun, error) {
+	run := &steerv1alpha1.HelmTestRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      runName(job.Name, runKey),
+			Namespace: job.Namespace,
+			Labels:    hooks.RunLabels(job, runKey),
+		},
+		Spec: steerv1alpha1.HelmTestRunSpec{
+			HelmTestJobName: job.Name,
+			RunKey:          runKey,
+			Trigger:         trigger,
+			ScheduledTime:   &metav1.Time{Time: scheduled},
+			ReleaseRevision: r.releaseRevision(ctx, job),
+		},
+	}
+	if err := controllerutil.SetControllerReference(job, run, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, run); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		// The run was started before the job status recording it was
+		// saved.
+		existing := &steerv1alpha1.HelmTestRun{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(run), existing); err != nil {
+			return nil, err
+		}
+		if existing.Spec.HelmTestJobName != job.Name || existing.Spec.RunKey != runKey {
+			return nil, fmt.Errorf("run %s already exists for run %s of HelmTestJob %s", run.Name, existing.Spec.RunKey, existing.Spec.HelmTestJobName)
+		}
+		return existing, nil
+	}
+	return run, nil
+}
+
+// admitRun applies the concurrency policy to a new run and starts it if
+// allowed. The returned note explains a run that was not started.
+func (r *HelmTestJobReconciler) admitRun(ctx context.Context, job *steerv1alpha1.HelmTestJob, runs []*steerv1alpha1.HelmTestRun, runKey string, trigger steerv1alpha1.HelmTestRunTrigger, scheduled, now time.Time) (*steerv1alpha1.HelmTestRun, string, error) {
+	if active := activeRuns(runs); len(active) > 0 {
+		switch concurrencyPolicyOf(job) {
+		case steerv1alpha1.ConcurrencyPolicyForbid:
+			note := fmt.Sprintf("skipped run %s: run %s is still active", runKey, active[0].Spec.RunKey)
+			log.FromContext(ctx).Info(note)
+			return nil, note, nil
+		case steerv1alpha1.ConcurrencyPolicyReplace:
+			if err := r.stopRuns(ctx, job, active, fmt.Sprintf("replaced by run %s", runKey), now); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	run, err := r.startRun(ctx, job, runKey, trigger, scheduled)
+	return run, "", err
+}
+
+// releaseRevision returns the current Helm revision of the job's release,
+// or 0 if it is not known yet.
+func (r *HelmTestJobReconciler) releaseRevision(ctx context.Context, job *steerv1alpha1.HelmTestJob) int64 {
+	ref := job.Spec.HelmReleaseRef
+	if ref.Namespace == "" {
+		ref.Namespace = job.Namespace
+	}
+	var hr steerv1alpha1.HelmRelease
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, &hr); err != nil {
+		return 0
+	}
+	if hr.Status.HelmRelease == nil {
+		return 0
+	}
+	return hr.Status.HelmRelease.Version
+}
+
+func runName(jobName, runKey string) string {
+	return hooks.ShortName(fmt.Sprintf("%s-%s", jobName, runKey))
+}
+
+// appendRun adds a run that was just started to runs, unless it was listed
+// already.
+func appendRun(runs []*steerv1alpha1.HelmTestRun, run *steerv1alpha1.HelmTestRun) []*steerv1alpha1.HelmTestRun {
+	if run == nil {
+		return runs
+	}
+	for _, listed := range runs {
+		if listed.Name == run.Name {
+			return runs
+		}
+	}
+	return append(runs, run)
+}
+
+func activeRuns(runs []*steerv1alpha1.HelmTestRun) []*steerv1alpha1.HelmTestRun {
+	var active []*steerv1alpha1.HelmTestRun
+	for _, run := range runs {
+		if !run.Status.Phase.IsFinished() {
+			active = append(active, run)
+		}
+	}
+	return active
+}
+
+func concurrencyPolicyOf(job *steerv1alpha1.HelmTestJob) steerv1alpha1.ConcurrencyPolicy {
+	if job.Spec.ConcurrencyPolicy == "" {
+		return steerv1alpha1.ConcurrencyPolicyForbid
+	}
+	return job.Spec.ConcurrencyPolicy
+}
+
+// stopRuns deletes the child Jobs of the given runs, restores what their
+// chaos hooks disrupted and marks them failed.
+func (r *HelmTestJobReconciler) stopRuns(ctx context.Context, job *steerv1alpha1.HelmTestJob, runs []*steerv1alpha1.HelmTestRun, message string, now time.Time) error {
+	for _, run := range runs {
+		if err := r.deleteRunJobs(ctx, job, run.Spec.RunKey, false); err != nil {
+			return err
+		}
+		if err := r.restoreDisruptions(ctx, run, now); err != nil {
+			return err
+		}
+		finishRun(&run.Status, steerv1alpha1.HelmTestJobPhaseFailed, message, now)
+		if err := r.teardownRunRBAC(ctx, job, run); err != nil {
+			return err
+		}
+		if err := r.Status().Update(ctx, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelRun stops the active child Jobs of a run and marks it cancelled.
+func (r *HelmTestJobReconciler) cancelRun(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun, by string, at time.Time) error {
+	if run.Status.CancelledAt != nil {
+		return nil
+	}
+	if err := r.stopRunJobs(ctx, job, run); err != nil {
+		return err
+	}
+	run.Status.CancelledAt = &metav1.Time{Time: at}
+	run.Status.CancelledBy = by
+	return nil
+}
+
+// stopRunJobs deletes the active child Jobs of a run that is stopped early.
+// Running post-test hooks with runPolicy always are left to finish.
+func (r *HelmTestJobReconciler) stopRunJobs(ctx context.Context, job *steerv1alpha1.HelmTestJob, run *steerv1alpha1.HelmTestRun) error {
+	var keep []string
+	post := job.Spec.Hooks.PostTest
+	if idx := int(run.Status.CurrentIndex); run.Status.CurrentStage == steerv1alpha1.HelmTestJobStagePostTest && idx < len(post) {
+		for i := idx; i < groupEnd(post, idx); i++ {
+			if runPolicyOf(post[i]) != steerv1alpha1.HookRunPolicyAlways {
+				continue
+			}
+			if name := hookResultAt(&run.Status, hooks.StagePostTest, i).JobName; name != "" {
+				keep = append(keep, name)
+			}
+		}
+	}
+	return r.deleteRunJobs(ctx, job, run.Spec.RunKey, true, keep...)
+}
+
+// restoring reports whether a chaos hook still has to undo its disruption.
+func restoring(hr steerv1alpha1.HookResult) bool {
+	d := hr.Disruption
+	return d != nil && d.Action == steerv1alpha1.ChaosActionScaleToZero && d.DisruptedAt != nil && d.RestoredAt == nil
+}
+
+// disrupted reports whether a chaos hook of a run still has to undo its
+// disruption.
+func disrupted(run *steerv1alpha1.HelmTestRun) bool {
+	if results := run.Status.HookResults; results != nil {
+		for _, hr := range append(append([]steerv1alpha1.HookResult{}, results.PreTest...), results.PostTest...) {
+			if restoring(hr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// restoreDisruptions undoes what the chaos hooks of a run disrupted, for runs
+// that ended before their hooks could, or are deleted.
+func (r *HelmTestJobReconciler) restoreDisruptions(ctx context.Context, run *steerv1alpha1.HelmTestRun, now time.Time) error {
+	results := run.Status.HookResults
+	if results == nil {
+		return nil
+	}
+	for _, stage := range [][]steerv1alpha1.HookResult{results.PreTest, results.PostTest} {
+		for i := range stage {
+			if !restoring(stage[i]) {
+				continue
+			}
+			if err := hooks.RestoreDisruption(ctx, r.Client, stage[i].Disruption, now); err != nil {
+				return fmt.Errorf("restore disruption of hook %s of run %s: %w", stage[i].Name, run.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// targetsRelease reports whether a hook is about to act on the release
+// namespace with the permissions of the operator: a chaos hook that hasn't
+// disrupted anything yet, as a disruption must always be restored, or a
+// waitFor hook.
+func targetsRelease(h steerv1alpha1.Hook, current steerv1alpha1.HookResult) bool {
+	switch h.Type {
+	case steerv1alpha1.HookTypeChaos:
+		return current.Disruption == nil
+	case steerv1alpha1.HookTypeWaitFor:
+		return true
+	}
+	return false
+}
+
+// checkHookTarget refuses hooks acting on a shared environment whose
+// namespace the operator config doesn't allow the HelmTestJob to act on.
+func (r *HelmTestJobReconciler) checkHookTarget(ctx context.Context, job *steerv1alpha1.HelmTestJob) error {
+	target, err := r.releaseTargetNamespace(ctx, job)
+	if err != nil {
+		return err
+	}
+	return r.Config.CheckTargetNamespace(job.Namespace, target)
+}
+
+// groupEnd returns the index after the step starting at idx: the end of its
+// parallel group, or idx+1 for a hook without a group.
+func groupEnd(specHooks []steerv1alpha1.Hook, idx int) int {
+	end := idx + 1
+	if group := specHooks[idx].ParallelGroup; group != "" {
+		for end < len(specHooks) && specHooks[end].ParallelGroup == group {
+			end++
+		}
+	}
+	return end
+}
+
+// statusBefore returns a copy of the run status as it was before the hooks
+// [start, end) of a stage started, so hooks of one parallel group don't
+// affect each other's runPolicy or see each other's outputs.
+func statusBefore(run *steerv1alpha1.HelmTestRunStatus, stage hooks.Stage, start, end int) *steerv1alpha1.HelmTestRunStatus {
+	before := *run
+	if run.HookResults != nil {
+		results := run.HookResults.DeepCopy()
+		stageResults := results.PreTest
+		if stage == hooks.StagePostTest {
+			stageResults = results.PostTest
+		}
+		for i := start; i < end && i < len(stageResults); i++ {
+			stageResults[i] = steerv1alpha1.HookResult{Name: stageResults[i].Name, Phase: steerv1alpha1.HelmTestJobPhasePending}
+		}
+		before.HookResults = results
+	}
+	return &before
+}
+
+// stopReason explains why a run was stopped early, or returns "" while it
+// runs normally.
+func stopReason(run *steerv1alpha1.HelmTestRunStatus) string {
+	switch {
+	case run.CancelledAt != nil:
+		return "run cancelled"
+	case run.TimedOutAt != nil:
+		return "run timed out"
+	default:
+		return ""
+	}
+}
+
+func runTimeoutOf(job *steerv1alpha1.HelmTestJob) time.Duration {
+	if job.Spec.RunTimeout == nil {
+		return 0
+	}
+	return job.Spec.RunTimeout.Duration
+}
+
+// pruneRuns deletes the oldest finished runs beyond the history limits,
+// together with their child Jobs. Active runs are never pruned.
+func (r *HelmTestJobReconciler) pruneRuns(ctx context.Context, job *steerv1alpha1.HelmTestJob, runs []*steerv1alpha1.HelmTestRun) error {
+	succeededLimit := historyLimit(job.Spec.SuccessfulRunsHistoryLimit, defaultSuccessfulRunsHistoryLimit)
+	failedLimit := historyLimit(job.Spec.FailedRunsHistoryLimit, defaultFailedRunsHistoryLimit)
+
+	var succeeded, failed int32
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		prune := false
+		switch run.Status.Phase {
+		case steerv1alpha1.HelmTestJobPhaseSucceeded:
+			succeeded++
+			prune = succeeded > succeededLimit
+		case steerv1alpha1.HelmTestJobPhaseFailed, steerv1alpha1.HelmTestJobPhaseCancelled:
+			failed++
+			prune = failed > failedLimit
+		}
+		// Runs that are still being cleaned up are pruned afterwards.
+		if !prune || cleanupPending(run) {
+			continue
+		}
+		if err := r.restoreDisruptions(ctx, run, time.Now()); err != nil {
+			return err
+		}
+		// A kept environment goes with its run.
+		if done, err := r.teardownEnvironment(ctx, job, run, time.Now()); err != nil || !done {
+			return err
+		}
+		if err := r.deleteRunJobs(ctx, job, run.Spec.RunKey, false); err != nil {
+			return err
+		}
+		if err := r.teardownRunRBAC(ctx, job, run); err != nil {
+			return err
+		}
+		r.deleteRunLogs(ctx, run)
+		if err := r.Delete(ctx, run); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRunLogs removes the logs of a pruned run from the log sink. Failures
+// are only logged; a leftover log does no harm.
+func (r *HelmTestJobReconciler) deleteRunLogs(ctx context.Context, run *steerv1alpha1.HelmTestRun) {
+	if r.LogSink == nil {
+		return
+	}
+	var refs []string
+	if reports := run.Status.Reports; reports != nil {
+		refs = append(refs, reports.JUnit, reports.JSON)
+	}
+	for _, tr := range run.Status.TestResults {
+		refs = append(refs, tr.LogRef)
+		for _, attempt := range tr.PreviousAttempts {
+			refs = append(refs, attempt.LogRef)
+		}
+	}
+	if hr := run.Status.HookResults; hr != nil {
+		for _, res := range append(append([]steerv1alpha1.HookResult{}, hr.PreTest...), hr.PostTest...) {
+			refs = append(refs, res.LogRef)
+		}
+	}
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		if err := r.LogSink.Delete(ctx, ref); err != nil {
+			log.FromContext(ctx).Error(err, "failed to delete run log", "run", run.Name, "key", ref)
+		}
+	}
+}
+
+// storeRunReports stores the JUnit and JSON reports of a finished run in the
+// log sink. Reports are best effort; the web server builds them from the
+// status when they are missing.
+func (r *HelmTestJobReconciler) storeRunReports(ctx context.Context, run *steerv1alpha1.HelmTestRun) {
+	if r.LogSink == nil {
+		return
+	}
+	rep := report.Build(run)
+	put := func(format string) string {
+		key := report.Key(run.Namespace, run.Spec.HelmTestJobName, run.Spec.RunKey, format)
+		data, err := rep.Encode(format)
+		if err == nil {
+			err = r.LogSink.Put(ctx, key, bytes.NewReader(data), int64(len(data)))
+		}
+		if err != nil {
+			log.FromContext(ctx).Error(err, "failed to store run report", "run", run.Name, "key", key)
+			return ""
+		}
+		return key
+	}
+	reports := &steerv1alpha1.HelmTestRunReports{JUnit: put(report.FormatJUnit), JSON: put(report.FormatJSON)}
+	if reports.JUnit != "" || reports.JSON != "" {
+		run.Status.Reports = reports
+	}
+}
+
+func historyLimit(limit *int32, def int32) int32 {
+	if limit == nil {
+		return def
+	}
+	return *limit
+}
+
+// deleteRunJobs deletes the Jobs created for a run, including their pods.
+// With activeOnly, Jobs that already finished are kept, as are the Jobs
+// named in keep.
+func (r *HelmTestJobReconciler) deleteRunJobs(ctx context.Context, job *steerv1alpha1.HelmTestJob, runKey string, activeOnly bool, keep ...string) error {
+	var jobs batchv1.JobList
+	if err := r.List(ctx, &jobs, client.InNamespace(job.Namespace), client.MatchingLabels(hooks.RunLabels(job, runKey))); err != nil {
+		return err
+	}
+	for i := range jobs.Items {
+		if slices.Contains(keep, jobs.Items[i].Name) {
+			continue
+		}
+		if activeOnly {
+			if phase, _ := hooks.PhaseFromJob(&jobs.Items[i]); phase.IsFinished() {
+				continue
+			}
+		}
+		if err := r.Delete(ctx, &jobs.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// summarizeLatestRun points the job status at its latest run.
+func summarizeLatestRun(status *steerv1alpha1.HelmTestJobStatus, runs []*steerv1alpha1.HelmTestRun) {
+	if len(runs) == 0 {
+		return
+	}
+	run := runs[len(runs)-1]
+	status.LastRunName = run.Name
+	status.Phase = run.Status.Phase
+	status.StartTime = run.Status.StartTime
+	status.CompletionTime = run.Status.CompletionTime
+	status.Message = run.Status.Message
+	status.TestCounts = nil
+	for _, tr := range run.Status.TestResults {
+		if tr.Counts == nil {
+			continue
+		}
+		if status.TestCounts == nil {
+			status.TestCounts = &steerv1alpha1.TestCaseCounts{}
+		}
+		status.TestCounts.Total += tr.Counts.Total
+		status.TestCounts.Passed += tr.Counts.Passed
+		status.TestCounts.Failed += tr.Counts.Failed
+		status.TestCounts.Skipped += tr.Counts.Skipped
+	}
+}
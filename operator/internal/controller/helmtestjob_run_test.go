@@ -0,0 +1,424 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+var _ = Describe("HelmTestJob Controller", func() {
+	Context("When starting and stopping runs", func() {
+		withHelmTestJob()
+
+		It("should start a manual run when one is requested", func() {
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+			}
+
+			By("Running the once schedule")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latestRun().Spec.RunKey).To(Equal("once"))
+
+			By("Requesting a run through the annotation")
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			// The once run waits on its test Job, which never finishes here.
+			resource.Spec.ConcurrencyPolicy = steerv1alpha1.ConcurrencyPolicyAllow
+			// Runs are ordered by their scheduled time, which has a resolution
+			// of a second; request the run after the once run.
+			requestedAt := latestRun().Spec.ScheduledTime.Add(time.Second).UTC().Format(time.RFC3339)
+			resource.Annotations = map[string]string{steerv1alpha1.AnnotationRunRequestedAt: requestedAt}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			for i := 0; i < 2; i++ {
+				_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			run := latestRun()
+			Expect(run.Spec.Trigger).To(Equal(steerv1alpha1.HelmTestRunTriggerManual))
+			Expect(run.Spec.RunKey).To(HavePrefix("m"))
+
+			var runs steerv1alpha1.HelmTestRunList
+			Expect(k8sClient.List(ctx, &runs, client.InNamespace("default"))).To(Succeed())
+			Expect(runs.Items).To(HaveLen(2))
+
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastRunRequest).To(Equal(requestedAt))
+		})
+
+		It("should keep the runs of a long-named HelmTestJob apart", func() {
+			long := &steerv1alpha1.HelmTestJob{
+				ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("long-job-name", 6), Namespace: "default"},
+				Spec: steerv1alpha1.HelmTestJobSpec{
+					HelmReleaseRef: steerv1alpha1.HelmReleaseRef{Name: "example-release"},
+					Schedule:       steerv1alpha1.ScheduleSpec{Type: steerv1alpha1.ScheduleTypeOnRelease},
+				},
+			}
+			Expect(k8sClient.Create(ctx, long)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, long)).To(Succeed()) }()
+			controllerReconciler := &HelmTestJobReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+
+			now := time.Now()
+			first, err := controllerReconciler.startRun(ctx, long, "m1700000000", steerv1alpha1.HelmTestRunTriggerManual, now)
+			Expect(err).NotTo(HaveOccurred())
+			second, err := controllerReconciler.startRun(ctx, long, "m1700000001", steerv1alpha1.HelmTestRunTriggerManual, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.Name).NotTo(Equal(second.Name))
+			Expect(len(first.Name)).To(BeNumerically("<=", 63))
+			Expect(jobNameForTest(long.Name, "m1700000000")).NotTo(Equal(jobNameForTest(long.Name, "m1700000001")))
+
+			var runs steerv1alpha1.HelmTestRunList
+			Expect(k8sClient.List(ctx, &runs, client.InNamespace("default"),
+				client.MatchingLabels{steerv1alpha1.LabelHelmTestJob: hooks.LabelValue(long.Name)})).To(Succeed())
+			Expect(runs.Items).To(HaveLen(2))
+
+			By("Reusing a run that was started before")
+			again, err := controllerReconciler.startRun(ctx, long, "m1700000000", steerv1alpha1.HelmTestRunTriggerManual, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again.Name).To(Equal(first.Name))
+			Expect(again.Spec.RunKey).To(Equal("m1700000000"))
+
+			By("Refusing a run name taken by another run")
+			taken := &steerv1alpha1.HelmTestRun{
+				ObjectMeta: metav1.ObjectMeta{Name: runName(long.Name, "v3"), Namespace: "default"},
+				Spec:       steerv1alpha1.HelmTestRunSpec{HelmTestJobName: "other", RunKey: "v3"},
+			}
+			Expect(k8sClient.Create(ctx, taken)).To(Succeed())
+			_, err = controllerReconciler.startRun(ctx, long, "v3", steerv1alpha1.HelmTestRunTriggerRelease, now)
+			Expect(err).To(MatchError(ContainSubstring("already exists")))
+		})
+
+		It("should keep a manual run request pending while a run is active", func() {
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+			}
+
+			By("Running the once schedule, whose test Job never finishes here")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			onceRun := latestRun()
+			Expect(onceRun.Spec.RunKey).To(Equal("once"))
+
+			By("Requesting a run under the Forbid policy")
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			requestedAt := onceRun.Spec.ScheduledTime.Add(time.Second).UTC().Format(time.RFC3339)
+			resource.Annotations = map[string]string{steerv1alpha1.AnnotationRunRequestedAt: requestedAt}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastRunRequest).To(BeEmpty())
+			Expect(updated.Status.Message).To(ContainSubstring("waits for run once to finish"))
+			Expect(latestRun().Spec.RunKey).To(Equal("once"))
+
+			By("Finishing the active run")
+			onceRun = latestRun()
+			onceRun.Status.Phase = steerv1alpha1.HelmTestJobPhaseSucceeded
+			Expect(k8sClient.Status().Update(ctx, onceRun)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := latestRun()
+			Expect(run.Spec.Trigger).To(Equal(steerv1alpha1.HelmTestRunTriggerManual))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastRunRequest).To(Equal(requestedAt))
+		})
+
+		It("should cancel the active run and still run always post-test hooks", func() {
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "slow", Type: steerv1alpha1.HookTypeScript, Script: "sleep 600"}}
+			resource.Spec.Hooks.PostTest = []steerv1alpha1.Hook{
+				{Name: "notify", Type: steerv1alpha1.HookTypeScript, Script: "true"},
+				{Name: "teardown", Type: steerv1alpha1.HookTypeScript, Script: "true", RunPolicy: steerv1alpha1.HookRunPolicyAlways},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			var executed []string
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						executed = append(executed, req.Hook.Name)
+						phase := steerv1alpha1.HelmTestJobPhaseSucceeded
+						if req.Stage == hooks.StagePreTest {
+							phase = steerv1alpha1.HelmTestJobPhaseRunning
+						}
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: phase}, nil
+					},
+				},
+			}
+
+			By("Starting the once run, which waits on the slow pre-test hook")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latestRun().Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			hookJob := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+				Name:      "slow-hook",
+				Namespace: "default",
+				Labels:    hooks.RunLabels(resource, "once"),
+			}}
+			hookJob.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+			hookJob.Spec.Template.Spec.Containers = []corev1.Container{{Name: "hook", Image: "busybox:1.36"}}
+			Expect(k8sClient.Create(ctx, hookJob)).To(Succeed())
+
+			By("Requesting cancellation through the annotations")
+			requestedAt := time.Now().UTC().Format(time.RFC3339)
+			resource.Annotations = map[string]string{
+				steerv1alpha1.AnnotationCancelRequestedAt: requestedAt,
+				steerv1alpha1.AnnotationCancelRequestedBy: "alice",
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			executed = nil
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(executed).To(Equal([]string{"teardown"}))
+
+			run := latestRun()
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseCancelled))
+			Expect(run.Status.CancelledBy).To(Equal("alice"))
+			Expect(run.Status.CancelledAt).NotTo(BeNil())
+			Expect(run.Status.HookResults.PreTest[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSkipped))
+			Expect(run.Status.TestResults[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSkipped))
+			Expect(run.Status.HookResults.PostTest[0].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSkipped))
+			Expect(run.Status.HookResults.PostTest[1].Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseSucceeded))
+
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseCancelled))
+			Expect(updated.Status.Message).To(ContainSubstring("alice"))
+			Expect(updated.Status.LastCancelRequest).To(Equal(requestedAt))
+
+			By("Ensuring the active hook Job is deleted")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "slow-hook", Namespace: "default"}, hookJob)
+			if err == nil {
+				Expect(hookJob.DeletionTimestamp).NotTo(BeNil())
+			} else {
+				Expect(errors.IsNotFound(err)).To(BeTrue())
+			}
+		})
+
+		It("should start a run for each new HelmRelease revision", func() {
+			By("Creating the referenced HelmRelease at revision 2")
+			release := &steerv1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-release", Namespace: "default"},
+				Spec: steerv1alpha1.HelmReleaseSpec{
+					Chart: steerv1alpha1.ChartSpec{
+						Source:     steerv1alpha1.ChartSourceRepository,
+						Repository: &steerv1alpha1.RepositoryChartSpec{URL: "https://example.invalid/charts", Name: "example"},
+					},
+					Deployment: steerv1alpha1.DeploymentSpec{Namespace: "default"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, release)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, release)).To(Succeed()) }()
+			release.Status.HelmRelease = &steerv1alpha1.HelmReleaseInfo{Name: "example-release", Version: 2}
+			Expect(k8sClient.Status().Update(ctx, release)).To(Succeed())
+
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Schedule.Type = steerv1alpha1.ScheduleTypeOnRelease
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks:  &hooks.FakeExecutor{},
+			}
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			run := latestRun()
+			Expect(run.Spec.RunKey).To(Equal("v2"))
+			Expect(run.Spec.Trigger).To(Equal(steerv1alpha1.HelmTestRunTriggerRelease))
+			Expect(run.Spec.ReleaseRevision).To(Equal(int64(2)))
+			var runs steerv1alpha1.HelmTestRunList
+			Expect(k8sClient.List(ctx, &runs, client.InNamespace("default"))).To(Succeed())
+			Expect(runs.Items).To(HaveLen(1))
+
+			By("Upgrading the release while the run is still active")
+			release.Status.HelmRelease.Version = 3
+			Expect(k8sClient.Status().Update(ctx, release)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastTestedRevision).To(Equal(int64(2)))
+			Expect(updated.Status.Message).To(ContainSubstring("revision 3 waits for run v2"))
+		})
+
+		It("should successfully reconcile the cron schedule resource", func() {
+			By("Updating the resource to use cron schedule")
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Schedule.Type = steerv1alpha1.ScheduleTypeCron
+			resource.Spec.Schedule.Cron = "* * * * *"
+			resource.Spec.Schedule.Timezone = "Asia/Shanghai"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.NextScheduleTime).NotTo(BeNil())
+			// Cron should schedule the next minute boundary (or later) in the specified timezone.
+			// Allow small clock skew to avoid flakiness.
+			Expect(updated.Status.NextScheduleTime.Time.After(time.Now().Add(-5 * time.Second))).To(BeTrue())
+		})
+
+		It("should skip a due cron run while another run is active", func() {
+			By("Marking a cron run as active and due again")
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Schedule.Type = steerv1alpha1.ScheduleTypeCron
+			resource.Spec.Schedule.Cron = "* * * * *"
+			resource.Spec.ConcurrencyPolicy = steerv1alpha1.ConcurrencyPolicyForbid
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "slow", Type: steerv1alpha1.HookTypeScript, Script: "sleep 600"}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			resource.Status.NextScheduleTime = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+			createRun(resource, "r100", steerv1alpha1.HelmTestJobPhaseRunning)
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseRunning}, nil
+					},
+				},
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var runs steerv1alpha1.HelmTestRunList
+			Expect(k8sClient.List(ctx, &runs, client.InNamespace("default"))).To(Succeed())
+			Expect(runs.Items).To(HaveLen(1))
+			Expect(runs.Items[0].Spec.RunKey).To(Equal("r100"))
+
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Message).To(ContainSubstring("r100 is still active"))
+			Expect(updated.Status.NextScheduleTime.Time.After(time.Now())).To(BeTrue())
+		})
+
+		It("should replace the active run and prune run history", func() {
+			By("Recording a failed and an active cron run with their Jobs")
+			resource := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Schedule.Type = steerv1alpha1.ScheduleTypeCron
+			resource.Spec.Schedule.Cron = "* * * * *"
+			resource.Spec.ConcurrencyPolicy = steerv1alpha1.ConcurrencyPolicyReplace
+			resource.Spec.FailedRunsHistoryLimit = ptrInt32(1)
+			resource.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{Name: "slow", Type: steerv1alpha1.HookTypeScript, Script: "sleep 600"}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			resource.Status.NextScheduleTime = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+			createRun(resource, "r100", steerv1alpha1.HelmTestJobPhaseFailed)
+			createRun(resource, "r200", steerv1alpha1.HelmTestJobPhaseRunning)
+
+			for _, runKey := range []string{"r100", "r200"} {
+				j := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+					Name:      jobNameForTest(resourceName, runKey),
+					Namespace: "default",
+					Labels:    hooks.RunLabels(resource, runKey),
+				}}
+				j.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+				j.Spec.Template.Spec.Containers = []corev1.Container{{Name: "test", Image: "busybox:1.36"}}
+				Expect(k8sClient.Create(ctx, j)).To(Succeed())
+			}
+
+			controllerReconciler := &HelmTestJobReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Hooks: &hooks.FakeExecutor{
+					ExecuteFunc: func(ctx context.Context, req hooks.ExecuteRequest) (hooks.Result, error) {
+						return hooks.Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseRunning}, nil
+					},
+				},
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Ensuring the replaced run failed and the oldest run was pruned")
+			replaced := &steerv1alpha1.HelmTestRun{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: runName(resourceName, "r200"), Namespace: "default"}, replaced)).To(Succeed())
+			Expect(replaced.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseFailed))
+			Expect(replaced.Status.Message).To(ContainSubstring("replaced by run"))
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: runName(resourceName, "r100"), Namespace: "default"}, &steerv1alpha1.HelmTestRun{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			run := latestRun()
+			Expect(run.Spec.RunKey).NotTo(BeElementOf("r100", "r200"))
+			Expect(run.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+			updated := &steerv1alpha1.HelmTestJob{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Phase).To(Equal(steerv1alpha1.HelmTestJobPhaseRunning))
+
+			By("Ensuring the Jobs of the stopped and pruned runs are deleted")
+			for _, runKey := range []string{"r100", "r200"} {
+				j := &batchv1.Job{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: jobNameForTest(resourceName, runKey), Namespace: "default"}, j)
+				if err == nil {
+					Expect(j.DeletionTimestamp).NotTo(BeNil())
+				} else {
+					Expect(errors.IsNotFound(err)).To(BeTrue())
+				}
+			}
+		})
+	})
+})
@@ -0,0 +1,268 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+)
+
+// podPollInterval is how often a following log stream checks whether the
+// pod of a test or hook has started.
+const podPollInterval = 2 * time.Second
+
+var (
+	errNoPod          = errors.New("no pod has started yet")
+	errResultNotFound = errors.New("result not found")
+)
+
+// handleStreamHelmTestJobLogs streams the pod logs of a test or hook of the
+// latest run, or of the run named by ?run=. stage and hook select the result
+// like for the run logs endpoint; follow=true keeps the stream open until the
+// container exits and waits for a pod that hasn't started yet.
+//
+// Clients accepting text/event-stream get server-sent events whose ids are
+// the log timestamps, so an EventSource resumes after a reconnect through
+// Last-Event-ID. Other clients get chunked plain text and resume with
+// ?sinceTime=<RFC3339 timestamp>, using timestamps=true to receive them.
+func (s *Server) handleStreamHelmTestJobLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if s.clientset == nil {
+		writeError(w, http.StatusServiceUnavailable, "log streaming is not available")
+		return
+	}
+	vars := mux.Vars(r)
+	var job steerv1alpha1.HelmTestJob
+	if err := s.k8sClient.Get(ctx, types.NamespacedName{Namespace: vars["namespace"], Name: vars["name"]}, &job); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	stage, hook := query.Get("stage"), query.Get("hook")
+	runName := query.Get("run")
+	if runName == "" {
+		runName = job.Status.LastRunName
+	}
+	if runName == "" {
+		writeError(w, http.StatusNotFound, "the job has not run yet")
+		return
+	}
+	follow, err := boolParam(query, "follow")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	timestamps, err := boolParam(query, "timestamps")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var since time.Time
+	if value := r.Header.Get("Last-Event-ID"); value != "" || query.Get("sinceTime") != "" {
+		if value == "" {
+			value = query.Get("sinceTime")
+		}
+		if since, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid sinceTime: %v", err))
+			return
+		}
+	}
+
+	namespace := job.Namespace
+	if stage == "preTest" || stage == "postTest" {
+		for _, h := range stageHooks(&job, stage) {
+			if h.Name == hook {
+				namespace = hooks.PodNamespace(&job, h)
+			}
+		}
+	}
+	pod, err := s.waitForPod(ctx, &job, runName, namespace, stage, hook, query.Get("test"), follow)
+	if err != nil {
+		switch {
+		case ctx.Err() != nil:
+		case apierrors.IsNotFound(err), errors.Is(err, errNoPod), errors.Is(err, errResultNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	container := pod.Spec.Containers[0].Name
+	if stage == "" || stage == "test" {
+		container = "test"
+	}
+	opts := &corev1.PodLogOptions{Container: container, Follow: follow, Timestamps: true}
+	if !since.IsZero() {
+		// The API only has second precision, lines up to since are dropped below.
+		opts.SinceTime = &metav1.Time{Time: since}
+	}
+	stream, err := s.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer stream.Close()
+
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	// Keep proxies from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_ = copyLogLines(w, stream, since, sse, timestamps)
+}
+
+// waitForPod returns the pod of the selected result. When following, it
+// waits until the pod has started its containers.
+func (s *Server) waitForPod(ctx context.Context, job *steerv1alpha1.HelmTestJob, runName, namespace, stage, hook, test string, follow bool) (*corev1.Pod, error) {
+	for {
+		var run steerv1alpha1.HelmTestRun
+		if err := s.k8sClient.Get(ctx, types.NamespacedName{Namespace: job.Namespace, Name: runName}, &run); err != nil {
+			return nil, err
+		}
+		finished := run.Status.CompletionTime != nil
+		result, err := findRunResult(&run.Status, stage, hook, test)
+		if err == nil {
+			pod, err := s.resultPod(ctx, namespace, result)
+			if err != nil {
+				return nil, err
+			}
+			if pod != nil && (podStarted(pod) || !follow || result.phase.IsFinished()) {
+				return pod, nil
+			}
+			finished = finished || result.phase.IsFinished()
+		} else if !follow || finished {
+			return nil, fmt.Errorf("%w: %v", errResultNotFound, err)
+		}
+		if !follow || finished {
+			return nil, errNoPod
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(podPollInterval):
+		}
+	}
+}
+
+// resultPod returns the pod of a result: the recorded one, or the newest pod
+// of its Job while the status doesn't know it yet.
+func (s *Server) resultPod(ctx context.Context, namespace string, result runResult) (*corev1.Pod, error) {
+	if result.podName != "" {
+		var pod corev1.Pod
+		if err := s.k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: result.podName}, &pod); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		return &pod, nil
+	}
+	if result.jobName == "" {
+		return nil, nil
+	}
+	var pods corev1.PodList
+	if err := s.k8sClient.List(ctx, &pods, client.InNamespace(namespace), client.MatchingLabels{batchv1.JobNameLabel: result.jobName}); err != nil {
+		return nil, err
+	}
+	return hooks.NewestPod(pods.Items), nil
+}
+
+// copyLogLines writes timestamped log lines to w, dropping lines up to since,
+// and flushes after each line.
+func copyLogLines(w io.Writer, r io.Reader, since time.Time, sse, timestamps bool) error {
+	flusher, _ := w.(http.Flusher)
+	reader := bufio.NewReader(r)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			stamp, text, _ := strings.Cut(line, " ")
+			ts, err := time.Parse(time.RFC3339Nano, stamp)
+			if err != nil {
+				stamp, text = "", line
+			}
+			if err == nil && !since.IsZero() && !ts.After(since) {
+				continue
+			}
+			switch {
+			case sse && stamp != "":
+				_, err = fmt.Fprintf(w, "id: %s\ndata: %s\n\n", stamp, text)
+			case sse:
+				_, err = fmt.Fprintf(w, "data: %s\n\n", text)
+			case timestamps:
+				_, err = fmt.Fprintln(w, line)
+			default:
+				_, err = fmt.Fprintln(w, text)
+			}
+			if err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	if sse {
+		// Tell EventSource clients not to reconnect to a finished stream.
+		_, err := io.WriteString(w, "event: end\ndata:\n\n")
+		return err
+	}
+	return nil
+}
+
+func stageHooks(job *steerv1alpha1.HelmTestJob, stage string) []steerv1alpha1.Hook {
+	if stage == "preTest" {
+		return job.Spec.Hooks.PreTest
+	}
+	return job.Spec.Hooks.PostTest
+}
+
+func podStarted(pod *corev1.Pod) bool {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Running != nil || cs.State.Terminated != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func boolParam(query url.Values, key string) (bool, error) {
+	if query.Get(key) == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(query.Get(key))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return value, nil
+}
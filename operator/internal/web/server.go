@@ -5,28 +5,45 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/hooks"
+	"github.com/MrLYC/steer/operator/pkg/logsink"
+	"github.com/MrLYC/steer/operator/pkg/report"
 )
 
 type Server struct {
 	addr      string
 	staticDir string
 	k8sClient client.Client
+	// clientset streams pod logs; may be nil, then log streaming is disabled.
+	clientset kubernetes.Interface
+	// logSink serves full logs; may be nil, then only the tails in the run
+	// status are served.
+	logSink logsink.Sink
+
+	// TrustedProxies are the networks of the reverse proxies whose
+	// X-Forwarded-User header names the user of a request. Without them
+	// actions are recorded as done by "web".
+	TrustedProxies []netip.Prefix
 }
 
-func NewServer(addr string, staticDir string, k8sClient client.Client) *Server {
-	return &Server{addr: addr, staticDir: staticDir, k8sClient: k8sClient}
+func NewServer(addr string, staticDir string, k8sClient client.Client, clientset kubernetes.Interface, logSink logsink.Sink) *Server {
+	return &Server{addr: addr, staticDir: staticDir, k8sClient: k8sClient, clientset: clientset, logSink: logSink}
 }
 
 func (s *Server) Start(ctx context.Context) error {
@@ -50,6 +67,12 @@ func (s *Server) Start(ctx context.Context) error {
 	api.HandleFunc("/helmtestjobs", s.handleCreateHelmTestJob).Methods(http.MethodPost, http.MethodOptions)
 	api.HandleFunc("/helmtestjobs/{namespace}/{name}", s.handleGetHelmTestJob).Methods(http.MethodGet, http.MethodOptions)
 	api.HandleFunc("/helmtestjobs/{namespace}/{name}", s.handleDeleteHelmTestJob).Methods(http.MethodDelete, http.MethodOptions)
+	api.HandleFunc("/helmtestjobs/{namespace}/{name}/runs", s.handleListHelmTestRuns).Methods(http.MethodGet, http.MethodOptions)
+	api.HandleFunc("/helmtestjobs/{namespace}/{name}/logs", s.handleStreamHelmTestJobLogs).Methods(http.MethodGet, http.MethodOptions)
+	api.HandleFunc("/helmtestjobs/{namespace}/{name}/runs/{run}/logs", s.handleGetHelmTestRunLogs).Methods(http.MethodGet, http.MethodOptions)
+	api.HandleFunc("/helmtestjobs/{namespace}/{name}/report", s.handleGetHelmTestJobReport).Methods(http.MethodGet, http.MethodOptions)
+	api.HandleFunc("/helmtestjobs/{namespace}/{name}/run", s.handleRunHelmTestJob).Methods(http.MethodPost, http.MethodOptions)
+	api.HandleFunc("/helmtestjobs/{namespace}/{name}/cancel", s.handleCancelHelmTestJob).Methods(http.MethodPost, http.MethodOptions)
 
 	// Static UI: keep it as a fallback, so API routes win.
 	if s.staticDir != "" {
@@ -271,3 +294,312 @@ func (s *Server) handleDeleteHelmTestJob(w http.ResponseWriter, r *http.Request)
 	}
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
+
+// handleListHelmTestRuns lists the runs of a HelmTestJob, newest first.
+func (s *Server) handleListHelmTestRuns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	nn := types.NamespacedName{Namespace: vars["namespace"], Name: vars["name"]}
+	var job steerv1alpha1.HelmTestJob
+	if err := s.k8sClient.Get(ctx, nn, &job); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var list steerv1alpha1.HelmTestRunList
+	if err := s.k8sClient.List(ctx, &list, client.InNamespace(nn.Namespace), client.MatchingLabels{steerv1alpha1.LabelHelmTestJob: hooks.LabelValue(nn.Name)}); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.SliceStable(list.Items, func(i, j int) bool {
+		return list.Items[j].CreationTimestamp.Before(&list.Items[i].CreationTimestamp)
+	})
+	writeJSON(w, http.StatusOK, list.Items)
+}
+
+// handleGetHelmTestRunLogs serves the log of a test or hook of a run as
+// plain text. stage is preTest, test (default) or postTest; hook names the
+// hook, test optionally the test. The full log comes from the log sink, the
+// tail kept in the status is served when there is none.
+func (s *Server) handleGetHelmTestRunLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	var run steerv1alpha1.HelmTestRun
+	if err := s.k8sClient.Get(ctx, types.NamespacedName{Namespace: vars["namespace"], Name: vars["run"]}, &run); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if run.Spec.HelmTestJobName != vars["name"] {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("run %s does not belong to %s", run.Name, vars["name"]))
+		return
+	}
+
+	query := r.URL.Query()
+	result, err := findRunResult(&run.Status, query.Get("stage"), query.Get("hook"), query.Get("test"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if result.logRef == "" || s.logSink == nil {
+		_, _ = io.WriteString(w, result.logs)
+		return
+	}
+	rc, err := s.logSink.Open(ctx, result.logRef)
+	if err != nil {
+		if errors.Is(err, logsink.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rc.Close()
+	_, _ = io.Copy(w, rc)
+}
+
+// handleGetHelmTestJobReport serves the report of the latest run, or of the
+// run named by ?run=, as JUnit XML (format=junit, default) or JSON. Stored
+// reports are served from the log sink; other runs, e.g. active ones, get a
+// report built from their status.
+func (s *Server) handleGetHelmTestJobReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = report.FormatJUnit
+	}
+	if format != report.FormatJUnit && format != report.FormatJSON {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown report format %q", format))
+		return
+	}
+
+	runName := query.Get("run")
+	if runName == "" {
+		var job steerv1alpha1.HelmTestJob
+		if err := s.k8sClient.Get(ctx, types.NamespacedName{Namespace: vars["namespace"], Name: vars["name"]}, &job); err != nil {
+			if apierrors.IsNotFound(err) {
+				writeError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if runName = job.Status.LastRunName; runName == "" {
+			writeError(w, http.StatusNotFound, "the job has not run yet")
+			return
+		}
+	}
+	var run steerv1alpha1.HelmTestRun
+	if err := s.k8sClient.Get(ctx, types.NamespacedName{Namespace: vars["namespace"], Name: runName}, &run); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if run.Spec.HelmTestJobName != vars["name"] {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("run %s does not belong to %s", run.Name, vars["name"]))
+		return
+	}
+
+	var ref string
+	if run.Status.Reports != nil {
+		ref = run.Status.Reports.JUnit
+		if format == report.FormatJSON {
+			ref = run.Status.Reports.JSON
+		}
+	}
+	if ref != "" && s.logSink != nil {
+		rc, err := s.logSink.Open(ctx, ref)
+		if err == nil {
+			defer rc.Close()
+			setReportHeaders(w, run.Name, format)
+			_, _ = io.Copy(w, rc)
+			return
+		}
+		if !errors.Is(err, logsink.ErrNotFound) {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	data, err := report.Build(&run).Encode(format)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	setReportHeaders(w, run.Name, format)
+	_, _ = w.Write(data)
+}
+
+func setReportHeaders(w http.ResponseWriter, runName, format string) {
+	contentType, ext := "application/xml", "xml"
+	if format == report.FormatJSON {
+		contentType, ext = "application/json", "json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", runName+"."+ext))
+}
+
+// runResult is the part of a test or hook result the log endpoints use.
+type runResult struct {
+	phase   steerv1alpha1.HelmTestJobPhase
+	jobName string
+	podName string
+	logs    string
+	logRef  string
+}
+
+// findRunResult returns the result of a test or hook of a run.
+func findRunResult(status *steerv1alpha1.HelmTestRunStatus, stage, hook, test string) (runResult, error) {
+	var hookResults []steerv1alpha1.HookResult
+	switch stage {
+	case "", "test":
+		for _, result := range status.TestResults {
+			if test == "" || result.Name == test {
+				return runResult{phase: result.Phase, jobName: result.JobName, podName: result.PodName, logs: result.Logs, logRef: result.LogRef}, nil
+			}
+		}
+		return runResult{}, errors.New("test result not found")
+	case "preTest":
+		if status.HookResults != nil {
+			hookResults = status.HookResults.PreTest
+		}
+	case "postTest":
+		if status.HookResults != nil {
+			hookResults = status.HookResults.PostTest
+		}
+	default:
+		return runResult{}, fmt.Errorf("unknown stage %q", stage)
+	}
+	for _, result := range hookResults {
+		if result.Name == hook {
+			return runResult{phase: result.Phase, jobName: result.JobName, podName: result.PodName, logs: result.Logs, logRef: result.LogRef}, nil
+		}
+	}
+	return runResult{}, fmt.Errorf("hook %q not found in stage %s", hook, stage)
+}
+
+// handleRunHelmTestJob requests a manual run by setting the
+// steer.io/run-requested-at annotation; the controller picks it up.
+func (s *Server) handleRunHelmTestJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	nn := types.NamespacedName{Namespace: vars["namespace"], Name: vars["name"]}
+	var obj steerv1alpha1.HelmTestJob
+	if err := s.k8sClient.Get(ctx, nn, &obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	requestedAt := time.Now().UTC().Format(time.RFC3339)
+	patch := client.MergeFrom(obj.DeepCopy())
+	if obj.Annotations == nil {
+		obj.Annotations = map[string]string{}
+	}
+	obj.Annotations[steerv1alpha1.AnnotationRunRequestedAt] = requestedAt
+	if err := s.k8sClient.Patch(ctx, &obj, patch); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"runRequestedAt": requestedAt})
+}
+
+// handleCancelHelmTestJob cancels the active runs by setting the
+// steer.io/cancel-requested-at annotation; the controller picks it up.
+func (s *Server) handleCancelHelmTestJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	nn := types.NamespacedName{Namespace: vars["namespace"], Name: vars["name"]}
+	requestedBy := s.requestUser(r)
+
+	var obj steerv1alpha1.HelmTestJob
+	if err := s.k8sClient.Get(ctx, nn, &obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	requestedAt := time.Now().UTC().Format(time.RFC3339)
+	patch := client.MergeFrom(obj.DeepCopy())
+	if obj.Annotations == nil {
+		obj.Annotations = map[string]string{}
+	}
+	obj.Annotations[steerv1alpha1.AnnotationCancelRequestedAt] = requestedAt
+	obj.Annotations[steerv1alpha1.AnnotationCancelRequestedBy] = requestedBy
+	if err := s.k8sClient.Patch(ctx, &obj, patch); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"cancelRequestedAt": requestedAt,
+		"cancelRequestedBy": requestedBy,
+	})
+}
+
+// requestUser returns who made a request. Only a trusted proxy may name the
+// user, in the X-Forwarded-User header.
+func (s *Server) requestUser(r *http.Request) string {
+	user := r.Header.Get("X-Forwarded-User")
+	if user == "" || !s.fromTrustedProxy(r) {
+		return "web"
+	}
+	return user
+}
+
+func (s *Server) fromTrustedProxy(r *http.Request) bool {
+	addr, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := addr.Addr().Unmap()
+	for _, prefix := range s.TrustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies parses a comma separated list of CIDRs or addresses.
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/config"
+)
+
+var helmtestjoblog = logf.Log.WithName("helmtestjob-resource")
+
+// SetupHelmTestJobWebhookWithManager registers the validating webhook for
+// HelmTestJob with the manager.
+func SetupHelmTestJobWebhookWithManager(mgr ctrl.Manager, cfg *config.Config, ephemeralEnvironments bool) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&steerv1alpha1.HelmTestJob{}).
+		WithValidator(&HelmTestJobCustomValidator{Config: cfg, EphemeralEnvironments: ephemeralEnvironments}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-steer-io-v1alpha1-helmtestjob,mutating=false,failurePolicy=fail,sideEffects=None,groups=steer.io,resources=helmtestjobs,verbs=create;update,versions=v1alpha1,name=vhelmtestjob.steer.io,admissionReviewVersions=v1
+
+// HelmTestJobCustomValidator rejects HelmTestJobs that use images outside
+// the registries allowed by the operator config, or ask for RBAC rules beyond
+// its allowedRBACRules. Ephemeral environments are rejected unless the
+// operator can install them.
+type HelmTestJobCustomValidator struct {
+	Config *config.Config
+	// EphemeralEnvironments is set when the operator has a Helm client to
+	// install ephemeral environments with.
+	EphemeralEnvironments bool
+}
+
+var _ webhook.CustomValidator = &HelmTestJobCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (v *HelmTestJobCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (v *HelmTestJobCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator.
+func (v *HelmTestJobCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *HelmTestJobCustomValidator) validate(obj runtime.Object) error {
+	job, ok := obj.(*steerv1alpha1.HelmTestJob)
+	if !ok {
+		return fmt.Errorf("expected a HelmTestJob but got %T", obj)
+	}
+	helmtestjoblog.V(1).Info("validate", "name", job.Name)
+	errs := v.Config.CheckJob(job)
+	if job.Spec.Environment == steerv1alpha1.EnvironmentEphemeral && !v.EphemeralEnvironments {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "environment"), "ephemeral environments are not supported: the operator has no Helm client configured"))
+	}
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(steerv1alpha1.GroupVersion.WithKind("HelmTestJob").GroupKind(), job.Name, errs)
+	}
+	return nil
+}
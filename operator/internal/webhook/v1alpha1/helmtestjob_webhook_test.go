@@ -0,0 +1,208 @@
+/*
+Copyright 2026 MrLYC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/config"
+)
+
+var _ = Describe("HelmTestJob Webhook", func() {
+	var (
+		ctx       = context.Background()
+		validator *HelmTestJobCustomValidator
+		job       *steerv1alpha1.HelmTestJob
+	)
+
+	BeforeEach(func() {
+		validator = &HelmTestJobCustomValidator{Config: &config.Config{
+			AllowedRegistries: []string{"docker.io/library", "ghcr.io/steer"},
+		}}
+		job = &steerv1alpha1.HelmTestJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: steerv1alpha1.HelmTestJobSpec{
+				HelmReleaseRef: steerv1alpha1.HelmReleaseRef{Name: "example-release", Namespace: "default"},
+				Schedule:       steerv1alpha1.ScheduleSpec{Type: steerv1alpha1.ScheduleTypeOnce},
+				Test:           steerv1alpha1.TestSpec{Image: "busybox:1.36"},
+			},
+		}
+	})
+
+	It("should admit images from allowed registries", func() {
+		job.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+			Name: "seed",
+			Type: steerv1alpha1.HookTypeKubernetes,
+			Kubernetes: &steerv1alpha1.KubernetesHookSpec{RawExtension: runtime.RawExtension{
+				Raw: []byte(`{"apiVersion":"batch/v1","kind":"Job","spec":{"template":{"spec":{"containers":[{"name":"seed","image":"ghcr.io/steer/seed:v1"}]}}}}`),
+			}},
+		}}
+		_, err := validator.ValidateCreate(ctx, job)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject a test image from another registry", func() {
+		job.Spec.Test.Image = "quay.io/example/helm:3"
+		_, err := validator.ValidateCreate(ctx, job)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.test.image"))
+	})
+
+	It("should reject images of embedded kubernetes hooks on update", func() {
+		job.Spec.Hooks.PostTest = []steerv1alpha1.Hook{{
+			Name: "report",
+			Type: steerv1alpha1.HookTypeKubernetes,
+			Kubernetes: &steerv1alpha1.KubernetesHookSpec{RawExtension: runtime.RawExtension{
+				Raw: []byte(`{"apiVersion":"v1","kind":"Pod","spec":{"initContainers":[{"name":"init","image":"evil.example.com/init"}],"containers":[{"name":"report","image":"busybox"}]}}`),
+			}},
+		}}
+		_, err := validator.ValidateUpdate(ctx, job.DeepCopy(), job)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.hooks.postTest[0].kubernetes"))
+		Expect(err.Error()).To(ContainSubstring("evil.example.com/init"))
+	})
+
+	It("should reject ephemeral environments without a Helm client", func() {
+		job.Spec.Environment = steerv1alpha1.EnvironmentEphemeral
+		_, err := validator.ValidateCreate(ctx, job)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.environment"))
+
+		validator.EphemeralEnvironments = true
+		_, err = validator.ValidateCreate(ctx, job)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject RBAC rules beyond the default rules", func() {
+		job.Spec.RBAC = &steerv1alpha1.HelmTestJobRBACSpec{Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list"}},
+			{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+			{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"get"}},
+		}}
+		_, err := validator.ValidateCreate(ctx, job)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).NotTo(ContainSubstring("spec.rbac.rules[0]"))
+		Expect(err.Error()).To(ContainSubstring("spec.rbac.rules[1]"))
+		Expect(err.Error()).To(ContainSubstring("get secrets is not allowed"))
+		Expect(err.Error()).To(ContainSubstring("spec.rbac.rules[2]"))
+	})
+
+	It("should admit RBAC rules covered by the configured allowlist", func() {
+		validator.Config.AllowedRBACRules = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get", "list"}, ResourceNames: []string{"db-credentials"}},
+			{APIGroups: []string{"*"}, Resources: []string{"*/status"}, Verbs: []string{"*"}},
+		}
+		job.Spec.RBAC = &steerv1alpha1.HelmTestJobRBACSpec{Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}, ResourceNames: []string{"db-credentials"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments/status"}, Verbs: []string{"get", "patch"}},
+		}}
+		_, err := validator.ValidateCreate(ctx, job)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Dropping the resource names")
+		job.Spec.RBAC.Rules[0].ResourceNames = nil
+		_, err = validator.ValidateUpdate(ctx, job.DeepCopy(), job)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.rbac.rules[0]"))
+	})
+
+	It("should reject a HelmRelease in another namespace unless the operator config allows it", func() {
+		job.Spec.HelmReleaseRef.Namespace = "kube-system"
+		_, err := validator.ValidateCreate(ctx, job)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.helmReleaseRef.namespace"))
+		Expect(err.Error()).To(ContainSubstring("allowedTargetNamespaces"))
+
+		validator.Config.AllowedTargetNamespaces = []string{"kube-system"}
+		_, err = validator.ValidateCreate(ctx, job)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject waitFor hooks on Secrets and kinds the operator config doesn't allow", func() {
+		job.Spec.Hooks.PreTest = []steerv1alpha1.Hook{
+			{Name: "secret", Type: steerv1alpha1.HookTypeWaitFor, WaitFor: &steerv1alpha1.WaitForHookSpec{Kind: "Secret", JSONPath: "{.data}"}},
+			{Name: "cert", Type: steerv1alpha1.HookTypeWaitFor, WaitFor: &steerv1alpha1.WaitForHookSpec{APIVersion: "cert-manager.io/v1", Kind: "Certificate", Condition: "Ready"}},
+			{Name: "web", Type: steerv1alpha1.HookTypeWaitFor, WaitFor: &steerv1alpha1.WaitForHookSpec{APIVersion: "apps/v1", Kind: "Deployment", Condition: "Available"}},
+		}
+		_, err := validator.ValidateCreate(ctx, job)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.hooks.preTest[0].waitFor.kind: Forbidden: waitFor cannot watch Secrets"))
+		Expect(err.Error()).To(ContainSubstring("spec.hooks.preTest[1].waitFor.kind: Forbidden: kind Certificate.cert-manager.io is not allowed"))
+		Expect(err.Error()).NotTo(ContainSubstring("preTest[2]"))
+
+		By("Allowing more kinds in the operator config, but never Secrets")
+		validator.Config.AllowedWaitForKinds = []string{"Certificate.cert-manager.io", "Secret"}
+		_, err = validator.ValidateCreate(ctx, job)
+		Expect(err.Error()).To(ContainSubstring("preTest[0]"))
+		Expect(err.Error()).NotTo(ContainSubstring("preTest[1]"))
+		Expect(err.Error()).To(ContainSubstring("preTest[2]"))
+	})
+
+	It("should reject pod templates beyond the pod template policy", func() {
+		privileged := true
+		job.Spec.PodTemplate = &steerv1alpha1.PodTemplateOverlay{
+			ServiceAccountName:       "default",
+			ContainerSecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+			Volumes: []corev1.Volume{
+				{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run"}}},
+				{Name: "Bad_Name"},
+			},
+		}
+		job.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+			Name: "seed",
+			Type: steerv1alpha1.HookTypeKubernetes,
+			Kubernetes: &steerv1alpha1.KubernetesHookSpec{RawExtension: runtime.RawExtension{
+				Raw: []byte(`{"apiVersion":"v1","kind":"Pod","spec":{"hostNetwork":true,"containers":[{"name":"seed","image":"busybox:1.36"}]}}`),
+			}},
+		}}
+		_, err := validator.ValidateCreate(ctx, job)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		for _, msg := range []string{
+			"spec.podTemplate.serviceAccountName",
+			"spec.podTemplate.containerSecurityContext: Forbidden: privileged",
+			"spec.podTemplate.volumes[0].hostPath",
+			"spec.podTemplate.volumes[1].name",
+			"must have exactly one volume source, has 0",
+			"spec.hooks.preTest[0].kubernetes: Forbidden: hostNetwork",
+		} {
+			Expect(err.Error()).To(ContainSubstring(msg))
+		}
+
+		By("Allowing them in the operator config")
+		validator.Config.PodTemplate = config.PodTemplatePolicy{AllowHostPath: true, AllowPrivileged: true, AllowedServiceAccounts: []string{"default"}}
+		job.Spec.PodTemplate.Volumes = job.Spec.PodTemplate.Volumes[:1]
+		_, err = validator.ValidateUpdate(ctx, job.DeepCopy(), job)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should allow any image without an allowlist", func() {
+		validator.Config = nil
+		job.Spec.Test.Image = "quay.io/example/helm:3"
+		_, err := validator.ValidateCreate(ctx, job)
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -1,25 +1,76 @@
 package cleanup
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInProgress is returned while a cleanup has been started but is not done
+// yet. Cleanups are idempotent; call CleanupNamespace again later.
+var ErrInProgress = errors.New("cleanup in progress")
 
 // Runner encapsulates post-test or post-release cleanup behavior.
-//
-// Block A note: the real implementation (namespace deletion, image cleanup)
-// will be added later.
 type Runner interface {
+	// NamespaceImages returns the images of the pods of a namespace. Record
+	// them before uninstalling what runs there and pass them as
+	// Options.Images.
+	NamespaceImages(ctx context.Context, namespace string) ([]string, error)
 	CleanupNamespace(ctx context.Context, namespace string, opts Options) error
 }
 
 type Options struct {
 	DeleteNamespace bool
 	DeleteImages    bool
+	// Images are removed from the nodes when DeleteImages is set. Images
+	// pods in other namespaces still use are kept.
+	Images []string
+}
+
+// StuckError reports a namespace that has been terminating for too long,
+// usually because of finalizers nobody removes. It is also ErrInProgress:
+// the namespace may still go away.
+type StuckError struct {
+	Namespace string
+	// Since is when the deletion of the namespace was requested.
+	Since time.Time
+	// Finalizers are the finalizers left on the namespace itself.
+	Finalizers []string
+	// Conditions are the messages of the namespace deletion conditions,
+	// naming the remaining resources and their finalizers.
+	Conditions []string
+}
+
+func (e *StuckError) Error() string {
+	msg := fmt.Sprintf("namespace %s is stuck terminating since %s", e.Namespace, e.Since.UTC().Format(time.RFC3339))
+	if len(e.Finalizers) > 0 {
+		msg += fmt.Sprintf("; finalizers: %s", strings.Join(e.Finalizers, ", "))
+	}
+	if len(e.Conditions) > 0 {
+		msg += "; " + strings.Join(e.Conditions, "; ")
+	}
+	return msg
+}
+
+func (e *StuckError) Is(target error) bool {
+	return target == ErrInProgress
 }
 
 // FakeRunner is a simple injectable fake implementation of Runner.
 type FakeRunner struct {
+	NamespaceImagesFunc  func(ctx context.Context, namespace string) ([]string, error)
 	CleanupNamespaceFunc func(ctx context.Context, namespace string, opts Options) error
 }
 
+func (f *FakeRunner) NamespaceImages(ctx context.Context, namespace string) ([]string, error) {
+	if f.NamespaceImagesFunc != nil {
+		return f.NamespaceImagesFunc(ctx, namespace)
+	}
+	return nil, nil
+}
+
 func (f *FakeRunner) CleanupNamespace(ctx context.Context, namespace string, opts Options) error {
 	if f.CleanupNamespaceFunc != nil {
 		return f.CleanupNamespaceFunc(ctx, namespace, opts)
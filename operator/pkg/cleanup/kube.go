@@ -0,0 +1,330 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LabelNamespace marks the image cleanup DaemonSet of a namespace.
+	LabelNamespace = "steer.io/cleanup-namespace"
+
+	defaultRuntimeEndpoint = "/run/containerd/containerd.sock"
+	defaultStuckAfter      = 10 * time.Minute
+
+	// runtimeSocket is where the CRI socket of the node is mounted.
+	runtimeSocket = "/run/steer/cri.sock"
+	// doneFile is written once the images are removed; the readiness probe
+	// of the cleanup pods checks it.
+	doneFile = "/tmp/steer-cleanup-done"
+)
+
+// removeImagesScript removes the images one by one; images still used by
+// other pods on the node are kept.
+const removeImagesScript = `for image in $IMAGES; do
+  crictl rmi "$image" || echo "kept $image"
+done
+touch ` + doneFile + `
+exec sleep 2147483647
+`
+
+// Config configures the KubeRunner.
+//
+//	cleanup:
+//	  image: registry.example.com/tools/crictl:v1.29.0
+//	  runtimeEndpoint: /run/containerd/containerd.sock
+type Config struct {
+	// Namespace runs the image cleanup DaemonSets. Defaults to the
+	// namespace of the operator.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Image runs on every node to remove images; it needs a shell and
+	// crictl. Image cleanup fails without it.
+	Image string `json:"image,omitempty"`
+
+	// RuntimeEndpoint is the path of the CRI socket on the nodes. Defaults
+	// to the containerd socket.
+	RuntimeEndpoint string `json:"runtimeEndpoint,omitempty"`
+
+	// StuckAfter is how long a namespace may terminate, or images take to be
+	// removed, before the cleanup reports it as stuck. Defaults to 10m.
+	StuckAfter metav1.Duration `json:"stuckAfter,omitempty"`
+}
+
+// KubeRunner deletes namespaces and removes the images their pods used from
+// the nodes.
+//
+// Images are removed by a DaemonSet in Config.Namespace that runs crictl on
+// every node. It is created before the namespace is deleted and deleted once
+// it is ready on all nodes and the namespace is gone.
+//
+// CleanupNamespace never waits: it returns ErrInProgress until the namespace
+// is gone and the images are removed.
+type KubeRunner struct {
+	Client client.Client
+	Config Config
+}
+
+func New(c client.Client, cfg Config) *KubeRunner {
+	return &KubeRunner{Client: c, Config: cfg}
+}
+
+func (r *KubeRunner) CleanupNamespace(ctx context.Context, namespace string, opts Options) error {
+	var ds *appsv1.DaemonSet
+	if opts.DeleteImages {
+		var err error
+		if ds, err = r.ensureImageCleanup(ctx, namespace, opts.Images); err != nil {
+			return fmt.Errorf("remove images of namespace %s: %w", namespace, err)
+		}
+	}
+
+	var nsErr error
+	if opts.DeleteNamespace {
+		nsErr = r.deleteNamespace(ctx, namespace)
+		if nsErr != nil && !errors.Is(nsErr, ErrInProgress) {
+			return nsErr
+		}
+	}
+
+	if ds == nil {
+		return nsErr
+	}
+	s := ds.Status
+	if s.ObservedGeneration < ds.Generation || s.NumberReady < s.DesiredNumberScheduled {
+		if nsErr != nil {
+			return nsErr
+		}
+		if time.Since(ds.CreationTimestamp.Time) >= r.stuckAfter() {
+			_ = r.deleteImageCleanup(ctx, ds)
+			return fmt.Errorf("images of namespace %s were only removed from %d of %d nodes after %s", namespace, s.NumberReady, s.DesiredNumberScheduled, r.stuckAfter())
+		}
+		return fmt.Errorf("%w: removing images of namespace %s from %d of %d nodes", ErrInProgress, namespace, s.NumberReady, s.DesiredNumberScheduled)
+	}
+	if nsErr != nil {
+		// The DaemonSet is kept until the namespace is gone, so it is not
+		// created again for the pods that are still terminating.
+		return nsErr
+	}
+	return r.deleteImageCleanup(ctx, ds)
+}
+
+// deleteNamespace deletes a namespace. It returns ErrInProgress while the
+// namespace is terminating.
+func (r *KubeRunner) deleteNamespace(ctx context.Context, name string) error {
+	ns := &corev1.Namespace{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("get namespace %s: %w", name, err)
+	}
+	if ns.DeletionTimestamp == nil {
+		if err := r.Client.Delete(ctx, ns); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("delete namespace %s: %w", name, err)
+		}
+	} else if time.Since(ns.DeletionTimestamp.Time) >= r.stuckAfter() {
+		return stuckError(ns)
+	}
+	return fmt.Errorf("%w: namespace %s is terminating", ErrInProgress, name)
+}
+
+// stuckError reports what keeps a namespace from terminating. The namespace
+// controller records the remaining resources and their finalizers in the
+// conditions of the namespace.
+func stuckError(ns *corev1.Namespace) *StuckError {
+	e := &StuckError{Namespace: ns.Name, Since: ns.DeletionTimestamp.Time}
+	e.Finalizers = append(e.Finalizers, ns.Finalizers...)
+	for _, f := range ns.Spec.Finalizers {
+		e.Finalizers = append(e.Finalizers, string(f))
+	}
+	for _, c := range ns.Status.Conditions {
+		if c.Status == corev1.ConditionTrue && c.Message != "" {
+			e.Conditions = append(e.Conditions, c.Message)
+		}
+	}
+	return e
+}
+
+// ensureImageCleanup returns the image cleanup DaemonSet of a namespace,
+// creating it if needed. It returns nil when there are no images to remove.
+func (r *KubeRunner) ensureImageCleanup(ctx context.Context, namespace string, images []string) (*appsv1.DaemonSet, error) {
+	if r.Config.Image == "" {
+		return nil, errors.New("no image cleanup image configured, set cleanup.image in the operator config")
+	}
+	if r.Config.Namespace == "" {
+		return nil, errors.New("no namespace for image cleanup configured")
+	}
+
+	ds := &appsv1.DaemonSet{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: imageCleanupName(namespace), Namespace: r.Config.Namespace}, ds)
+	if err == nil {
+		return ds, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	images, err = r.unusedImages(ctx, namespace, images)
+	if err != nil || len(images) == 0 {
+		return nil, err
+	}
+	ds = r.imageCleanupDaemonSet(namespace, images)
+	if err := r.Client.Create(ctx, ds); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// NamespaceImages returns the images of the pods of a namespace.
+func (r *KubeRunner) NamespaceImages(ctx context.Context, namespace string) ([]string, error) {
+	var pods corev1.PodList
+	if err := r.Client.List(ctx, &pods, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	seen := map[string]bool{}
+	var images []string
+	for i := range pods.Items {
+		for _, image := range podImages(&pods.Items[i]) {
+			if !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// unusedImages drops the images pods outside of a namespace use, the image
+// cleanup would remove them from the nodes for everyone.
+func (r *KubeRunner) unusedImages(ctx context.Context, namespace string, images []string) ([]string, error) {
+	if len(images) == 0 {
+		return nil, nil
+	}
+	var pods corev1.PodList
+	if err := r.Client.List(ctx, &pods); err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	used := map[string]bool{}
+	for i := range pods.Items {
+		if pods.Items[i].Namespace == namespace {
+			continue
+		}
+		for _, image := range podImages(&pods.Items[i]) {
+			used[image] = true
+		}
+	}
+	var unused []string
+	for _, image := range images {
+		if !used[image] {
+			unused = append(unused, image)
+		}
+	}
+	return unused, nil
+}
+
+func podImages(pod *corev1.Pod) []string {
+	var images []string
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range containers {
+			if c.Image != "" {
+				images = append(images, c.Image)
+			}
+		}
+	}
+	return images
+}
+
+func (r *KubeRunner) imageCleanupDaemonSet(namespace string, images []string) *appsv1.DaemonSet {
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": "steer",
+		"app.kubernetes.io/component":  "image-cleanup",
+		LabelNamespace:                 namespace,
+	}
+	endpoint := r.Config.RuntimeEndpoint
+	if endpoint == "" {
+		endpoint = defaultRuntimeEndpoint
+	}
+	socket := corev1.HostPathSocket
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      imageCleanupName(namespace),
+			Namespace: r.Config.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					// Images are cached on every node, tainted or not.
+					Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					Containers: []corev1.Container{{
+						Name:    "remove-images",
+						Image:   r.Config.Image,
+						Command: []string{"/bin/sh", "-c", removeImagesScript},
+						Env: []corev1.EnvVar{
+							{Name: "IMAGES", Value: strings.Join(images, " ")},
+							{Name: "CONTAINER_RUNTIME_ENDPOINT", Value: "unix://" + runtimeSocket},
+						},
+						VolumeMounts: []corev1.VolumeMount{{Name: "runtime", MountPath: runtimeSocket}},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								Exec: &corev1.ExecAction{Command: []string{"cat", doneFile}},
+							},
+							PeriodSeconds: 2,
+						},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "runtime",
+						VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{Path: endpoint, Type: &socket},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func (r *KubeRunner) deleteImageCleanup(ctx context.Context, ds *appsv1.DaemonSet) error {
+	err := r.Client.Delete(ctx, ds, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete image cleanup DaemonSet %s/%s: %w", ds.Namespace, ds.Name, err)
+	}
+	return nil
+}
+
+// imageCleanupName returns the name of the image cleanup DaemonSet of a
+// namespace. Long names are shortened with a hash to stay unique.
+func imageCleanupName(namespace string) string {
+	name := "steer-image-cleanup-" + namespace
+	if len(name) <= 63 {
+		return name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	suffix := fmt.Sprintf("-%08x", h.Sum32())
+	return strings.TrimRight(name[:63-len(suffix)], "-") + suffix
+}
+
+func (r *KubeRunner) stuckAfter() time.Duration {
+	if r.Config.StuckAfter.Duration > 0 {
+		return r.Config.StuckAfter.Duration
+	}
+	return defaultStuckAfter
+}
@@ -0,0 +1,218 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNamespace = "env"
+
+func newTestRunner(t *testing.T, objs ...client.Object) *KubeRunner {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	return New(c, Config{Namespace: "steer-system", Image: "crictl:test", StuckAfter: metav1.Duration{Duration: time.Hour}})
+}
+
+func testPod(namespace, name string, images ...string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	for i, image := range images {
+		c := corev1.Container{Name: name + string(rune('a'+i)), Image: image}
+		if i == 0 {
+			pod.Spec.InitContainers = append(pod.Spec.InitContainers, c)
+		} else {
+			pod.Spec.Containers = append(pod.Spec.Containers, c)
+		}
+	}
+	return pod
+}
+
+// terminatingNamespace is a namespace whose deletion was requested at since.
+func terminatingNamespace(since time.Time) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              testNamespace,
+			DeletionTimestamp: &metav1.Time{Time: since},
+			Finalizers:        []string{"example.com/protect"},
+		},
+		Status: corev1.NamespaceStatus{Conditions: []corev1.NamespaceCondition{{
+			Type:    corev1.NamespaceFinalizersRemaining,
+			Status:  corev1.ConditionTrue,
+			Message: "Some content in the namespace has finalizers remaining",
+		}}},
+	}
+}
+
+func TestNamespaceImages(t *testing.T) {
+	r := newTestRunner(t,
+		testPod(testNamespace, "web", "busybox:1", "nginx:1"),
+		testPod(testNamespace, "worker", "app:2", "nginx:1"),
+		testPod("other", "db", "postgres:16"),
+	)
+	got, err := r.NamespaceImages(context.Background(), testNamespace)
+	if err != nil {
+		t.Fatalf("NamespaceImages() error = %v", err)
+	}
+	if want := []string{"app:2", "busybox:1", "nginx:1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NamespaceImages() = %v, want %v", got, want)
+	}
+}
+
+func TestCleanupNamespaceDeleteNamespace(t *testing.T) {
+	tests := []struct {
+		name       string
+		objects    []client.Object
+		wantErr    error
+		wantStuck  bool
+		wantGone   bool
+		wantDetail string
+	}{
+		{
+			name:     "missing namespace",
+			wantGone: true,
+		},
+		{
+			name:       "deletes the namespace without waiting",
+			objects:    []client.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}}},
+			wantErr:    ErrInProgress,
+			wantGone:   true,
+			wantDetail: "namespace env is terminating",
+		},
+		{
+			name:       "terminating namespace",
+			objects:    []client.Object{terminatingNamespace(time.Now().Add(-time.Minute))},
+			wantErr:    ErrInProgress,
+			wantDetail: "namespace env is terminating",
+		},
+		{
+			name:       "stuck namespace",
+			objects:    []client.Object{terminatingNamespace(time.Now().Add(-2 * time.Hour))},
+			wantErr:    ErrInProgress,
+			wantStuck:  true,
+			wantDetail: "finalizers: example.com/protect; Some content in the namespace has finalizers remaining",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRunner(t, tt.objects...)
+			err := r.CleanupNamespace(context.Background(), testNamespace, Options{DeleteNamespace: true})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CleanupNamespace() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantDetail) {
+				t.Errorf("CleanupNamespace() error = %q, want it to contain %q", err, tt.wantDetail)
+			}
+			var stuck *StuckError
+			if errors.As(err, &stuck) != tt.wantStuck {
+				t.Errorf("CleanupNamespace() error = %#v, want stuck %v", err, tt.wantStuck)
+			}
+			getErr := r.Client.Get(context.Background(), client.ObjectKey{Name: testNamespace}, &corev1.Namespace{})
+			if apierrors.IsNotFound(getErr) != tt.wantGone {
+				t.Errorf("namespace gone = %v, want %v", apierrors.IsNotFound(getErr), tt.wantGone)
+			}
+		})
+	}
+}
+
+func TestCleanupNamespaceImages(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Name: imageCleanupName(testNamespace), Namespace: "steer-system"}
+	imagesOf := func(ds *appsv1.DaemonSet) string {
+		for _, env := range ds.Spec.Template.Spec.Containers[0].Env {
+			if env.Name == "IMAGES" {
+				return env.Value
+			}
+		}
+		return ""
+	}
+
+	t.Run("removes only images nothing else uses", func(t *testing.T) {
+		r := newTestRunner(t, terminatingNamespace(time.Now()), testPod("other", "db", "shared:1"))
+		err := r.CleanupNamespace(ctx, testNamespace, Options{DeleteNamespace: true, DeleteImages: true, Images: []string{"app:1", "shared:1"}})
+		if !errors.Is(err, ErrInProgress) {
+			t.Fatalf("CleanupNamespace() error = %v, want ErrInProgress", err)
+		}
+		ds := &appsv1.DaemonSet{}
+		if err := r.Client.Get(ctx, key, ds); err != nil {
+			t.Fatal(err)
+		}
+		if got := imagesOf(ds); got != "app:1" {
+			t.Errorf("DaemonSet removes %q, want %q", got, "app:1")
+		}
+	})
+
+	t.Run("skips images in use", func(t *testing.T) {
+		r := newTestRunner(t, testPod("other", "db", "shared:1"))
+		if err := r.CleanupNamespace(ctx, testNamespace, Options{DeleteImages: true, Images: []string{"shared:1"}}); err != nil {
+			t.Fatalf("CleanupNamespace() error = %v", err)
+		}
+		if err := r.Client.Get(ctx, key, &appsv1.DaemonSet{}); !apierrors.IsNotFound(err) {
+			t.Errorf("DaemonSet was created: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		created time.Time
+		ready   int32
+		wantErr string
+		wantDS  bool
+	}{
+		{
+			name:    "waits for the nodes",
+			created: time.Now(),
+			ready:   1,
+			wantErr: "removing images of namespace env from 1 of 2 nodes",
+			wantDS:  true,
+		},
+		{
+			name:    "gives up after stuckAfter",
+			created: time.Now().Add(-2 * time.Hour),
+			ready:   1,
+			wantErr: "were only removed from 1 of 2 nodes",
+		},
+		{
+			name:    "deletes the DaemonSet once done",
+			created: time.Now(),
+			ready:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRunner(t)
+			ds := r.imageCleanupDaemonSet(testNamespace, []string{"app:1"})
+			ds.CreationTimestamp = metav1.Time{Time: tt.created}
+			ds.Status = appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberReady: tt.ready}
+			if err := r.Client.Create(ctx, ds); err != nil {
+				t.Fatal(err)
+			}
+			err := r.CleanupNamespace(ctx, testNamespace, Options{DeleteNamespace: true, DeleteImages: true, Images: []string{"app:1"}})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("CleanupNamespace() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("CleanupNamespace() error = %v, want %q", err, tt.wantErr)
+			}
+			getErr := r.Client.Get(ctx, key, &appsv1.DaemonSet{})
+			if (getErr == nil) != tt.wantDS {
+				t.Errorf("DaemonSet kept = %v, want %v", getErr == nil, tt.wantDS)
+			}
+		})
+	}
+}
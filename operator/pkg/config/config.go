@@ -0,0 +1,278 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/cleanup"
+	"github.com/MrLYC/steer/operator/pkg/helm"
+	"github.com/MrLYC/steer/operator/pkg/logsink"
+)
+
+// Config is the operator-level configuration, usually mounted from a
+// ConfigMap and passed with --config.
+//
+//	defaultImages:
+//	  test: alpine/helm:3.14.0
+//	  hooks:
+//	    script: bitnami/kubectl:1.29
+//	allowedRegistries:
+//	- docker.io
+//	- ghcr.io/my-org
+//	allowedRBACRules:
+//	- apiGroups: [""]
+//	  resources: ["pods", "pods/log", "services"]
+//	  verbs: ["get", "list", "watch"]
+//	allowedWaitForKinds: ["Deployment.apps", "Certificate.cert-manager.io"]
+//	allowedTargetNamespaces: ["staging"]
+//	podTemplate:
+//	  allowedServiceAccounts: ["test-runner"]
+//	logSink:
+//	  type: pvc
+//	  dir: /var/lib/steer/logs
+//	cleanup:
+//	  image: registry.example.com/tools/crictl:v1.29.0
+//	helm:
+//	  enabled: true
+type Config struct {
+	// DefaultImages are used when a HelmTestJob doesn't set an image.
+	DefaultImages DefaultImages `json:"defaultImages,omitempty"`
+
+	// AllowedRegistries restricts the images HelmTestJobs may use. An entry
+	// matches a registry host or a repository prefix. Empty allows any image.
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+
+	// AllowedRBACRules bound spec.rbac.rules of HelmTestJobs: each rule must
+	// be covered by them, as the operator creates and binds the Roles of runs
+	// itself. Defaults to DefaultRBACRules.
+	AllowedRBACRules []rbacv1.PolicyRule `json:"allowedRBACRules,omitempty"`
+
+	// AllowedWaitForKinds are the kinds waitFor hooks may watch, as Kind or
+	// Kind.group, e.g. Deployment.apps. Defaults to DefaultWaitForKinds.
+	// Secrets are never allowed.
+	AllowedWaitForKinds []string `json:"allowedWaitForKinds,omitempty"`
+
+	// AllowedTargetNamespaces are the namespaces other than their own that
+	// HelmTestJobs may act on: reference HelmReleases in, bind the Roles of
+	// runs in, disrupt with chaos hooks and clean up. "*" allows any. Empty
+	// only allows the namespace of the HelmTestJob.
+	AllowedTargetNamespaces []string `json:"allowedTargetNamespaces,omitempty"`
+
+	// PodTemplate bounds the pod settings HelmTestJobs may ask for.
+	PodTemplate PodTemplatePolicy `json:"podTemplate,omitempty"`
+
+	// LogSink stores the full logs of tests and hooks. Without it only the
+	// tail kept in the status is available.
+	LogSink logsink.Config `json:"logSink,omitempty"`
+
+	// Cleanup configures how namespaces and images are cleaned up after
+	// HelmReleases are deleted and tests ran.
+	Cleanup cleanup.Config `json:"cleanup,omitempty"`
+
+	// Helm installs the releases of ephemeral environments. Without it
+	// HelmTestJobs with spec.environment ephemeral are rejected.
+	Helm helm.Config `json:"helm,omitempty"`
+}
+
+type DefaultImages struct {
+	// Test runs helm test when spec.test.image is empty.
+	Test string `json:"test,omitempty"`
+
+	// Hooks maps a hook type to the image of its Jobs. Hook types without an
+	// entry use the test image.
+	Hooks map[steerv1alpha1.HookType]string `json:"hooks,omitempty"`
+}
+
+// Load reads a Config from a YAML file. An empty path returns an empty Config.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+	if err := yaml.UnmarshalStrict(raw, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// TestImage returns the image of the test Job of a HelmTestJob.
+func (c *Config) TestImage(job *steerv1alpha1.HelmTestJob) string {
+	if job.Spec.Test.Image != "" {
+		return job.Spec.Test.Image
+	}
+	if c == nil {
+		return ""
+	}
+	return c.DefaultImages.Test
+}
+
+// HookImage returns the image of the Jobs of a hook type, falling back to
+// testImage.
+func (c *Config) HookImage(hookType steerv1alpha1.HookType, testImage string) string {
+	if c != nil {
+		if image := c.DefaultImages.Hooks[hookType]; image != "" {
+			return image
+		}
+	}
+	return testImage
+}
+
+// CheckImage returns an error if image is not from an allowed registry.
+func (c *Config) CheckImage(image string) error {
+	if c == nil || len(c.AllowedRegistries) == 0 {
+		return nil
+	}
+	repo := repository(image)
+	for _, allowed := range c.AllowedRegistries {
+		allowed = strings.TrimSuffix(allowed, "/")
+		if repo == allowed || strings.HasPrefix(repo, allowed+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %q is not from an allowed registry (%s)", image, strings.Join(c.AllowedRegistries, ", "))
+}
+
+// CheckTargetNamespace returns an error if a HelmTestJob in jobNamespace may
+// not act on namespace.
+func (c *Config) CheckTargetNamespace(jobNamespace, namespace string) error {
+	if namespace == jobNamespace {
+		return nil
+	}
+	if c != nil {
+		for _, allowed := range c.AllowedTargetNamespaces {
+			if allowed == "*" || allowed == namespace {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("namespace %s is not the namespace of the HelmTestJob and not allowed by the operator config (allowedTargetNamespaces)", namespace)
+}
+
+// repository returns the fully qualified repository of an image reference,
+// without tag or digest, e.g. "docker.io/library/busybox" for "busybox:1.36".
+func repository(image string) string {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		name = name[:i]
+	}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 1 {
+		return "docker.io/library/" + name
+	}
+	if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		return "docker.io/" + name
+	}
+	return name
+}
+
+// CheckJob checks the images a HelmTestJob sets itself, spec.test.image and
+// the containers of embedded kubernetes hook objects, spec.rbac.rules, the
+// namespace of spec.helmReleaseRef, the kinds of waitFor hooks and the pod
+// templates against the PodTemplatePolicy.
+func (c *Config) CheckJob(job *steerv1alpha1.HelmTestJob) field.ErrorList {
+	errs := c.checkRBAC(job)
+	spec := field.NewPath("spec")
+	if ns := job.Spec.HelmReleaseRef.Namespace; ns != "" {
+		if err := c.CheckTargetNamespace(job.Namespace, ns); err != nil {
+			errs = append(errs, field.Forbidden(spec.Child("helmReleaseRef", "namespace"), err.Error()))
+		}
+	}
+	errs = append(errs, c.checkPodTemplate(job.Spec.PodTemplate, spec.Child("podTemplate"))...)
+	if image := job.Spec.Test.Image; image != "" {
+		if err := c.CheckImage(image); err != nil {
+			errs = append(errs, field.Forbidden(spec.Child("test", "image"), err.Error()))
+		}
+	}
+	stages := []struct {
+		name  string
+		hooks []steerv1alpha1.Hook
+	}{
+		{"preTest", job.Spec.Hooks.PreTest},
+		{"postTest", job.Spec.Hooks.PostTest},
+	}
+	for _, stage := range stages {
+		for i, h := range stage.hooks {
+			hook := spec.Child("hooks", stage.name).Index(i)
+			errs = append(errs, c.checkPodTemplate(h.PodTemplate, hook.Child("podTemplate"))...)
+			if h.WaitFor != nil {
+				if err := c.CheckWaitForKind(h.WaitFor.APIVersion, h.WaitFor.Kind); err != nil {
+					errs = append(errs, field.Forbidden(hook.Child("waitFor", "kind"), err.Error()))
+				}
+			}
+			if h.Kubernetes == nil {
+				continue
+			}
+			path := hook.Child("kubernetes")
+			for _, image := range embeddedImages(h.Kubernetes.RawExtension) {
+				if err := c.CheckImage(image); err != nil {
+					errs = append(errs, field.Forbidden(path, err.Error()))
+				}
+			}
+			errs = append(errs, c.checkEmbeddedPods(h.Kubernetes.RawExtension, path)...)
+		}
+	}
+	return errs
+}
+
+// embeddedImages collects the images of all containers and init containers
+// of an embedded object, whatever its kind.
+func embeddedImages(raw runtime.RawExtension) []string {
+	var obj interface{}
+	switch {
+	case len(raw.Raw) > 0:
+		if err := json.Unmarshal(raw.Raw, &obj); err != nil {
+			return nil
+		}
+	case raw.Object != nil:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(raw.Object)
+		if err != nil {
+			return nil
+		}
+		obj = content
+	}
+
+	var images []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for key, child := range t {
+				if key == "containers" || key == "initContainers" {
+					if list, ok := child.([]interface{}); ok {
+						for _, c := range list {
+							if m, ok := c.(map[string]interface{}); ok {
+								if image, ok := m["image"].(string); ok && image != "" {
+									images = append(images, image)
+								}
+							}
+						}
+					}
+				}
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range t {
+				walk(child)
+			}
+		}
+	}
+	walk(obj)
+	sort.Strings(images)
+	return images
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// PodTemplatePolicy bounds what HelmTestJobs may set on the pods the
+// operator creates for them: through spec.podTemplate, the podTemplate of
+// hooks and the pod specs of kubernetes hooks. Everything is denied by
+// default.
+type PodTemplatePolicy struct {
+	// AllowHostPath allows hostPath volumes.
+	AllowHostPath bool `json:"allowHostPath,omitempty"`
+
+	// AllowPrivileged allows privileged containers, privilege escalation,
+	// added capabilities and the host's network, PID and IPC namespaces.
+	AllowPrivileged bool `json:"allowPrivileged,omitempty"`
+
+	// AllowedServiceAccounts are the ServiceAccounts pods may run as, "*"
+	// allows any.
+	AllowedServiceAccounts []string `json:"allowedServiceAccounts,omitempty"`
+}
+
+// CheckServiceAccount returns an error if pods may not run as the
+// ServiceAccount name.
+func (c *Config) CheckServiceAccount(name string) error {
+	if name == "" {
+		return nil
+	}
+	if c != nil {
+		for _, allowed := range c.PodTemplate.AllowedServiceAccounts {
+			if allowed == "*" || allowed == name {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("ServiceAccount %s is not allowed by the operator config (podTemplate.allowedServiceAccounts)", name)
+}
+
+func (c *Config) allowHostPath() bool {
+	return c != nil && c.PodTemplate.AllowHostPath
+}
+
+func (c *Config) allowPrivileged() bool {
+	return c != nil && c.PodTemplate.AllowPrivileged
+}
+
+// checkPodTemplate checks a pod template overlay.
+func (c *Config) checkPodTemplate(o *steerv1alpha1.PodTemplateOverlay, path *field.Path) field.ErrorList {
+	if o == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	if err := c.CheckServiceAccount(o.ServiceAccountName); err != nil {
+		errs = append(errs, field.Forbidden(path.Child("serviceAccountName"), err.Error()))
+	}
+	errs = append(errs, checkVolumes(o.Volumes, path.Child("volumes"))...)
+	if !c.allowHostPath() {
+		for i, v := range o.Volumes {
+			if v.HostPath != nil {
+				errs = append(errs, field.Forbidden(path.Child("volumes").Index(i).Child("hostPath"), "hostPath volumes are not allowed by the operator config (podTemplate.allowHostPath)"))
+			}
+		}
+	}
+	if sc := o.ContainerSecurityContext; sc != nil && !c.allowPrivileged() {
+		if reason := privilegedReason(sc); reason != "" {
+			errs = append(errs, field.Forbidden(path.Child("containerSecurityContext"), reason+" is not allowed by the operator config (podTemplate.allowPrivileged)"))
+		}
+	}
+	return errs
+}
+
+// privilegedReason returns what makes a container security context
+// privileged, if anything.
+func privilegedReason(sc *corev1.SecurityContext) string {
+	switch {
+	case sc.Privileged != nil && *sc.Privileged:
+		return "privileged"
+	case sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation:
+		return "allowPrivilegeEscalation"
+	case sc.Capabilities != nil && len(sc.Capabilities.Add) > 0:
+		return "capabilities.add"
+	}
+	return ""
+}
+
+// checkVolumes validates volumes, whose schema is left out of the CRD: each
+// needs a unique name and exactly one source.
+func checkVolumes(volumes []corev1.Volume, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := map[string]bool{}
+	for i, v := range volumes {
+		p := path.Index(i)
+		for _, msg := range validation.IsDNS1123Label(v.Name) {
+			errs = append(errs, field.Invalid(p.Child("name"), v.Name, msg))
+		}
+		if names[v.Name] {
+			errs = append(errs, field.Duplicate(p.Child("name"), v.Name))
+		}
+		names[v.Name] = true
+		if n := volumeSources(v.VolumeSource); n != 1 {
+			errs = append(errs, field.Invalid(p, v.Name, fmt.Sprintf("must have exactly one volume source, has %d", n)))
+		}
+	}
+	return errs
+}
+
+func volumeSources(src corev1.VolumeSource) int {
+	n := 0
+	v := reflect.ValueOf(src)
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Field(i).IsNil() {
+			n++
+		}
+	}
+	return n
+}
+
+// checkEmbeddedPods applies the pod template policy to the pod specs of an
+// embedded object, whatever its kind.
+func (c *Config) checkEmbeddedPods(raw runtime.RawExtension, path *field.Path) field.ErrorList {
+	var obj interface{}
+	switch {
+	case len(raw.Raw) > 0:
+		if err := json.Unmarshal(raw.Raw, &obj); err != nil {
+			return nil
+		}
+	case raw.Object != nil:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(raw.Object)
+		if err != nil {
+			return nil
+		}
+		obj = content
+	}
+
+	var errs field.ErrorList
+	forbid := func(what, option string) {
+		errs = append(errs, field.Forbidden(path, fmt.Sprintf("%s is not allowed by the operator config (podTemplate.%s)", what, option)))
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for key, child := range t {
+				switch key {
+				case "hostPath":
+					if _, ok := child.(map[string]interface{}); ok && !c.allowHostPath() {
+						forbid("hostPath volume", "allowHostPath")
+					}
+				case "privileged", "allowPrivilegeEscalation", "hostNetwork", "hostPID", "hostIPC":
+					if b, _ := child.(bool); b && !c.allowPrivileged() {
+						forbid(key, "allowPrivileged")
+					}
+				case "add":
+					if list, _ := child.([]interface{}); len(list) > 0 && !c.allowPrivileged() {
+						forbid("capabilities.add", "allowPrivileged")
+					}
+				case "serviceAccountName", "serviceAccount":
+					if name, _ := child.(string); name != "" {
+						if err := c.CheckServiceAccount(name); err != nil {
+							errs = append(errs, field.Forbidden(path, err.Error()))
+						}
+					}
+				}
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range t {
+				walk(child)
+			}
+		}
+	}
+	walk(obj)
+	return errs
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+func TestCheckJobPodTemplate(t *testing.T) {
+	yes := true
+	hostPath := corev1.Volume{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}}}
+	emptyDir := corev1.Volume{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}
+	tests := []struct {
+		name     string
+		policy   PodTemplatePolicy
+		overlay  *steerv1alpha1.PodTemplateOverlay
+		embedded string
+		want     []string
+	}{
+		{name: "nothing set", overlay: &steerv1alpha1.PodTemplateOverlay{Volumes: []corev1.Volume{emptyDir}}},
+		{
+			name:    "service account",
+			overlay: &steerv1alpha1.PodTemplateOverlay{ServiceAccountName: "admin"},
+			want:    []string{"spec.podTemplate.serviceAccountName", "ServiceAccount admin is not allowed"},
+		},
+		{
+			name:    "allowed service account",
+			policy:  PodTemplatePolicy{AllowedServiceAccounts: []string{"tester", "admin"}},
+			overlay: &steerv1alpha1.PodTemplateOverlay{ServiceAccountName: "admin"},
+		},
+		{
+			name:    "any service account",
+			policy:  PodTemplatePolicy{AllowedServiceAccounts: []string{"*"}},
+			overlay: &steerv1alpha1.PodTemplateOverlay{ServiceAccountName: "admin"},
+		},
+		{
+			name:    "hostPath",
+			overlay: &steerv1alpha1.PodTemplateOverlay{Volumes: []corev1.Volume{emptyDir, hostPath}},
+			want:    []string{"spec.podTemplate.volumes[1].hostPath"},
+		},
+		{
+			name:    "allowed hostPath",
+			policy:  PodTemplatePolicy{AllowHostPath: true},
+			overlay: &steerv1alpha1.PodTemplateOverlay{Volumes: []corev1.Volume{hostPath}},
+		},
+		{
+			name:    "privileged",
+			overlay: &steerv1alpha1.PodTemplateOverlay{ContainerSecurityContext: &corev1.SecurityContext{Privileged: &yes}},
+			want:    []string{"privileged is not allowed"},
+		},
+		{
+			name:    "privilege escalation",
+			overlay: &steerv1alpha1.PodTemplateOverlay{ContainerSecurityContext: &corev1.SecurityContext{AllowPrivilegeEscalation: &yes}},
+			want:    []string{"allowPrivilegeEscalation is not allowed"},
+		},
+		{
+			name: "added capabilities",
+			overlay: &steerv1alpha1.PodTemplateOverlay{ContainerSecurityContext: &corev1.SecurityContext{
+				Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN"}},
+			}},
+			want: []string{"capabilities.add is not allowed"},
+		},
+		{
+			name:    "allowed privileged",
+			policy:  PodTemplatePolicy{AllowPrivileged: true},
+			overlay: &steerv1alpha1.PodTemplateOverlay{ContainerSecurityContext: &corev1.SecurityContext{Privileged: &yes}},
+		},
+		{
+			name: "invalid volumes",
+			overlay: &steerv1alpha1.PodTemplateOverlay{Volumes: []corev1.Volume{
+				emptyDir, emptyDir, {Name: "Upper"},
+				{Name: "two", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}, Secret: &corev1.SecretVolumeSource{}}},
+			}},
+			want: []string{
+				"spec.podTemplate.volumes[1].name: Duplicate value",
+				"spec.podTemplate.volumes[2].name",
+				"has 0", "has 2",
+			},
+		},
+		{
+			name:     "embedded hostPath",
+			embedded: `{"kind":"Pod","spec":{"volumes":[{"name":"h","hostPath":{"path":"/"}}]}}`,
+			want:     []string{"spec.hooks.preTest[0].kubernetes", "hostPath volume is not allowed"},
+		},
+		{
+			name:     "embedded privileged pod",
+			embedded: `{"kind":"Job","spec":{"template":{"spec":{"hostPID":true,"serviceAccountName":"admin","containers":[{"securityContext":{"privileged":true}}]}}}}`,
+			want:     []string{"hostPID is not allowed", "privileged is not allowed", "ServiceAccount admin is not allowed"},
+		},
+		{
+			name:     "embedded data keys",
+			embedded: `{"kind":"ConfigMap","data":{"hostPath":"/","privileged":"true"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{PodTemplate: tt.policy}
+			job := &steerv1alpha1.HelmTestJob{}
+			job.Spec.PodTemplate = tt.overlay
+			if tt.embedded != "" {
+				job.Spec.Hooks.PreTest = []steerv1alpha1.Hook{{
+					Name:       "seed",
+					Type:       steerv1alpha1.HookTypeKubernetes,
+					Kubernetes: &steerv1alpha1.KubernetesHookSpec{RawExtension: runtime.RawExtension{Raw: []byte(tt.embedded)}},
+				}}
+			}
+			errs := c.CheckJob(job)
+			if len(tt.want) == 0 {
+				if len(errs) > 0 {
+					t.Fatalf("CheckJob() = %v, want no errors", errs)
+				}
+				return
+			}
+			got := errs.ToAggregate()
+			if got == nil {
+				t.Fatalf("CheckJob() = nil, want %v", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got.Error(), want) {
+					t.Errorf("CheckJob() = %v, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckJobHookPodTemplate(t *testing.T) {
+	job := &steerv1alpha1.HelmTestJob{}
+	job.Spec.Hooks.PostTest = []steerv1alpha1.Hook{{
+		Name:        "teardown",
+		Type:        steerv1alpha1.HookTypeScript,
+		PodTemplate: &steerv1alpha1.PodTemplateOverlay{ServiceAccountName: "admin"},
+	}}
+	var c *Config
+	errs := c.CheckJob(job)
+	if len(errs) != 1 || errs[0].Field != "spec.hooks.postTest[0].podTemplate.serviceAccountName" {
+		t.Fatalf("CheckJob() = %v, want the hook's serviceAccountName rejected", errs)
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// DefaultRBACRules are granted to the pods of a run when spec.rbac doesn't
+// list any rules: enough for tests to inspect the release's workloads. Pods
+// cannot be created, as they could run as any ServiceAccount of the
+// namespace; Secrets, and with them Helm's release records, are not
+// included.
+var DefaultRBACRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods/log"},
+		Verbs:     []string{"get"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"services", "endpoints", "configmaps", "persistentvolumeclaims", "events"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"apps"},
+		Resources: []string{"deployments", "statefulsets", "daemonsets", "replicasets"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"batch"},
+		Resources: []string{"jobs"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+}
+
+// allowedRBACRules returns the rules spec.rbac.rules must stay within.
+func (c *Config) allowedRBACRules() []rbacv1.PolicyRule {
+	if c == nil || len(c.AllowedRBACRules) == 0 {
+		return DefaultRBACRules
+	}
+	return c.AllowedRBACRules
+}
+
+// CheckRBACRule returns an error if rule grants anything the allowed RBAC
+// rules don't.
+func (c *Config) CheckRBACRule(rule rbacv1.PolicyRule) error {
+	if len(rule.NonResourceURLs) > 0 {
+		return fmt.Errorf("non-resource URLs cannot be granted by a Role")
+	}
+	if len(rule.APIGroups) == 0 || len(rule.Resources) == 0 || len(rule.Verbs) == 0 {
+		return fmt.Errorf("apiGroups, resources and verbs are required")
+	}
+	allowed := c.allowedRBACRules()
+	names := rule.ResourceNames
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	for _, group := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			for _, verb := range rule.Verbs {
+				for _, name := range names {
+					if !anyRuleAllows(allowed, group, resource, verb, name) {
+						return fmt.Errorf("%s %s is not allowed by the operator config (allowedRBACRules)", verb, qualifiedResource(group, resource, name))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkRBAC checks spec.rbac.rules of a HelmTestJob.
+func (c *Config) checkRBAC(job *steerv1alpha1.HelmTestJob) field.ErrorList {
+	if job.Spec.RBAC == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	path := field.NewPath("spec", "rbac", "rules")
+	for i, rule := range job.Spec.RBAC.Rules {
+		if err := c.CheckRBACRule(rule); err != nil {
+			errs = append(errs, field.Forbidden(path.Index(i), err.Error()))
+		}
+	}
+	return errs
+}
+
+func anyRuleAllows(rules []rbacv1.PolicyRule, group, resource, verb, name string) bool {
+	for _, rule := range rules {
+		if contains(rule.APIGroups, group) && resourceMatches(rule.Resources, resource) &&
+			contains(rule.Verbs, verb) && (len(rule.ResourceNames) == 0 || (name != "" && contains(rule.ResourceNames, name))) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether values has value or the wildcard. A requested
+// wildcard is only matched by a wildcard.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == rbacv1.VerbAll || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceMatches matches a resource, optionally with a subresource, the way
+// RBAC does: "*" matches everything, "*/log" any log subresource and
+// "pods/*" any subresource of pods.
+func resourceMatches(resources []string, resource string) bool {
+	base, sub, hasSub := strings.Cut(resource, "/")
+	for _, r := range resources {
+		switch {
+		case r == rbacv1.ResourceAll || r == resource:
+			return true
+		case hasSub && r == "*/"+sub:
+			return true
+		case hasSub && r == base+"/*":
+			return true
+		}
+	}
+	return false
+}
+
+func qualifiedResource(group, resource, name string) string {
+	s := resource
+	if group != "" {
+		s += "." + group
+	}
+	if name != "" {
+		s += " " + name
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DefaultWaitForKinds are the kinds waitFor hooks may watch when the
+// operator config doesn't list any: what a release is usually made of, the
+// same objects DefaultRBACRules lets runs read.
+var DefaultWaitForKinds = []string{
+	"Pod", "Service", "Endpoints", "ConfigMap", "PersistentVolumeClaim",
+	"Deployment.apps", "StatefulSet.apps", "DaemonSet.apps", "ReplicaSet.apps",
+	"Job.batch",
+}
+
+// allowedWaitForKinds returns the kinds waitFor hooks may watch.
+func (c *Config) allowedWaitForKinds() []string {
+	if c == nil || len(c.AllowedWaitForKinds) == 0 {
+		return DefaultWaitForKinds
+	}
+	return c.AllowedWaitForKinds
+}
+
+// CheckWaitForKind returns an error if waitFor hooks may not watch objects of
+// kind in apiVersion. The controller reads them with its own permissions, so
+// Secrets, and with them Helm's release records, are never allowed.
+func (c *Config) CheckWaitForKind(apiVersion, kind string) error {
+	if apiVersion == "" {
+		apiVersion = "v1"
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return fmt.Errorf("invalid apiVersion: %w", err)
+	}
+	gk := schema.GroupKind{Group: gv.Group, Kind: kind}
+	if gk == (schema.GroupKind{Kind: "Secret"}) {
+		return fmt.Errorf("waitFor cannot watch Secrets")
+	}
+	allowed := c.allowedWaitForKinds()
+	for _, a := range allowed {
+		if schema.ParseGroupKind(a) == gk {
+			return nil
+		}
+	}
+	return fmt.Errorf("kind %s is not allowed by the operator config (allowedWaitForKinds: %s)", gk, strings.Join(allowed, ", "))
+}
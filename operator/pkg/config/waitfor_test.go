@@ -0,0 +1,56 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckWaitForKind(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *Config
+		apiVersion string
+		kind       string
+		wantErr    string
+	}{
+		{name: "core kind", kind: "Pod"},
+		{name: "core kind with apiVersion", apiVersion: "v1", kind: "ConfigMap"},
+		{name: "workload", apiVersion: "apps/v1", kind: "Deployment"},
+		{name: "secret", kind: "Secret", wantErr: "waitFor cannot watch Secrets"},
+		{name: "kind of another group", apiVersion: "v1", kind: "Deployment", wantErr: "kind Deployment is not allowed"},
+		{name: "custom resource", apiVersion: "cert-manager.io/v1", kind: "Certificate", wantErr: "kind Certificate.cert-manager.io is not allowed"},
+		{
+			name:       "configured kinds",
+			cfg:        &Config{AllowedWaitForKinds: []string{"Certificate.cert-manager.io"}},
+			apiVersion: "cert-manager.io/v1",
+			kind:       "Certificate",
+		},
+		{
+			name:    "configured kinds replace the defaults",
+			cfg:     &Config{AllowedWaitForKinds: []string{"Certificate.cert-manager.io"}},
+			kind:    "Pod",
+			wantErr: "allowedWaitForKinds: Certificate.cert-manager.io",
+		},
+		{
+			name:    "secrets can't be configured",
+			cfg:     &Config{AllowedWaitForKinds: []string{"Secret"}},
+			kind:    "Secret",
+			wantErr: "waitFor cannot watch Secrets",
+		},
+		{name: "invalid apiVersion", apiVersion: "a/b/c", kind: "Pod", wantErr: "invalid apiVersion"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.CheckWaitForKind(tt.apiVersion, tt.kind)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckWaitForKind() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckWaitForKind() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
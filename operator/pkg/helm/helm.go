@@ -7,9 +7,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// Client is an abstraction over Helm operations.
-//
-// Block A note: this package intentionally does NOT depend on the Helm SDK yet.
+// Client is an abstraction over Helm operations. SDKClient implements it
+// with the Helm SDK.
 // Controllers should depend on this interface for testability.
 type Client interface {
 	InstallOrUpgrade(ctx context.Context, req InstallOrUpgradeRequest) (ReleaseInfo, error)
@@ -26,6 +25,9 @@ type InstallOrUpgradeRequest struct {
 
 	Chart  steerv1alpha1.ChartSpec
 	Values steerv1alpha1.ValuesSpec
+	// ValuesNamespace is where the ConfigMaps and Secrets of
+	// Values.ValuesFrom are, the namespace of the HelmRelease.
+	ValuesNamespace string
 
 	CreateNamespace bool
 	Timeout         metav1.Duration
@@ -62,7 +64,7 @@ type TestResult struct {
 
 // FakeClient is a simple, injectable fake implementation of Client.
 //
-// It is intended for controller tests in envtest without pulling Helm SDK.
+// It is intended for controller tests in envtest without a Helm SDK client.
 type FakeClient struct {
 	InstallOrUpgradeFunc func(ctx context.Context, req InstallOrUpgradeRequest) (ReleaseInfo, error)
 	UninstallFunc        func(ctx context.Context, req UninstallRequest) error
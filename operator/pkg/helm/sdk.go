@@ -0,0 +1,239 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// defaultTimeout bounds Helm operations of requests without a timeout.
+const defaultTimeout = 5 * time.Minute
+
+// Config enables the Helm client of the operator.
+type Config struct {
+	// Enabled installs, tests and uninstalls releases with the Helm SDK.
+	// Without it HelmTestJobs with spec.environment ephemeral are rejected.
+	// The operator needs the rights to create whatever the charts contain.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// CacheDir caches the charts pulled from repositories. Defaults to a
+	// directory in the temp dir.
+	CacheDir string `json:"cacheDir,omitempty"`
+}
+
+// New returns the Helm client described by cfg, or nil when it is not
+// enabled. The values of releases are read with reader.
+func New(cfg Config, restConfig *rest.Config, reader client.Reader) (Client, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = filepath.Join(os.TempDir(), "steer-helm")
+	}
+	if err := os.MkdirAll(cfg.CacheDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create helm cache dir: %w", err)
+	}
+	settings := cli.New()
+	settings.RepositoryCache = filepath.Join(cfg.CacheDir, "repository")
+	settings.RepositoryConfig = filepath.Join(cfg.CacheDir, "repositories.yaml")
+	settings.RegistryConfig = filepath.Join(cfg.CacheDir, "registry.json")
+	return &SDKClient{restConfig: restConfig, reader: reader, settings: settings}, nil
+}
+
+// SDKClient runs Helm actions in-process with the Helm SDK. Releases are
+// stored as Secrets in their namespace, as the helm CLI does.
+type SDKClient struct {
+	restConfig *rest.Config
+	reader     client.Reader
+	settings   *cli.EnvSettings
+}
+
+var _ Client = &SDKClient{}
+
+// InstallOrUpgrade installs the release, or upgrades it if it exists, and
+// waits until its resources are ready.
+func (c *SDKClient) InstallOrUpgrade(ctx context.Context, req InstallOrUpgradeRequest) (ReleaseInfo, error) {
+	cfg, err := c.actionConfig(ctx, req.Namespace)
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+	chrt, err := c.loadChart(req.Chart)
+	if err != nil {
+		return ReleaseInfo{}, fmt.Errorf("load chart: %w", err)
+	}
+	values, err := Values(ctx, c.reader, req.ValuesNamespace, req.Values)
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+	timeout := durationOr(req.Timeout.Duration)
+
+	history := action.NewHistory(cfg)
+	history.Max = 1
+	if _, err := history.Run(req.ReleaseName); errors.Is(err, driver.ErrReleaseNotFound) {
+		install := action.NewInstall(cfg)
+		install.ReleaseName = req.ReleaseName
+		install.Namespace = req.Namespace
+		install.CreateNamespace = req.CreateNamespace
+		install.Wait = true
+		install.Timeout = timeout
+		rel, err := install.RunWithContext(ctx, chrt, values)
+		if err != nil {
+			return ReleaseInfo{}, fmt.Errorf("install %s/%s: %w", req.Namespace, req.ReleaseName, err)
+		}
+		return ReleaseInfo{Name: rel.Name, Namespace: rel.Namespace, Version: int64(rel.Version), Status: rel.Info.Status.String()}, nil
+	} else if err != nil {
+		return ReleaseInfo{}, fmt.Errorf("get history of %s/%s: %w", req.Namespace, req.ReleaseName, err)
+	}
+
+	upgrade := action.NewUpgrade(cfg)
+	upgrade.Namespace = req.Namespace
+	upgrade.Wait = true
+	upgrade.Timeout = timeout
+	rel, err := upgrade.RunWithContext(ctx, req.ReleaseName, chrt, values)
+	if err != nil {
+		return ReleaseInfo{}, fmt.Errorf("upgrade %s/%s: %w", req.Namespace, req.ReleaseName, err)
+	}
+	return ReleaseInfo{Name: rel.Name, Namespace: rel.Namespace, Version: int64(rel.Version), Status: rel.Info.Status.String()}, nil
+}
+
+// Uninstall uninstalls the release. A release that doesn't exist is
+// already uninstalled.
+func (c *SDKClient) Uninstall(ctx context.Context, req UninstallRequest) error {
+	cfg, err := c.actionConfig(ctx, req.Namespace)
+	if err != nil {
+		return err
+	}
+	uninstall := action.NewUninstall(cfg)
+	uninstall.IgnoreNotFound = true
+	uninstall.Timeout = durationOr(req.Timeout.Duration)
+	if _, err := uninstall.Run(req.ReleaseName); err != nil {
+		return fmt.Errorf("uninstall %s/%s: %w", req.Namespace, req.ReleaseName, err)
+	}
+	return nil
+}
+
+// Test runs the test hooks of the release and returns their logs.
+func (c *SDKClient) Test(ctx context.Context, req TestRequest) (TestResult, error) {
+	cfg, err := c.actionConfig(ctx, req.Namespace)
+	if err != nil {
+		return TestResult{}, err
+	}
+	test := action.NewReleaseTesting(cfg)
+	test.Namespace = req.Namespace
+	test.Timeout = durationOr(req.Timeout.Duration)
+	if req.Filter != "" {
+		test.Filters[action.IncludeNameFilter] = strings.Split(req.Filter, ",")
+	}
+	rel, runErr := test.Run(req.ReleaseName)
+	if rel == nil {
+		return TestResult{}, fmt.Errorf("test %s/%s: %w", req.Namespace, req.ReleaseName, runErr)
+	}
+	var logs strings.Builder
+	if err := test.GetPodLogs(&logs, rel); err != nil {
+		logf.FromContext(ctx).Error(err, "failed to get the logs of the helm test pods", "release", req.ReleaseName)
+	}
+	return TestResult{Succeeded: runErr == nil, Logs: splitLines(logs.String())}, nil
+}
+
+func (c *SDKClient) actionConfig(ctx context.Context, namespace string) (*action.Configuration, error) {
+	logger := logf.FromContext(ctx).WithName("helm")
+	cfg := &action.Configuration{}
+	getter := &restClientGetter{config: c.restConfig, namespace: namespace}
+	if err := cfg.Init(getter, namespace, "secret", func(format string, v ...interface{}) {
+		logger.V(1).Info(fmt.Sprintf(format, v...))
+	}); err != nil {
+		return nil, fmt.Errorf("init helm: %w", err)
+	}
+	return cfg, nil
+}
+
+func (c *SDKClient) loadChart(spec steerv1alpha1.ChartSpec) (*chart.Chart, error) {
+	switch spec.Source {
+	case steerv1alpha1.ChartSourceLocal:
+		if spec.Local == nil {
+			return nil, fmt.Errorf("chart.local is required when source=local")
+		}
+		return loader.Load(spec.Local.Path)
+	case steerv1alpha1.ChartSourceRepository, "":
+		if spec.Repository == nil {
+			return nil, fmt.Errorf("chart.repository is required when source=repository")
+		}
+		opts := action.ChartPathOptions{RepoURL: spec.Repository.URL, Version: spec.Repository.Version}
+		path, err := opts.LocateChart(spec.Repository.Name, c.settings)
+		if err != nil {
+			return nil, err
+		}
+		return loader.Load(path)
+	default:
+		return nil, fmt.Errorf("chart source %q is not supported", spec.Source)
+	}
+}
+
+func durationOr(d time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultTimeout
+	}
+	return d
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// restClientGetter hands Helm the REST config of the operator instead of a
+// kubeconfig file.
+type restClientGetter struct {
+	config    *rest.Config
+	namespace string
+}
+
+func (g *restClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return rest.CopyConfig(g.config), nil
+}
+
+func (g *restClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(g.config)
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewMemCacheClient(dc), nil
+}
+
+func (g *restClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	dc, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	return restmapper.NewDeferredDiscoveryRESTMapper(dc), nil
+}
+
+func (g *restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return clientcmd.NewDefaultClientConfig(clientcmdapi.Config{}, &clientcmd.ConfigOverrides{
+		Context: clientcmdapi.Context{Namespace: g.namespace},
+	})
+}
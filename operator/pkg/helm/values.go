@@ -0,0 +1,83 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// Values returns the values of a release: the documents of spec.valuesFrom
+// in order, then spec.inline, each merged over the ones before. The
+// ConfigMaps and Secrets are read from namespace.
+func Values(ctx context.Context, c client.Reader, namespace string, spec steerv1alpha1.ValuesSpec) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, src := range spec.ValuesFrom {
+		raw, err := valuesSource(ctx, c, namespace, src)
+		if err != nil {
+			return nil, err
+		}
+		if err := mergeValues(values, raw); err != nil {
+			return nil, err
+		}
+	}
+	if err := mergeValues(values, []byte(spec.Inline)); err != nil {
+		return nil, fmt.Errorf("inline values: %w", err)
+	}
+	return values, nil
+}
+
+func valuesSource(ctx context.Context, c client.Reader, namespace string, src steerv1alpha1.ValuesSource) ([]byte, error) {
+	switch {
+	case src.ConfigMapKeyRef != nil:
+		ref := src.ConfigMapKeyRef
+		var cm corev1.ConfigMap
+		if err := c.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: namespace}, &cm); err != nil {
+			return nil, fmt.Errorf("values from configmap %s/%s: %w", namespace, ref.Name, err)
+		}
+		raw, ok := cm.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("values from configmap %s/%s: no key %q", namespace, ref.Name, ref.Key)
+		}
+		return []byte(raw), nil
+	case src.SecretKeyRef != nil:
+		ref := src.SecretKeyRef
+		var secret corev1.Secret
+		if err := c.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: namespace}, &secret); err != nil {
+			return nil, fmt.Errorf("values from secret %s/%s: %w", namespace, ref.Name, err)
+		}
+		raw, ok := secret.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("values from secret %s/%s: no key %q", namespace, ref.Name, ref.Key)
+		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("valuesFrom requires configMapKeyRef or secretKeyRef")
+}
+
+// mergeValues merges a YAML document of values into values. Nested maps are
+// merged, anything else is replaced.
+func mergeValues(values map[string]interface{}, raw []byte) error {
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	mergeMaps(values, doc)
+	return nil
+}
+
+func mergeMaps(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if m, ok := v.(map[string]interface{}); ok {
+			if d, ok := dst[k].(map[string]interface{}); ok {
+				mergeMaps(d, m)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
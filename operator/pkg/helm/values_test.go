@@ -0,0 +1,110 @@
+package helm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+func TestValues(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "values", Namespace: "default"},
+			Data:       map[string]string{"values.yaml": "image:\n  repository: nginx\n  tag: \"1.25\"\nreplicas: 2\n"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+			Data:       map[string][]byte{"values.yaml": []byte("password: secret\nimage:\n  tag: \"1.26\"\n")},
+		},
+	).Build()
+	configMap := steerv1alpha1.ValuesSource{ConfigMapKeyRef: &steerv1alpha1.ConfigMapKeySelector{Name: "values", Key: "values.yaml"}}
+	secret := steerv1alpha1.ValuesSource{SecretKeyRef: &steerv1alpha1.SecretKeySelector{Name: "credentials", Key: "values.yaml"}}
+
+	tests := []struct {
+		name      string
+		namespace string
+		spec      steerv1alpha1.ValuesSpec
+		want      map[string]interface{}
+		wantErr   string
+	}{
+		{name: "empty", namespace: "default", want: map[string]interface{}{}},
+		{
+			name:      "inline",
+			namespace: "default",
+			spec:      steerv1alpha1.ValuesSpec{Inline: "replicas: 1"},
+			want:      map[string]interface{}{"replicas": float64(1)},
+		},
+		{
+			name:      "merges in order",
+			namespace: "default",
+			spec: steerv1alpha1.ValuesSpec{
+				Inline:     "replicas: 3",
+				ValuesFrom: []steerv1alpha1.ValuesSource{configMap, secret},
+			},
+			want: map[string]interface{}{
+				"image":    map[string]interface{}{"repository": "nginx", "tag": "1.26"},
+				"password": "secret",
+				"replicas": float64(3),
+			},
+		},
+		{
+			name:      "reads from the namespace",
+			namespace: "other",
+			spec:      steerv1alpha1.ValuesSpec{ValuesFrom: []steerv1alpha1.ValuesSource{configMap}},
+			wantErr:   `values from configmap other/values: configmaps "values" not found`,
+		},
+		{
+			name:      "missing key",
+			namespace: "default",
+			spec: steerv1alpha1.ValuesSpec{ValuesFrom: []steerv1alpha1.ValuesSource{
+				{SecretKeyRef: &steerv1alpha1.SecretKeySelector{Name: "credentials", Key: "other.yaml"}},
+			}},
+			wantErr: `values from secret default/credentials: no key "other.yaml"`,
+		},
+		{
+			name:      "invalid inline",
+			namespace: "default",
+			spec:      steerv1alpha1.ValuesSpec{Inline: "- a list"},
+			wantErr:   "inline values:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Values(context.Background(), c, tt.namespace, tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Values() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Values() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Values() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	c, err := New(Config{}, nil, nil)
+	if err != nil || c != nil {
+		t.Fatalf("New() = %v, %v, want no client when disabled", c, err)
+	}
+	c, err = New(Config{Enabled: true, CacheDir: t.TempDir()}, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, ok := c.(*SDKClient); !ok {
+		t.Errorf("New() = %T, want *SDKClient", c)
+	}
+}
@@ -0,0 +1,207 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+const defaultChaosDuration = 30 * time.Second
+
+// executeChaos disrupts workloads in the target namespace of the release.
+// Deleting pods is done in one call; scaling a Deployment to zero and back
+// progresses over several calls using the disruption recorded in the
+// request status.
+func (e *JobExecutor) executeChaos(ctx context.Context, req ExecuteRequest) (Result, error) {
+	spec := req.Hook.Chaos
+	if spec == nil {
+		return Result{}, fmt.Errorf("hook %q: chaos is required when type=chaos", req.Hook.Name)
+	}
+
+	now := time.Now()
+	res := Result{
+		Phase:      steerv1alpha1.HelmTestJobPhaseRunning,
+		StartedAt:  &now,
+		Message:    req.Status.Message,
+		Disruption: req.Status.Disruption.DeepCopy(),
+	}
+	if req.Status.StartedAt != nil {
+		res.StartedAt = timePtr(req.Status.StartedAt)
+	}
+	if res.Disruption == nil {
+		namespace, err := newTemplateData(ctx, e.Client, req).releaseNamespace()
+		if err != nil {
+			return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+		}
+		res.Disruption = &steerv1alpha1.HookDisruption{Action: spec.Action, Namespace: namespace}
+	}
+
+	var err error
+	switch spec.Action {
+	case steerv1alpha1.ChaosActionDeletePods:
+		err = e.deletePods(ctx, spec, &res, now)
+	case steerv1alpha1.ChaosActionScaleToZero:
+		err = e.scaleToZero(ctx, req, spec, &res, now)
+	default:
+		err = fmt.Errorf("unsupported chaos action %q", spec.Action)
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+	if isFinished(res.Phase) {
+		res.CompletedAt = &now
+	}
+	return res, nil
+}
+
+// deletePods deletes up to count running pods matching the selector, in
+// name order so repeated runs disrupt the same way.
+func (e *JobExecutor) deletePods(ctx context.Context, spec *steerv1alpha1.ChaosHookSpec, res *Result, now time.Time) error {
+	d := res.Disruption
+	if d.DisruptedAt != nil {
+		res.Phase = steerv1alpha1.HelmTestJobPhaseSucceeded
+		return nil
+	}
+	if spec.Selector == nil {
+		return fmt.Errorf("deletePods requires a selector")
+	}
+	selector, err := metav1.LabelSelectorAsSelector(spec.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	var pods corev1.PodList
+	if err := e.Client.List(ctx, &pods, client.InNamespace(d.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	count := int(spec.Count)
+	if count < 1 {
+		count = 1
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if len(d.Targets) == count {
+			break
+		}
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		if err := e.Client.Delete(ctx, pod); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		d.Targets = append(d.Targets, pod.Name)
+	}
+	if len(d.Targets) == 0 {
+		res.Phase = steerv1alpha1.HelmTestJobPhaseFailed
+		res.Message = fmt.Sprintf("no running pods match the selector in %s", d.Namespace)
+		return nil
+	}
+	d.DisruptedAt = &metav1.Time{Time: now}
+	res.Phase = steerv1alpha1.HelmTestJobPhaseSucceeded
+	res.Message = fmt.Sprintf("deleted %d pod(s) in %s", len(d.Targets), d.Namespace)
+	return nil
+}
+
+// scaleToZero scales the Deployment to zero, waits for the duration, scales
+// it back to its replicas and waits until they are ready. The Deployment is
+// restored early when the hook times out or the run is stopped.
+func (e *JobExecutor) scaleToZero(ctx context.Context, req ExecuteRequest, spec *steerv1alpha1.ChaosHookSpec, res *Result, now time.Time) error {
+	d := res.Disruption
+	if spec.Deployment == "" {
+		return fmt.Errorf("scaleToZero requires a deployment")
+	}
+	var deploy appsv1.Deployment
+	if err := e.Client.Get(ctx, types.NamespacedName{Name: spec.Deployment, Namespace: d.Namespace}, &deploy); err != nil {
+		if apierrors.IsNotFound(err) && d.DisruptedAt != nil {
+			// Nothing is left to restore.
+			if d.RestoredAt == nil {
+				d.RestoredAt = &metav1.Time{Time: now}
+			}
+			res.Phase = steerv1alpha1.HelmTestJobPhaseFailed
+			res.Message = fmt.Sprintf("deployment %s/%s was deleted", d.Namespace, spec.Deployment)
+			return nil
+		}
+		return fmt.Errorf("failed to get deployment %s/%s: %w", d.Namespace, spec.Deployment, err)
+	}
+	timedOut := req.Hook.Timeout != nil && req.Hook.Timeout.Duration > 0 && now.Sub(*res.StartedAt) >= req.Hook.Timeout.Duration
+
+	switch {
+	case d.DisruptedAt == nil:
+		replicas := int32(1)
+		if deploy.Spec.Replicas != nil {
+			replicas = *deploy.Spec.Replicas
+		}
+		if err := scaleDeployment(ctx, e.Client, &deploy, 0); err != nil {
+			return err
+		}
+		d.Targets = []string{deploy.Name}
+		d.OriginalReplicas = &replicas
+		d.DisruptedAt = &metav1.Time{Time: now}
+		res.Message = fmt.Sprintf("scaled deployment %s from %d to 0", deploy.Name, replicas)
+		return nil
+
+	case d.RestoredAt == nil:
+		if !timedOut && !req.Stop && now.Sub(d.DisruptedAt.Time) < durationOr(spec.Duration.Duration, defaultChaosDuration) {
+			return nil
+		}
+		if err := scaleDeployment(ctx, e.Client, &deploy, *d.OriginalReplicas); err != nil {
+			return err
+		}
+		d.RestoredAt = &metav1.Time{Time: now}
+		res.Message = fmt.Sprintf("scaled deployment %s back to %d", deploy.Name, *d.OriginalReplicas)
+	}
+
+	if deploy.Status.ObservedGeneration >= deploy.Generation && deploy.Status.ReadyReplicas >= *d.OriginalReplicas {
+		res.Phase = steerv1alpha1.HelmTestJobPhaseSucceeded
+		res.Message = fmt.Sprintf("deployment %s recovered with %d ready replicas", deploy.Name, deploy.Status.ReadyReplicas)
+		return nil
+	}
+	if timedOut {
+		res.Phase = steerv1alpha1.HelmTestJobPhaseFailed
+		res.Message = fmt.Sprintf("timed out after %s: deployment %s has %d/%d ready replicas", req.Hook.Timeout.Duration, deploy.Name, deploy.Status.ReadyReplicas, *d.OriginalReplicas)
+	}
+	return nil
+}
+
+// RestoreDisruption scales a Deployment a chaos hook left scaled to zero back
+// to its replicas and records when, without waiting for them to be ready. It
+// is used when a run ends before the hook could restore it itself. A
+// Deployment that is gone has nothing left to restore.
+func RestoreDisruption(ctx context.Context, c client.Client, d *steerv1alpha1.HookDisruption, now time.Time) error {
+	if d == nil || d.Action != steerv1alpha1.ChaosActionScaleToZero || d.DisruptedAt == nil || d.RestoredAt != nil || len(d.Targets) == 0 || d.OriginalReplicas == nil {
+		return nil
+	}
+	var deploy appsv1.Deployment
+	err := c.Get(ctx, types.NamespacedName{Name: d.Targets[0], Namespace: d.Namespace}, &deploy)
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return fmt.Errorf("failed to get deployment %s/%s: %w", d.Namespace, d.Targets[0], err)
+	default:
+		if err := scaleDeployment(ctx, c, &deploy, *d.OriginalReplicas); err != nil {
+			return err
+		}
+	}
+	d.RestoredAt = &metav1.Time{Time: now}
+	return nil
+}
+
+func scaleDeployment(ctx context.Context, c client.Client, deploy *appsv1.Deployment, replicas int32) error {
+	patch := client.MergeFrom(deploy.DeepCopy())
+	deploy.Spec.Replicas = &replicas
+	if err := c.Patch(ctx, deploy, patch); err != nil {
+		return fmt.Errorf("failed to scale deployment %s/%s to %d: %w", deploy.Namespace, deploy.Name, replicas, err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package hooks
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// statusOf records a result the way the controller does, so the next call
+// continues from it.
+func statusOf(res Result) steerv1alpha1.HookResult {
+	status := steerv1alpha1.HookResult{Name: res.Name, Phase: res.Phase, Message: res.Message, Disruption: res.Disruption}
+	if res.StartedAt != nil {
+		status.StartedAt = &metav1.Time{Time: *res.StartedAt}
+	}
+	return status
+}
+
+func TestExecuteChaosDeletePods(t *testing.T) {
+	pod := func(name, app string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testReleaseNamespace, Labels: map[string]string{"app": app}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	tests := []struct {
+		name        string
+		count       int32
+		objects     []client.Object
+		wantPhase   steerv1alpha1.HelmTestJobPhase
+		wantTargets []string
+		wantMessage string
+	}{
+		{
+			name:        "deletes the first running pods by name",
+			count:       2,
+			objects:     []client.Object{pod("web-c", "web", corev1.PodRunning), pod("web-a", "web", corev1.PodRunning), pod("web-b", "web", corev1.PodPending), pod("web-d", "web", corev1.PodRunning), pod("db-a", "db", corev1.PodRunning)},
+			wantPhase:   steerv1alpha1.HelmTestJobPhaseSucceeded,
+			wantTargets: []string{"web-a", "web-c"},
+			wantMessage: "deleted 2 pod(s) in app",
+		},
+		{
+			name:        "defaults to one pod",
+			objects:     []client.Object{pod("web-b", "web", corev1.PodRunning), pod("web-a", "web", corev1.PodRunning)},
+			wantPhase:   steerv1alpha1.HelmTestJobPhaseSucceeded,
+			wantTargets: []string{"web-a"},
+		},
+		{
+			name:        "fails without running pods",
+			objects:     []client.Object{pod("web-a", "web", corev1.PodPending)},
+			wantPhase:   steerv1alpha1.HelmTestJobPhaseFailed,
+			wantMessage: "no running pods match the selector in app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestExecutor(t, tt.objects...)
+			req := testRequest(steerv1alpha1.Hook{Name: "kill", Type: steerv1alpha1.HookTypeChaos, Chaos: &steerv1alpha1.ChaosHookSpec{
+				Action:   steerv1alpha1.ChaosActionDeletePods,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				Count:    tt.count,
+			}})
+			res, err := e.Execute(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if res.Phase != tt.wantPhase || !strings.Contains(res.Message, tt.wantMessage) {
+				t.Errorf("Execute() = %s %q, want %s %q", res.Phase, res.Message, tt.wantPhase, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(res.Disruption.Targets, tt.wantTargets) {
+				t.Errorf("Execute() deleted %v, want %v", res.Disruption.Targets, tt.wantTargets)
+			}
+			for _, name := range tt.wantTargets {
+				err := e.Client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: testReleaseNamespace}, &corev1.Pod{})
+				if client.IgnoreNotFound(err) != nil || err == nil {
+					t.Errorf("pod %s was not deleted: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteChaosScaleToZero(t *testing.T) {
+	replicas := int32(3)
+	newDeployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: testReleaseNamespace, Generation: 1},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, ReadyReplicas: replicas},
+		}
+	}
+	hook := steerv1alpha1.Hook{Name: "outage", Type: steerv1alpha1.HookTypeChaos, Chaos: &steerv1alpha1.ChaosHookSpec{
+		Action:     steerv1alpha1.ChaosActionScaleToZero,
+		Deployment: "web",
+		Duration:   metav1.Duration{Duration: time.Hour},
+	}}
+	ctx := context.Background()
+
+	getDeployment := func(t *testing.T, e *JobExecutor) *appsv1.Deployment {
+		t.Helper()
+		deploy := &appsv1.Deployment{}
+		if err := e.Client.Get(ctx, types.NamespacedName{Name: "web", Namespace: testReleaseNamespace}, deploy); err != nil {
+			t.Fatal(err)
+		}
+		return deploy
+	}
+	// setReady stands in for the Deployment controller.
+	setReady := func(t *testing.T, e *JobExecutor, ready int32) {
+		t.Helper()
+		deploy := getDeployment(t, e)
+		deploy.Status.ObservedGeneration = deploy.Generation
+		deploy.Status.ReadyReplicas = ready
+		if err := e.Client.Status().Update(ctx, deploy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scaleDown := func(t *testing.T, e *JobExecutor) ExecuteRequest {
+		t.Helper()
+		req := testRequest(hook)
+		res, err := e.Execute(ctx, req)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if res.Phase != steerv1alpha1.HelmTestJobPhaseRunning || *res.Disruption.OriginalReplicas != replicas {
+			t.Fatalf("Execute() = %s %+v, want Running with 3 original replicas", res.Phase, res.Disruption)
+		}
+		if got := *getDeployment(t, e).Spec.Replicas; got != 0 {
+			t.Fatalf("deployment has %d replicas, want 0", got)
+		}
+		setReady(t, e, 0)
+		req.Status = statusOf(res)
+		return req
+	}
+
+	t.Run("waits for the duration", func(t *testing.T) {
+		e := newTestExecutor(t, newDeployment())
+		req := scaleDown(t, e)
+		res, err := e.Execute(ctx, req)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if res.Phase != steerv1alpha1.HelmTestJobPhaseRunning || res.Disruption.RestoredAt != nil {
+			t.Errorf("Execute() = %s %+v, want Running and not restored", res.Phase, res.Disruption)
+		}
+	})
+
+	t.Run("restores on stop and waits for the pods", func(t *testing.T) {
+		e := newTestExecutor(t, newDeployment())
+		req := scaleDown(t, e)
+
+		req.Stop = true
+		res, err := e.Execute(ctx, req)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if res.Phase != steerv1alpha1.HelmTestJobPhaseRunning || res.Disruption.RestoredAt == nil {
+			t.Fatalf("Execute() = %s %+v, want Running and restored", res.Phase, res.Disruption)
+		}
+		if got := *getDeployment(t, e).Spec.Replicas; got != replicas {
+			t.Fatalf("deployment has %d replicas, want %d", got, replicas)
+		}
+
+		// The pods are recreated.
+		setReady(t, e, replicas)
+		req.Status = statusOf(res)
+		res, err = e.Execute(ctx, req)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if res.Phase != steerv1alpha1.HelmTestJobPhaseSucceeded || res.CompletedAt == nil {
+			t.Errorf("Execute() = %s %q, want Succeeded", res.Phase, res.Message)
+		}
+	})
+
+	t.Run("fails when not recovered before the timeout", func(t *testing.T) {
+		e := newTestExecutor(t, newDeployment())
+		timed := hook
+		timed.Timeout = &metav1.Duration{Duration: time.Minute}
+		req := scaleDown(t, e)
+		req.Hook = timed
+		req.Status.StartedAt = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+
+		res, err := e.Execute(ctx, req)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if res.Phase != steerv1alpha1.HelmTestJobPhaseFailed || !strings.Contains(res.Message, "0/3 ready replicas") {
+			t.Errorf("Execute() = %s %q, want Failed with 0/3 ready replicas", res.Phase, res.Message)
+		}
+		if got := *getDeployment(t, e).Spec.Replicas; got != replicas {
+			t.Errorf("deployment has %d replicas, want %d", got, replicas)
+		}
+	})
+
+	t.Run("restores without the hook", func(t *testing.T) {
+		e := newTestExecutor(t, newDeployment())
+		req := scaleDown(t, e)
+		d := req.Status.Disruption.DeepCopy()
+		if err := RestoreDisruption(ctx, e.Client, d, time.Now()); err != nil {
+			t.Fatalf("RestoreDisruption() error = %v", err)
+		}
+		if d.RestoredAt == nil {
+			t.Errorf("RestoreDisruption() left RestoredAt unset")
+		}
+		if got := *getDeployment(t, e).Spec.Replicas; got != replicas {
+			t.Errorf("deployment has %d replicas, want %d", got, replicas)
+		}
+	})
+
+	t.Run("has nothing to restore once the deployment is gone", func(t *testing.T) {
+		e := newTestExecutor(t, newDeployment())
+		req := scaleDown(t, e)
+		if err := e.Client.Delete(ctx, getDeployment(t, e)); err != nil {
+			t.Fatal(err)
+		}
+		res, err := e.Execute(ctx, req)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if res.Phase != steerv1alpha1.HelmTestJobPhaseFailed || res.Disruption.RestoredAt == nil {
+			t.Errorf("Execute() = %s %+v, want Failed and nothing left to restore", res.Phase, res.Disruption)
+		}
+		d := req.Status.Disruption.DeepCopy()
+		if err := RestoreDisruption(ctx, e.Client, d, time.Now()); err != nil || d.RestoredAt == nil {
+			t.Errorf("RestoreDisruption() = %v, RestoredAt %v, want it restored", err, d.RestoredAt)
+		}
+	})
+}
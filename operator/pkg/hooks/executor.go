@@ -0,0 +1,693 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+	"github.com/MrLYC/steer/operator/pkg/logsink"
+)
+
+// maxLogBytes bounds how much of a response body is read.
+const maxLogBytes = 4096
+
+// JobExecutor runs hooks as Kubernetes objects in the owner's namespace.
+//
+// Script hooks run in a Job using the request image. Kubernetes hooks create
+// the embedded object; Jobs and Pods are tracked until they finish, any other
+// kind is considered done once it has been created. HTTP, waitFor and chaos
+// hooks are run from the controller itself. Hooks running in a pod pass outputs back
+// through the termination message of their first container.
+type JobExecutor struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+	// Clientset is used to read pod logs. Logs are not collected when nil.
+	Clientset kubernetes.Interface
+	// LogSink stores the full logs of hook pods. Only the tail is kept in
+	// the result when nil.
+	LogSink logsink.Sink
+	// HTTPClient sends the requests of http hooks; it never follows
+	// redirects. Defaults to an http.Client with default settings.
+	HTTPClient *http.Client
+}
+
+func NewJobExecutor(c client.Client, scheme *runtime.Scheme, clientset kubernetes.Interface) *JobExecutor {
+	return &JobExecutor{Client: c, Scheme: scheme, Clientset: clientset}
+}
+
+func (e *JobExecutor) Execute(ctx context.Context, req ExecuteRequest) (Result, error) {
+	if req.Owner == nil {
+		return Result{}, fmt.Errorf("hook %q: owner is required", req.Hook.Name)
+	}
+
+	name := JobName(req.Owner.Name, req.RunKey, req.Stage, req.Index)
+	var (
+		res Result
+		err error
+	)
+	switch req.Hook.Type {
+	case steerv1alpha1.HookTypeScript:
+		res, err = e.executeScript(ctx, req, name)
+	case steerv1alpha1.HookTypeKubernetes:
+		res, err = e.executeObject(ctx, req, name)
+	case steerv1alpha1.HookTypeHTTP:
+		res, err = e.executeHTTP(ctx, req)
+	case steerv1alpha1.HookTypeWaitFor:
+		res, err = e.executeWaitFor(ctx, req)
+	case steerv1alpha1.HookTypeChaos:
+		res, err = e.executeChaos(ctx, req)
+	default:
+		return Result{}, fmt.Errorf("unsupported hook.type %q", req.Hook.Type)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	res.Name = req.Hook.Name
+	res.Stage = req.Stage
+	return res, nil
+}
+
+// NewestPod returns the most recently created of pods, the one of the last
+// attempt of a Job. Pods created in the same second are told apart by their
+// start time.
+func NewestPod(pods []corev1.Pod) *corev1.Pod {
+	var newest *corev1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		switch {
+		case newest == nil, newest.CreationTimestamp.Before(&pod.CreationTimestamp):
+			newest = pod
+		case newest.CreationTimestamp.Equal(&pod.CreationTimestamp) && pod.Status.StartTime != nil &&
+			(newest.Status.StartTime == nil || newest.Status.StartTime.Before(pod.Status.StartTime)):
+			newest = pod
+		}
+	}
+	return newest
+}
+
+// observePod fills in the pod the hook ran in, its exit code and, once the
+// hook is finished, its logs. Pods are selected by name when podName is set,
+// otherwise by the owning Job.
+func (e *JobExecutor) observePod(ctx context.Context, res *Result, namespace, podName, jobName, logKey string) error {
+	var pod *corev1.Pod
+	if podName != "" {
+		var p corev1.Pod
+		if err := e.Client.Get(ctx, types.NamespacedName{Name: podName, Namespace: namespace}, &p); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		pod = &p
+	} else {
+		var pods corev1.PodList
+		if err := e.Client.List(ctx, &pods, client.InNamespace(namespace), client.MatchingLabels{batchv1.JobNameLabel: jobName}); err != nil {
+			return err
+		}
+		pod = NewestPod(pods.Items)
+	}
+	if pod == nil || len(pod.Spec.Containers) == 0 {
+		return nil
+	}
+
+	res.PodName = pod.Name
+	if !isFinished(res.Phase) {
+		// Don't wait for the deadline when the pod can never start.
+		if reason := UnrecoverablePodReason(pod); reason != "" {
+			now := time.Now()
+			res.Phase = steerv1alpha1.HelmTestJobPhaseFailed
+			res.Message = reason
+			res.CompletedAt = &now
+		}
+	}
+	container := pod.Spec.Containers[0].Name
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == container {
+			res.ImageID = cs.ImageID
+		}
+		if cs.Name == container && cs.State.Terminated != nil {
+			exitCode := cs.State.Terminated.ExitCode
+			res.ExitCode = &exitCode
+			if res.Phase == steerv1alpha1.HelmTestJobPhaseSucceeded {
+				res.Outputs = ParseOutputs(cs.State.Terminated.Message)
+			}
+		}
+	}
+
+	if e.Clientset == nil || !isFinished(res.Phase) {
+		return nil
+	}
+	tail, ref, err := logsink.Collect(ctx, e.Clientset, e.LogSink, namespace, pod.Name, container, logKey)
+	if err != nil && e.LogSink != nil {
+		// Keep at least the tail when the sink is unavailable.
+		tail, ref, err = logsink.Collect(ctx, e.Clientset, nil, namespace, pod.Name, container, logKey)
+	}
+	if err != nil {
+		// Logs are best effort, the pod may already be gone.
+		return nil
+	}
+	res.Logs, res.LogRef = tail, ref
+	return nil
+}
+
+// logKey returns where the full log of a hook is stored.
+func logKey(req ExecuteRequest) string {
+	return logsink.Key(req.Owner.Namespace, req.Owner.Name, req.RunKey, string(req.Stage), req.Hook.Name)
+}
+
+// unrecoverableWaitingReasons are container waiting reasons that won't
+// resolve without changing the pod spec or the cluster.
+var unrecoverableWaitingReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+}
+
+// UnrecoverablePodReason describes why a pod can never start its containers,
+// e.g. because its image can't be pulled. It returns "" otherwise.
+func UnrecoverablePodReason(pod *corev1.Pod) string {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		w := cs.State.Waiting
+		if w == nil || !unrecoverableWaitingReasons[w.Reason] {
+			continue
+		}
+		if w.Message == "" {
+			return fmt.Sprintf("container %q: %s", cs.Name, w.Reason)
+		}
+		return fmt.Sprintf("container %q: %s: %s", cs.Name, w.Reason, w.Message)
+	}
+	return ""
+}
+
+func isFinished(phase steerv1alpha1.HelmTestJobPhase) bool {
+	return phase == steerv1alpha1.HelmTestJobPhaseSucceeded || phase == steerv1alpha1.HelmTestJobPhaseFailed
+}
+
+// pace spaces the attempts of hooks the executor runs itself, using the
+// progress recorded in the request status. It returns the result so far and
+// whether an attempt is due now; the attempt is already counted. A hook
+// whose timeout has passed is failed instead. A zero defaultTimeout means
+// no timeout unless the hook sets one.
+func pace(req ExecuteRequest, interval, defaultTimeout time.Duration, now time.Time) (Result, bool) {
+	res := Result{
+		Phase:     steerv1alpha1.HelmTestJobPhaseRunning,
+		StartedAt: &now,
+		Attempts:  req.Status.Attempts,
+		Message:   req.Status.Message,
+		Logs:      req.Status.Logs,
+	}
+	if req.Status.StartedAt != nil {
+		res.StartedAt = timePtr(req.Status.StartedAt)
+	}
+	if last := req.Status.LastAttemptAt; last != nil {
+		res.LastAttemptAt = timePtr(last)
+		if now.Sub(last.Time) < interval {
+			return res, false
+		}
+	}
+	timeout := defaultTimeout
+	if req.Hook.Timeout != nil {
+		timeout = req.Hook.Timeout.Duration
+	}
+	if timeout > 0 && now.Sub(*res.StartedAt) >= timeout {
+		res.Phase = steerv1alpha1.HelmTestJobPhaseFailed
+		if res.Message == "" {
+			res.Message = fmt.Sprintf("timed out after %s", timeout)
+		} else {
+			res.Message = fmt.Sprintf("timed out after %s: %s", timeout, res.Message)
+		}
+		res.CompletedAt = &now
+		return res, false
+	}
+	res.Attempts++
+	res.LastAttemptAt = &now
+	return res, true
+}
+
+func (e *JobExecutor) executeScript(ctx context.Context, req ExecuteRequest, jobName string) (Result, error) {
+	var kjob batchv1.Job
+	key := types.NamespacedName{Name: jobName, Namespace: req.Owner.Namespace}
+	if err := e.Client.Get(ctx, key, &kjob); err != nil {
+		if !errors.IsNotFound(err) {
+			return Result{}, err
+		}
+		env, err := e.resolveEnv(ctx, req)
+		if err != nil {
+			return Result{}, err
+		}
+
+		labels := RunLabels(req.Owner, req.RunKey)
+		newJob := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: jobName, Namespace: req.Owner.Namespace, Labels: labels}}
+		if err := controllerutil.SetControllerReference(req.Owner, &newJob, e.Scheme); err != nil {
+			return Result{}, err
+		}
+		container := corev1.Container{
+			Name:            "hook",
+			Image:           req.Image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         []string{"/bin/sh", "-c", req.Hook.Script},
+			Env:             append(env, corev1.EnvVar{Name: OutputEnvVar, Value: OutputPath}),
+			// Outputs are passed back through the termination message.
+			TerminationMessagePath:   OutputPath,
+			TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+		}
+		newJob.Spec.Template.Spec = corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    []corev1.Container{container},
+		}
+		ApplyPodTemplate(&newJob.Spec.Template, req.Owner.Spec.PodTemplate, req.Hook.PodTemplate)
+		if req.ServiceAccountName != "" {
+			newJob.Spec.Template.Spec.ServiceAccountName = req.ServiceAccountName
+		}
+		// Run labels win over the overlay, they are used to find the run's objects.
+		newJob.Spec.Template.Labels = mergeMap(newJob.Spec.Template.Labels, labels)
+		backoffLimit := int32(0)
+		newJob.Spec.BackoffLimit = &backoffLimit
+		newJob.Spec.ActiveDeadlineSeconds = DeadlineSeconds(req.Hook.Timeout)
+		if err := e.Client.Create(ctx, &newJob); err != nil {
+			return Result{}, err
+		}
+		return Result{Phase: steerv1alpha1.HelmTestJobPhasePending, ObjectName: jobName, Message: "hook job created"}, nil
+	}
+
+	res := resultFromJob(&kjob)
+	if err := e.observePod(ctx, &res, kjob.Namespace, "", kjob.Name, logKey(req)); err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+func (e *JobExecutor) executeObject(ctx context.Context, req ExecuteRequest, defaultName string) (Result, error) {
+	if req.Hook.Kubernetes == nil {
+		return Result{}, fmt.Errorf("hook %q: kubernetes is required when type=kubernetes", req.Hook.Name)
+	}
+	desired, err := decodeObject(req.Hook.Kubernetes.RawExtension)
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+	if desired.GetNamespace() == "" {
+		desired.SetNamespace(req.Owner.Namespace)
+	}
+	if desired.GetName() == "" {
+		desired.SetName(defaultName)
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(desired.GroupVersionKind())
+	key := types.NamespacedName{Name: desired.GetName(), Namespace: desired.GetNamespace()}
+	if err := e.Client.Get(ctx, key, existing); err != nil {
+		if !errors.IsNotFound(err) {
+			return Result{}, err
+		}
+		env, err := e.resolveEnv(ctx, req)
+		if err != nil {
+			return Result{}, err
+		}
+		if err := injectEnv(desired, env); err != nil {
+			return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+		}
+		if err := injectDeadline(desired, DeadlineSeconds(req.Hook.Timeout)); err != nil {
+			return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+		}
+		labels := desired.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for k, v := range RunLabels(req.Owner, req.RunKey) {
+			labels[k] = v
+		}
+		desired.SetLabels(labels)
+		// Owner references cannot cross namespaces.
+		if desired.GetNamespace() == req.Owner.Namespace {
+			if err := controllerutil.SetControllerReference(req.Owner, desired, e.Scheme); err != nil {
+				return Result{}, err
+			}
+		}
+		if err := e.Client.Create(ctx, desired); err != nil {
+			return Result{}, err
+		}
+		return Result{Phase: steerv1alpha1.HelmTestJobPhasePending, ObjectName: desired.GetName(), Message: "hook object created"}, nil
+	}
+
+	res, err := resultFromObject(existing)
+	if err != nil {
+		return Result{}, err
+	}
+	gvk := existing.GroupVersionKind()
+	switch {
+	case gvk.Group == "batch" && gvk.Kind == "Job":
+		err = e.observePod(ctx, &res, existing.GetNamespace(), "", existing.GetName(), logKey(req))
+	case gvk.Group == "" && gvk.Kind == "Pod":
+		err = e.observePod(ctx, &res, existing.GetNamespace(), existing.GetName(), "", logKey(req))
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+// PodNamespace returns the namespace the pods of a hook run in: the one of
+// the embedded object for kubernetes hooks, the owner's otherwise.
+func PodNamespace(owner *steerv1alpha1.HelmTestJob, hook steerv1alpha1.Hook) string {
+	if hook.Type == steerv1alpha1.HookTypeKubernetes && hook.Kubernetes != nil {
+		if obj, err := decodeObject(hook.Kubernetes.RawExtension); err == nil && obj.GetNamespace() != "" {
+			return obj.GetNamespace()
+		}
+	}
+	return owner.Namespace
+}
+
+func decodeObject(raw runtime.RawExtension) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	switch {
+	case len(raw.Raw) > 0:
+		if err := obj.UnmarshalJSON(raw.Raw); err != nil {
+			return nil, fmt.Errorf("invalid kubernetes object: %w", err)
+		}
+	case raw.Object != nil:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(raw.Object)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubernetes object: %w", err)
+		}
+		obj.SetUnstructuredContent(content)
+	default:
+		return nil, fmt.Errorf("kubernetes object is empty")
+	}
+	if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+		return nil, fmt.Errorf("kubernetes object must set apiVersion and kind")
+	}
+	return obj, nil
+}
+
+// containersPath returns where the containers live for the kinds we inject env into.
+func containersPath(obj *unstructured.Unstructured) []string {
+	gvk := obj.GroupVersionKind()
+	switch {
+	case gvk.Group == "batch" && gvk.Kind == "Job":
+		return []string{"spec", "template", "spec", "containers"}
+	case gvk.Group == "" && gvk.Kind == "Pod":
+		return []string{"spec", "containers"}
+	default:
+		return nil
+	}
+}
+
+func injectEnv(obj *unstructured.Unstructured, env []corev1.EnvVar) error {
+	path := containersPath(obj)
+	if len(env) == 0 || path == nil {
+		return nil
+	}
+	containers, found, err := unstructured.NestedSlice(obj.Object, path...)
+	if err != nil || !found {
+		return err
+	}
+	for i := range containers {
+		c, ok := containers[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		existing, _ := c["env"].([]interface{})
+		for _, v := range env {
+			existing = append(existing, map[string]interface{}{"name": v.Name, "value": v.Value})
+		}
+		c["env"] = existing
+		containers[i] = c
+	}
+	return unstructured.SetNestedSlice(obj.Object, containers, path...)
+}
+
+// DeadlineSeconds converts a timeout to activeDeadlineSeconds, or nil when
+// no timeout is set.
+func DeadlineSeconds(timeout *metav1.Duration) *int64 {
+	if timeout == nil || timeout.Duration <= 0 {
+		return nil
+	}
+	seconds := int64(timeout.Duration.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &seconds
+}
+
+// injectDeadline sets spec.activeDeadlineSeconds on Jobs and Pods that don't
+// set one themselves.
+func injectDeadline(obj *unstructured.Unstructured, seconds *int64) error {
+	if seconds == nil || containersPath(obj) == nil {
+		return nil
+	}
+	if _, found, err := unstructured.NestedFieldNoCopy(obj.Object, "spec", "activeDeadlineSeconds"); err != nil || found {
+		return err
+	}
+	return unstructured.SetNestedField(obj.Object, *seconds, "spec", "activeDeadlineSeconds")
+}
+
+func resultFromObject(obj *unstructured.Unstructured) (Result, error) {
+	gvk := obj.GroupVersionKind()
+	switch {
+	case gvk.Group == "batch" && gvk.Kind == "Job":
+		var kjob batchv1.Job
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &kjob); err != nil {
+			return Result{}, err
+		}
+		return resultFromJob(&kjob), nil
+	case gvk.Group == "" && gvk.Kind == "Pod":
+		var pod corev1.Pod
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pod); err != nil {
+			return Result{}, err
+		}
+		res := Result{ObjectName: pod.Name, StartedAt: timePtr(pod.Status.StartTime)}
+		switch pod.Status.Phase {
+		case corev1.PodSucceeded:
+			res.Phase, res.Message = steerv1alpha1.HelmTestJobPhaseSucceeded, "pod succeeded"
+		case corev1.PodFailed:
+			res.Phase, res.Message = steerv1alpha1.HelmTestJobPhaseFailed, "pod failed"
+		case corev1.PodRunning:
+			res.Phase, res.Message = steerv1alpha1.HelmTestJobPhaseRunning, "pod running"
+		default:
+			res.Phase, res.Message = steerv1alpha1.HelmTestJobPhasePending, "pod pending"
+		}
+		return res, nil
+	default:
+		created := obj.GetCreationTimestamp().Time
+		return Result{
+			Phase:       steerv1alpha1.HelmTestJobPhaseSucceeded,
+			ObjectName:  obj.GetName(),
+			Message:     fmt.Sprintf("%s created", gvk.Kind),
+			StartedAt:   &created,
+			CompletedAt: &created,
+		}, nil
+	}
+}
+
+func resultFromJob(job *batchv1.Job) Result {
+	phase, msg := PhaseFromJob(job)
+	res := Result{Phase: phase, ObjectName: job.Name, Message: msg, StartedAt: timePtr(job.Status.StartTime)}
+	switch phase {
+	case steerv1alpha1.HelmTestJobPhaseSucceeded:
+		res.CompletedAt = timePtr(job.Status.CompletionTime)
+	case steerv1alpha1.HelmTestJobPhaseFailed:
+		for _, c := range job.Status.Conditions {
+			if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+				t := c.LastTransitionTime.Time
+				res.CompletedAt = &t
+			}
+		}
+	}
+	return res
+}
+
+// PhaseFromJob maps a batch Job's status to a HelmTestJob phase.
+func PhaseFromJob(job *batchv1.Job) (steerv1alpha1.HelmTestJobPhase, string) {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			// e.g. DeadlineExceeded when activeDeadlineSeconds was reached.
+			if c.Reason != "" {
+				return steerv1alpha1.HelmTestJobPhaseFailed, fmt.Sprintf("job failed: %s", c.Reason)
+			}
+			return steerv1alpha1.HelmTestJobPhaseFailed, "job failed"
+		}
+		if c.Type == batchv1.JobComplete && c.Status == corev1.ConditionTrue {
+			return steerv1alpha1.HelmTestJobPhaseSucceeded, "job succeeded"
+		}
+	}
+	if job.Status.Active > 0 {
+		return steerv1alpha1.HelmTestJobPhaseRunning, "job running"
+	}
+	// If it exists but hasn't started yet.
+	return steerv1alpha1.HelmTestJobPhasePending, "job pending"
+}
+
+func (e *JobExecutor) resolveEnv(ctx context.Context, req ExecuteRequest) ([]corev1.EnvVar, error) {
+	return ResolveEnv(ctx, e.Client, req.Owner, req.Hook.Env, req.OutputsSecret)
+}
+
+// ResolveEnv renders env vars for a container of a run: literal values,
+// fields of the HelmTestJob or its HelmRelease, and outputs of hooks, which
+// refer to the outputs Secret of the run.
+func ResolveEnv(ctx context.Context, c client.Client, owner *steerv1alpha1.HelmTestJob, vars []steerv1alpha1.HookEnvVar, outputsSecret string) ([]corev1.EnvVar, error) {
+	var ownerFields, releaseFields map[string]interface{}
+	env := make([]corev1.EnvVar, 0, len(vars))
+	for _, v := range vars {
+		if v.ValueFrom == nil {
+			env = append(env, corev1.EnvVar{Name: v.Name, Value: v.Value})
+			continue
+		}
+
+		var (
+			fields map[string]interface{}
+			path   string
+			err    error
+		)
+		switch {
+		case v.ValueFrom.HookOutput != nil:
+			key, ok := hookOutputKey(owner, v.ValueFrom.HookOutput.Hook, v.ValueFrom.HookOutput.Key)
+			if !ok {
+				env = append(env, corev1.EnvVar{Name: v.Name})
+				continue
+			}
+			optional := true
+			env = append(env, corev1.EnvVar{Name: v.Name, ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: outputsSecret},
+					Key:                  key,
+					Optional:             &optional,
+				},
+			}})
+			continue
+		case v.ValueFrom.HelmReleaseRef != nil:
+			if releaseFields == nil {
+				releaseFields, err = releaseFieldsOf(ctx, c, owner)
+				if err != nil {
+					return nil, err
+				}
+			}
+			fields, path = releaseFields, v.ValueFrom.HelmReleaseRef.FieldPath
+		case v.ValueFrom.FieldPath != "":
+			if ownerFields == nil {
+				ownerFields, err = runtime.DefaultUnstructuredConverter.ToUnstructured(owner)
+				if err != nil {
+					return nil, err
+				}
+			}
+			fields, path = ownerFields, v.ValueFrom.FieldPath
+		default:
+			return nil, fmt.Errorf("env %q: valueFrom must set fieldPath, helmReleaseRef or hookOutput", v.Name)
+		}
+
+		value, err := fieldValue(fields, path)
+		if err != nil {
+			return nil, fmt.Errorf("env %q: %w", v.Name, err)
+		}
+		env = append(env, corev1.EnvVar{Name: v.Name, Value: value})
+	}
+	return env, nil
+}
+
+func releaseFieldsOf(ctx context.Context, c client.Client, owner *steerv1alpha1.HelmTestJob) (map[string]interface{}, error) {
+	ref := owner.Spec.HelmReleaseRef
+	ns := ref.Namespace
+	if ns == "" {
+		ns = owner.Namespace
+	}
+	var hr steerv1alpha1.HelmRelease
+	if err := c.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ns}, &hr); err != nil {
+		return nil, fmt.Errorf("failed to get HelmRelease %s/%s: %w", ns, ref.Name, err)
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(&hr)
+}
+
+// fieldValue renders the value at a dot separated path. Missing fields render
+// as an empty string, non-scalar values as JSON.
+func fieldValue(obj map[string]interface{}, path string) (string, error) {
+	v, found, err := unstructured.NestedFieldNoCopy(obj, strings.Split(path, ".")...)
+	if err != nil {
+		return "", fmt.Errorf("invalid fieldPath %q: %w", path, err)
+	}
+	if !found || v == nil {
+		return "", nil
+	}
+	switch tv := v.(type) {
+	case string:
+		return tv, nil
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(tv)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return fmt.Sprint(tv), nil
+	}
+}
+
+// JobName returns the name of the Job created for a hook in a given run.
+func JobName(parentName, runKey string, stage Stage, idx int) string {
+	short := "pre"
+	if stage == StagePostTest {
+		short = "post"
+	}
+	return ShortName(fmt.Sprintf("%s-%s-%s-%d", parentName, runKey, short, idx))
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]`)
+
+// ShortName returns name if it is a valid DNS-1123 label. Other names are
+// cut and end with a hash of the full name, so they stay unique.
+func ShortName(name string) string {
+	if len(validation.IsDNS1123Label(name)) == 0 {
+		return name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	suffix := fmt.Sprintf("-%08x", h.Sum32())
+	prefix := invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(prefix) > validation.DNS1123LabelMaxLength-len(suffix) {
+		prefix = prefix[:validation.DNS1123LabelMaxLength-len(suffix)]
+	}
+	if prefix = strings.Trim(prefix, "-"); prefix == "" {
+		prefix = "steer"
+	}
+	return prefix + suffix
+}
+
+// LabelValue returns value if it is a valid label value, otherwise its
+// ShortName.
+func LabelValue(value string) string {
+	if len(validation.IsValidLabelValue(value)) == 0 {
+		return value
+	}
+	return ShortName(value)
+}
+
+// RunLabels returns the labels set on objects created for a run, so they
+// can be found again when the run is stopped or pruned.
+func RunLabels(owner *steerv1alpha1.HelmTestJob, runKey string) map[string]string {
+	return map[string]string{
+		steerv1alpha1.LabelHelmTestJob: LabelValue(owner.Name),
+		steerv1alpha1.LabelRunKey:      LabelValue(runKey),
+	}
+}
+
+func timePtr(t *metav1.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
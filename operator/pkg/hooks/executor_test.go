@@ -0,0 +1,107 @@
+package hooks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestShortName(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	tests := []struct {
+		name   string
+		in     string
+		want   string
+		prefix string
+	}{
+		{name: "valid", in: "job-r100", want: "job-r100"},
+		{name: "too long", in: long + "-r100", prefix: strings.Repeat("a", 54) + "-"},
+		{name: "invalid characters", in: "Job.v1", prefix: "job-v1-"},
+		{name: "only invalid characters", in: "...", prefix: "steer-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ShortName(tt.in)
+			if errs := validation.IsDNS1123Label(got); len(errs) > 0 {
+				t.Fatalf("ShortName(%q) = %q: %v", tt.in, got, errs)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Fatalf("ShortName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !strings.HasPrefix(got, tt.prefix) {
+				t.Fatalf("ShortName(%q) = %q, want prefix %q", tt.in, got, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestShortNameKeepsLongNamesApart(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	seen := map[string]string{}
+	for _, name := range []string{
+		long + "-r100", long + "-r200", long + "-m100",
+		JobName(long, "r100", StagePreTest, 0), JobName(long, "r100", StagePreTest, 1),
+		JobName(long, "r100", StagePostTest, 0),
+	} {
+		short := ShortName(name)
+		if other, ok := seen[short]; ok {
+			t.Fatalf("%q and %q both shorten to %q", other, name, short)
+		}
+		seen[short] = name
+	}
+}
+
+func TestRunLabels(t *testing.T) {
+	job := testJob()
+	job.Name = strings.Repeat("j", 100)
+	labels := RunLabels(job, "r100")
+	for k, v := range labels {
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			t.Fatalf("label %s=%q: %v", k, v, errs)
+		}
+	}
+	if got, want := labels["steer.io/helmtestjob"], LabelValue(job.Name); got != want {
+		t.Fatalf("job label = %q, want %q", got, want)
+	}
+	if got := LabelValue("job.v1_a"); got != "job.v1_a" {
+		t.Fatalf("LabelValue kept a valid value as %q", got)
+	}
+}
+
+func TestNewestPod(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pod := func(name string, created, started time.Duration) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(base.Add(created))}}
+		if started >= 0 {
+			startTime := metav1.NewTime(base.Add(started))
+			p.Status.StartTime = &startTime
+		}
+		return p
+	}
+	tests := []struct {
+		name string
+		pods []corev1.Pod
+		want string
+	}{
+		{name: "none"},
+		{name: "created last", pods: []corev1.Pod{pod("b", time.Second, -1), pod("a", 0, -1)}, want: "b"},
+		{name: "not list order", pods: []corev1.Pod{pod("a", 2*time.Second, -1), pod("b", time.Second, -1)}, want: "a"},
+		{name: "same second started last", pods: []corev1.Pod{pod("a", 0, time.Second), pod("b", 0, 0)}, want: "a"},
+		{name: "same second not started", pods: []corev1.Pod{pod("a", 0, 0), pod("b", 0, -1)}, want: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewestPod(tt.pods)
+			switch {
+			case tt.want == "" && got != nil:
+				t.Fatalf("NewestPod() = %s, want none", got.Name)
+			case tt.want != "" && (got == nil || got.Name != tt.want):
+				t.Fatalf("NewestPod() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -16,35 +16,85 @@ const (
 
 // Executor runs hooks defined on HelmTestJob.
 //
-// Block A note: this is an interface-only abstraction. The real implementation
-// (script + kubernetes) will be added in later blocks.
+// Execute is level-triggered: it makes sure the hook has been started and
+// reports its current result without waiting for it to finish. Callers are
+// expected to call Execute again (e.g. on requeue) until the returned phase
+// is terminal.
 type Executor interface {
-	Execute(ctx context.Context, req ExecuteRequest) ([]Result, error)
+	Execute(ctx context.Context, req ExecuteRequest) (Result, error)
 }
 
 type ExecuteRequest struct {
-	Namespace string
-	Stage     Stage
-	Hooks     []steerv1alpha1.Hook
+	// Owner is the HelmTestJob the hook belongs to. Objects created for the
+	// hook live in its namespace and are owned by it.
+	Owner *steerv1alpha1.HelmTestJob
+
+	Stage Stage
+	// Index is the position of the hook within its stage.
+	Index int
+	// RunKey identifies the run, so each run gets its own hook objects.
+	RunKey string
+	// Image is used by hook types that need a container (e.g. script).
+	Image string
+	// ReleaseNamespace, if set, replaces the target namespace of the
+	// HelmRelease, e.g. for a run in an ephemeral environment.
+	ReleaseNamespace string
+	// ServiceAccountName, if set, is the ServiceAccount of the hook pod. It
+	// wins over the pod template.
+	ServiceAccountName string
+	// Outputs are the outputs of the hooks that already finished in this
+	// run, used to render the templates of hooks the controller executes.
+	Outputs Outputs
+	// OutputsSecret is the Secret those outputs are kept in. hookOutput env
+	// vars of containers refer to it, so the values don't end up in the
+	// pod spec.
+	OutputsSecret string
+	// Status is the result recorded for the hook so far. Hooks the
+	// executor runs itself keep their progress, e.g. attempts, in it.
+	Status steerv1alpha1.HookResult
+	// Stop is set when the run is being stopped. Hooks that disrupted
+	// something undo it right away and are not waited for any further.
+	Stop bool
+
+	Hook steerv1alpha1.Hook
 }
 
 type Result struct {
-	Name        string
-	Stage       Stage
-	Succeeded   bool
+	Name  string
+	Stage Stage
+	Phase steerv1alpha1.HelmTestJobPhase
+	// ObjectName is the name of the Job (or embedded object) running the hook.
+	ObjectName string
+	// PodName is the Pod running the hook, if any.
+	PodName     string
 	Message     string
 	StartedAt   *time.Time
 	CompletedAt *time.Time
+	// ImageID is the image, including its digest, the hook container ran.
+	ImageID string
+	// ExitCode is set once the hook container has terminated.
+	ExitCode *int32
+	// Logs is the tail of the hook container logs, collected once the hook finished.
+	Logs string
+	// LogRef is the key of the full log in the log sink, if one is configured.
+	LogRef string
+	// Outputs are harvested from the termination message once the hook succeeded.
+	Outputs map[string]string
+	// Attempts and LastAttemptAt track hooks the executor runs itself.
+	Attempts      int32
+	LastAttemptAt *time.Time
+	// Disruption records what a chaos hook disrupted.
+	Disruption *steerv1alpha1.HookDisruption
 }
 
 // FakeExecutor is a simple injectable fake implementation of Executor.
 type FakeExecutor struct {
-	ExecuteFunc func(ctx context.Context, req ExecuteRequest) ([]Result, error)
+	ExecuteFunc func(ctx context.Context, req ExecuteRequest) (Result, error)
 }
 
-func (f *FakeExecutor) Execute(ctx context.Context, req ExecuteRequest) ([]Result, error) {
+func (f *FakeExecutor) Execute(ctx context.Context, req ExecuteRequest) (Result, error) {
 	if f.ExecuteFunc != nil {
 		return f.ExecuteFunc(ctx, req)
 	}
-	return nil, nil
+	return Result{Name: req.Hook.Name, Stage: req.Stage, Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}, nil
 }
@@ -0,0 +1,49 @@
+package hooks
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// testReleaseNamespace is where the release of testJob deploys to.
+const testReleaseNamespace = "app"
+
+func newTestExecutor(t *testing.T, objs ...client.Object) *JobExecutor {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := steerv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	release := &steerv1alpha1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default"},
+		Spec: steerv1alpha1.HelmReleaseSpec{
+			Deployment: steerv1alpha1.DeploymentSpec{Namespace: testReleaseNamespace},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(objs, release)...).Build()
+	return NewJobExecutor(c, scheme, nil)
+}
+
+func testJob() *steerv1alpha1.HelmTestJob {
+	return &steerv1alpha1.HelmTestJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "default"},
+		Spec: steerv1alpha1.HelmTestJobSpec{
+			HelmReleaseRef: steerv1alpha1.HelmReleaseRef{Name: "release"},
+		},
+	}
+}
+
+func testRequest(hook steerv1alpha1.Hook) ExecuteRequest {
+	return ExecuteRequest{Owner: testJob(), Stage: StagePreTest, RunKey: "r1", Hook: hook}
+}
@@ -0,0 +1,201 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+const (
+	defaultHTTPInterval       = 5 * time.Second
+	defaultHTTPRequestTimeout = 10 * time.Second
+)
+
+// executeHTTP makes at most one attempt per call, so probing never blocks a
+// reconcile for longer than a single request.
+func (e *JobExecutor) executeHTTP(ctx context.Context, req ExecuteRequest) (Result, error) {
+	spec := req.Hook.HTTP
+	if spec == nil {
+		return Result{}, fmt.Errorf("hook %q: http is required when type=http", req.Hook.Name)
+	}
+	bodyRegex, err := compileBodyRegex(spec.BodyRegex)
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+
+	now := time.Now()
+	res, due := pace(req, durationOr(spec.Interval.Duration, defaultHTTPInterval), 0, now)
+	if !due {
+		return res, nil
+	}
+
+	data := newTemplateData(ctx, e.Client, req)
+	rawURL, err := data.render("url", spec.URL)
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+	namespace, err := data.releaseNamespace()
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+	url, service, err := serviceURL(rawURL, namespace)
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+	method := spec.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	body, err := data.render("body", spec.Body)
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+	headers := http.Header{}
+	for _, h := range spec.Headers {
+		value, err := data.render("header "+h.Name, h.Value)
+		if err != nil {
+			return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+		}
+		headers.Add(h.Name, value)
+	}
+
+	ok, msg := e.probe(ctx, method, url, types.NamespacedName{Name: service, Namespace: namespace}, headers, body, spec, bodyRegex)
+	res.Message = fmt.Sprintf("%s %s: %s", method, url, msg)
+	switch {
+	case ok:
+		res.Phase = steerv1alpha1.HelmTestJobPhaseSucceeded
+		res.CompletedAt = &now
+	case res.Attempts > spec.Retries:
+		res.Phase = steerv1alpha1.HelmTestJobPhaseFailed
+		res.Message = fmt.Sprintf("%s (after %d attempts)", res.Message, res.Attempts)
+		res.CompletedAt = &now
+	}
+	return res, nil
+}
+
+// serviceURL returns rawURL with the cluster DNS name of the Service it
+// targets as host, and the name of the Service. The controller sends the
+// requests with its own network access, so only Services of the release
+// namespace may be probed, named as <service>, <service>.<namespace> or
+// <service>.<namespace>.svc[.<domain>].
+func serviceURL(rawURL, namespace string) (string, string, error) {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", fmt.Errorf("invalid url %q: scheme must be http or https", rawURL)
+	}
+	host := u.Hostname()
+	parts := strings.Split(host, ".")
+	name, ns := parts[0], namespace
+	switch {
+	case net.ParseIP(host) != nil || name == "":
+		return "", "", fmt.Errorf("url %q must name a Service in namespace %s", rawURL, namespace)
+	case len(parts) == 1:
+	case len(parts) == 2 || parts[2] == "svc":
+		ns = parts[1]
+	default:
+		return "", "", fmt.Errorf("url %q must name a Service in namespace %s", rawURL, namespace)
+	}
+	if ns != namespace {
+		return "", "", fmt.Errorf("url %q must name a Service in namespace %s, not %s", rawURL, namespace, ns)
+	}
+	port := u.Port()
+	u.Host = name + "." + ns + ".svc"
+	if port != "" {
+		u.Host = net.JoinHostPort(u.Host, port)
+	}
+	u.User = nil
+	return u.String(), name, nil
+}
+
+// probe sends a single request and checks the response. It returns whether
+// the response was as expected and a short description. The response body is
+// only matched against bodyRegex, never recorded.
+func (e *JobExecutor) probe(ctx context.Context, method, url string, service types.NamespacedName, headers http.Header, body string, spec *steerv1alpha1.HTTPHookSpec, bodyRegex *regexp.Regexp) (bool, string) {
+	ctx, cancel := context.WithTimeout(ctx, durationOr(spec.RequestTimeout.Duration, defaultHTTPRequestTimeout))
+	defer cancel()
+
+	// ExternalName Services resolve to hosts outside of the cluster.
+	var svc corev1.Service
+	if err := e.Client.Get(ctx, service, &svc); err != nil {
+		return false, fmt.Sprintf("service %s: %v", service, err)
+	}
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return false, fmt.Sprintf("service %s is an ExternalName service", service)
+	}
+
+	var reqBody io.Reader
+	if body != "" {
+		reqBody = strings.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return false, err.Error()
+	}
+	httpReq.Header = headers
+
+	client := http.Client{}
+	if e.HTTPClient != nil {
+		client = *e.HTTPClient
+	}
+	// Redirects could lead anywhere.
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxLogBytes))
+	if err != nil {
+		return false, fmt.Sprintf("status %d, failed to read body: %v", resp.StatusCode, err)
+	}
+
+	if !expectedStatus(spec.ExpectedStatus, resp.StatusCode) {
+		return false, fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	if bodyRegex != nil && !bodyRegex.Match(raw) {
+		return false, fmt.Sprintf("status %d, body does not match %q", resp.StatusCode, spec.BodyRegex)
+	}
+	return true, fmt.Sprintf("status %d", resp.StatusCode)
+}
+
+func compileBodyRegex(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bodyRegex: %w", err)
+	}
+	return re, nil
+}
+
+func expectedStatus(expected []int32, code int) bool {
+	if len(expected) == 0 {
+		return code >= 200 && code < 300
+	}
+	return slices.Contains(expected, int32(code))
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,84 @@
+package hooks
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// OutputEnvVar is set on script hooks to the file their outputs are written
+// to. Every line of the form key=value becomes an output of the hook; keys
+// are made of alphanumerics, '-', '_' and '.'.
+const OutputEnvVar = "STEER_OUTPUT"
+
+// OutputPath is where hook containers write their outputs. It is the
+// default termination message path, so the kubelet hands the outputs to the
+// controller in the pod status; the kubelet keeps at most 4096 bytes.
+const OutputPath = "/dev/termination-log"
+
+// Outputs holds the outputs of the hooks of a run by hook name.
+type Outputs map[string]map[string]string
+
+// Value returns an output of a hook, or "" if the hook did not write it.
+func (o Outputs) Value(hook, key string) string {
+	return o[hook][key]
+}
+
+// ParseOutputs reads key=value lines. Blank lines, lines starting with # and
+// lines without a valid key are ignored; a later line wins over an earlier
+// one.
+func ParseOutputs(message string) map[string]string {
+	var outputs map[string]string
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || len(validation.IsConfigMapKey(key)) > 0 {
+			continue
+		}
+		if outputs == nil {
+			outputs = map[string]string{}
+		}
+		outputs[key] = value
+	}
+	return outputs
+}
+
+// OutputsSecretName returns the name of the Secret the outputs of the hooks
+// of a run are kept in. Outputs may be credentials, so they are not part of
+// the run status.
+func OutputsSecretName(run string) string {
+	return run + "-outputs"
+}
+
+// OutputKey returns the key of an output in the outputs Secret of a run,
+// e.g. preTest-0.token. Hooks are told apart by their position as their
+// names need not be valid Secret keys.
+func OutputKey(stage Stage, index int, key string) string {
+	return fmt.Sprintf("%s-%d.%s", stage, index, key)
+}
+
+// hookOutputKey returns the key of an output of the hook with the given name
+// in the outputs Secret, or false if the HelmTestJob has no such hook.
+func hookOutputKey(owner *steerv1alpha1.HelmTestJob, hook, key string) (string, bool) {
+	for _, stage := range []struct {
+		stage Stage
+		hooks []steerv1alpha1.Hook
+	}{
+		{StagePreTest, owner.Spec.Hooks.PreTest},
+		{StagePostTest, owner.Spec.Hooks.PostTest},
+	} {
+		for i, h := range stage.hooks {
+			if h.Name == hook {
+				return OutputKey(stage.stage, i, key), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,28 @@
+package hooks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOutputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    map[string]string
+	}{
+		{name: "empty"},
+		{name: "key value pairs", message: "token=abc\nuser = admin\n", want: map[string]string{"token": "abc", "user": " admin"}},
+		{name: "later lines win", message: "a=1\na=2", want: map[string]string{"a": "2"}},
+		{name: "values may contain =", message: "query=a=b", want: map[string]string{"query": "a=b"}},
+		{name: "skips comments and lines without a key", message: "# a=1\nplain\n=x\n", want: nil},
+		{name: "skips keys that can't be secret keys", message: "a b=1\nc/d=2\ne.f_g-h=3", want: map[string]string{"e.f_g-h": "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseOutputs(tt.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
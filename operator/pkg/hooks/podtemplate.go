@@ -0,0 +1,93 @@
+package hooks
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// ApplyPodTemplate merges overlays into a pod template, in order, so later
+// overlays (e.g. a hook's own podTemplate) win over earlier ones. Nil
+// overlays are ignored.
+func ApplyPodTemplate(tmpl *corev1.PodTemplateSpec, overlays ...*steerv1alpha1.PodTemplateOverlay) {
+	for _, o := range overlays {
+		if o == nil {
+			continue
+		}
+		tmpl.Labels = mergeMap(tmpl.Labels, o.Labels)
+		tmpl.Annotations = mergeMap(tmpl.Annotations, o.Annotations)
+
+		spec := &tmpl.Spec
+		if o.ServiceAccountName != "" {
+			spec.ServiceAccountName = o.ServiceAccountName
+		}
+		spec.NodeSelector = mergeMap(spec.NodeSelector, o.NodeSelector)
+		spec.Tolerations = append(spec.Tolerations, o.Tolerations...)
+		if o.SecurityContext != nil {
+			spec.SecurityContext = o.SecurityContext.DeepCopy()
+		}
+		for _, v := range o.Volumes {
+			spec.Volumes = setVolume(spec.Volumes, v)
+		}
+		for _, s := range o.ImagePullSecrets {
+			if !hasPullSecret(spec.ImagePullSecrets, s.Name) {
+				spec.ImagePullSecrets = append(spec.ImagePullSecrets, s)
+			}
+		}
+
+		for i := range spec.Containers {
+			c := &spec.Containers[i]
+			if o.Resources != nil {
+				c.Resources = *o.Resources.DeepCopy()
+			}
+			if o.ContainerSecurityContext != nil {
+				c.SecurityContext = o.ContainerSecurityContext.DeepCopy()
+			}
+			for _, m := range o.VolumeMounts {
+				c.VolumeMounts = setVolumeMount(c.VolumeMounts, m)
+			}
+		}
+	}
+}
+
+func mergeMap(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func setVolume(volumes []corev1.Volume, v corev1.Volume) []corev1.Volume {
+	for i := range volumes {
+		if volumes[i].Name == v.Name {
+			volumes[i] = *v.DeepCopy()
+			return volumes
+		}
+	}
+	return append(volumes, *v.DeepCopy())
+}
+
+func setVolumeMount(mounts []corev1.VolumeMount, m corev1.VolumeMount) []corev1.VolumeMount {
+	for i := range mounts {
+		if mounts[i].MountPath == m.MountPath {
+			mounts[i] = m
+			return mounts
+		}
+	}
+	return append(mounts, m)
+}
+
+func hasPullSecret(secrets []corev1.LocalObjectReference, name string) bool {
+	for _, s := range secrets {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
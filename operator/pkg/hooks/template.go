@@ -0,0 +1,84 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// templateData is what hook templates are rendered with. The HelmRelease
+// is only fetched when a template references it.
+type templateData struct {
+	ctx     context.Context
+	client  client.Client
+	owner   *steerv1alpha1.HelmTestJob
+	release map[string]interface{}
+	// namespace overrides the target namespace of the HelmRelease.
+	namespace string
+
+	Outputs Outputs
+}
+
+func newTemplateData(ctx context.Context, c client.Client, req ExecuteRequest) *templateData {
+	return &templateData{ctx: ctx, client: c, owner: req.Owner, namespace: req.ReleaseNamespace, Outputs: req.Outputs}
+}
+
+// Release returns the HelmRelease of the job as an unstructured object. In
+// an ephemeral environment, spec.deployment.namespace is its namespace.
+func (d *templateData) Release() (map[string]interface{}, error) {
+	if d.release == nil {
+		release, err := releaseFieldsOf(d.ctx, d.client, d.owner)
+		if err != nil {
+			return nil, err
+		}
+		if d.namespace != "" {
+			if err := unstructured.SetNestedField(release, d.namespace, "spec", "deployment", "namespace"); err != nil {
+				return nil, err
+			}
+		}
+		d.release = release
+	}
+	return d.release, nil
+}
+
+// releaseNamespace returns the namespace the HelmRelease deploys into.
+func (d *templateData) releaseNamespace() (string, error) {
+	release, err := d.Release()
+	if err != nil {
+		return "", err
+	}
+	if ns, _, _ := unstructured.NestedString(release, "spec", "deployment", "namespace"); ns != "" {
+		return ns, nil
+	}
+	ns, _, _ := unstructured.NestedString(release, "metadata", "namespace")
+	return ns, nil
+}
+
+// Job returns the HelmTestJob as an unstructured object.
+func (d *templateData) Job() (map[string]interface{}, error) {
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(d.owner)
+}
+
+// render executes a template. Text without actions is returned as is.
+func (d *templateData) render(name, text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,156 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+const (
+	defaultWaitForInterval = 5 * time.Second
+	defaultWaitForTimeout  = 5 * time.Minute
+)
+
+// executeWaitFor checks the selected objects once per call.
+func (e *JobExecutor) executeWaitFor(ctx context.Context, req ExecuteRequest) (Result, error) {
+	spec := req.Hook.WaitFor
+	if spec == nil {
+		return Result{}, fmt.Errorf("hook %q: waitFor is required when type=waitFor", req.Hook.Name)
+	}
+	if (spec.Condition == "") == (spec.JSONPath == "") {
+		return Result{}, fmt.Errorf("hook %q: waitFor must set exactly one of condition or jsonPath", req.Hook.Name)
+	}
+	var path *jsonpath.JSONPath
+	if spec.JSONPath != "" {
+		path = jsonpath.New(req.Hook.Name).AllowMissingKeys(true)
+		if err := path.Parse(spec.JSONPath); err != nil {
+			return Result{}, fmt.Errorf("hook %q: invalid jsonPath: %w", req.Hook.Name, err)
+		}
+	}
+
+	now := time.Now()
+	res, due := pace(req, durationOr(spec.Interval.Duration, defaultWaitForInterval), defaultWaitForTimeout, now)
+	if !due {
+		return res, nil
+	}
+
+	// The controller reads the objects with its own permissions, so they are
+	// only looked up where the release is installed.
+	data := newTemplateData(ctx, e.Client, req)
+	namespace, err := data.releaseNamespace()
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+	requested, err := data.render("namespace", spec.Namespace)
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+	if requested != "" && requested != namespace {
+		return Result{}, fmt.Errorf("hook %q: waitFor can only watch namespace %s, not %s", req.Hook.Name, namespace, requested)
+	}
+	objects, err := e.waitForObjects(ctx, spec, namespace)
+	if err != nil {
+		return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+	}
+
+	target := fmt.Sprintf("%s in %s", spec.Kind, namespace)
+	if len(objects) == 0 {
+		res.Message = fmt.Sprintf("waiting for %s: no objects found", target)
+		return res, nil
+	}
+	for i := range objects {
+		ok, detail, err := checkObject(&objects[i], spec, path)
+		if err != nil {
+			return Result{}, fmt.Errorf("hook %q: %w", req.Hook.Name, err)
+		}
+		if !ok {
+			res.Message = fmt.Sprintf("waiting for %s %s: %s", target, objects[i].GetName(), detail)
+			return res, nil
+		}
+	}
+	res.Phase = steerv1alpha1.HelmTestJobPhaseSucceeded
+	res.Message = fmt.Sprintf("%d %s ready", len(objects), target)
+	res.CompletedAt = &now
+	return res, nil
+}
+
+func (e *JobExecutor) waitForObjects(ctx context.Context, spec *steerv1alpha1.WaitForHookSpec, namespace string) ([]unstructured.Unstructured, error) {
+	apiVersion := spec.APIVersion
+	if apiVersion == "" {
+		apiVersion = "v1"
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiVersion: %w", err)
+	}
+
+	if spec.Name != "" {
+		obj := unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gv.WithKind(spec.Kind))
+		if err := e.Client.Get(ctx, types.NamespacedName{Name: spec.Name, Namespace: namespace}, &obj); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		return []unstructured.Unstructured{obj}, nil
+	}
+
+	list := unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gv.WithKind(spec.Kind + "List"))
+	opts := []client.ListOption{client.InNamespace(namespace)}
+	if spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector: %w", err)
+		}
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+	}
+	if err := e.Client.List(ctx, &list, opts...); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// checkObject reports whether an object is in the expected state, and if
+// not, which check it fails. Values the JSONPath selects are never reported,
+// they could come from anywhere in the object.
+func checkObject(obj *unstructured.Unstructured, spec *steerv1alpha1.WaitForHookSpec, path *jsonpath.JSONPath) (bool, string, error) {
+	if path != nil {
+		var buf bytes.Buffer
+		if err := path.Execute(&buf, obj.Object); err != nil {
+			return false, "", fmt.Errorf("failed to evaluate jsonPath: %w", err)
+		}
+		got := buf.String()
+		if spec.Value == "" {
+			return got != "", fmt.Sprintf("%s is empty", spec.JSONPath), nil
+		}
+		return got == spec.Value, fmt.Sprintf("%s: condition not met", spec.JSONPath), nil
+	}
+
+	condType, want, found := strings.Cut(spec.Condition, "=")
+	if !found {
+		want = "True"
+	}
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return false, "", fmt.Errorf("invalid status.conditions: %w", err)
+	}
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != condType {
+			continue
+		}
+		got, _ := cond["status"].(string)
+		return strings.EqualFold(got, want), fmt.Sprintf("condition %s not met", condType), nil
+	}
+	return false, fmt.Sprintf("condition %s not reported", condType), nil
+}
@@ -0,0 +1,157 @@
+package hooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+func TestExecuteWaitFor(t *testing.T) {
+	available := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: testReleaseNamespace, Labels: map[string]string{"app": "web"}},
+		Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue},
+		}},
+	}
+	progressing := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: testReleaseNamespace, Labels: map[string]string{"app": "api"}},
+		Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionFalse},
+		}},
+	}
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: testReleaseNamespace},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}
+	elsewhere := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "kube-system"},
+		Data:       map[string]string{"ready": "true"},
+	}
+
+	tests := []struct {
+		name        string
+		spec        steerv1alpha1.WaitForHookSpec
+		timeout     time.Duration
+		status      steerv1alpha1.HookResult
+		objects     []client.Object
+		wantPhase   steerv1alpha1.HelmTestJobPhase
+		wantMessage string
+		wantErr     string
+	}{
+		{
+			name:        "condition met",
+			spec:        steerv1alpha1.WaitForHookSpec{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Condition: "Available"},
+			objects:     []client.Object{available},
+			wantPhase:   steerv1alpha1.HelmTestJobPhaseSucceeded,
+			wantMessage: "1 Deployment in app ready",
+		},
+		{
+			name:        "condition not met",
+			spec:        steerv1alpha1.WaitForHookSpec{APIVersion: "apps/v1", Kind: "Deployment", Condition: "Available=True"},
+			objects:     []client.Object{available, progressing},
+			wantPhase:   steerv1alpha1.HelmTestJobPhaseRunning,
+			wantMessage: "waiting for Deployment in app api: condition Available not met",
+		},
+		{
+			name:        "no objects yet",
+			spec:        steerv1alpha1.WaitForHookSpec{APIVersion: "apps/v1", Kind: "Deployment", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}, Condition: "Available"},
+			objects:     []client.Object{available},
+			wantPhase:   steerv1alpha1.HelmTestJobPhaseRunning,
+			wantMessage: "no objects found",
+		},
+		{
+			name:        "jsonPath value is not reported",
+			spec:        steerv1alpha1.WaitForHookSpec{Kind: "Secret", Name: "credentials", JSONPath: "{.data.password}", Value: "guess"},
+			objects:     []client.Object{credentials},
+			wantPhase:   steerv1alpha1.HelmTestJobPhaseRunning,
+			wantMessage: "{.data.password}: condition not met",
+		},
+		{
+			name:    "timeout",
+			spec:    steerv1alpha1.WaitForHookSpec{APIVersion: "apps/v1", Kind: "Deployment", Name: "api", Condition: "Available"},
+			timeout: time.Minute,
+			status: steerv1alpha1.HookResult{
+				StartedAt: &metav1.Time{Time: time.Now().Add(-time.Hour)},
+				Message:   "waiting for Deployment in app api: condition Available not met",
+			},
+			objects:     []client.Object{progressing},
+			wantPhase:   steerv1alpha1.HelmTestJobPhaseFailed,
+			wantMessage: "timed out after 1m0s: waiting for Deployment in app api",
+		},
+		{
+			name:        "release namespace template",
+			spec:        steerv1alpha1.WaitForHookSpec{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "{{ .Release.spec.deployment.namespace }}", Name: "web", Condition: "Available"},
+			objects:     []client.Object{available},
+			wantPhase:   steerv1alpha1.HelmTestJobPhaseSucceeded,
+			wantMessage: "ready",
+		},
+		{
+			name:    "other namespaces are refused",
+			spec:    steerv1alpha1.WaitForHookSpec{Kind: "ConfigMap", Namespace: "kube-system", Name: "config", JSONPath: "{.data.ready}"},
+			objects: []client.Object{elsewhere},
+			wantErr: "waitFor can only watch namespace app, not kube-system",
+		},
+		{
+			name:    "condition and jsonPath",
+			spec:    steerv1alpha1.WaitForHookSpec{Kind: "ConfigMap", Condition: "Ready", JSONPath: "{.data.ready}"},
+			wantErr: "exactly one of condition or jsonPath",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestExecutor(t, tt.objects...)
+			hook := steerv1alpha1.Hook{Name: "wait", Type: steerv1alpha1.HookTypeWaitFor, WaitFor: &tt.spec}
+			if tt.timeout > 0 {
+				hook.Timeout = &metav1.Duration{Duration: tt.timeout}
+			}
+			req := testRequest(hook)
+			req.Status = tt.status
+
+			res, err := e.Execute(context.Background(), req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if res.Phase != tt.wantPhase || !strings.Contains(res.Message, tt.wantMessage) {
+				t.Errorf("Execute() = %s %q, want %s %q", res.Phase, res.Message, tt.wantPhase, tt.wantMessage)
+			}
+			if strings.Contains(res.Message, "hunter2") || strings.Contains(res.Message, "aHVudGVyMg") {
+				t.Errorf("Execute() reported the secret value: %q", res.Message)
+			}
+		})
+	}
+}
+
+func TestExecuteWaitForEphemeralNamespace(t *testing.T) {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "release-r1"},
+		Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue},
+		}},
+	}
+	e := newTestExecutor(t, deploy)
+	req := testRequest(steerv1alpha1.Hook{Name: "wait", Type: steerv1alpha1.HookTypeWaitFor, WaitFor: &steerv1alpha1.WaitForHookSpec{
+		APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Condition: "Available",
+	}})
+	req.ReleaseNamespace = "release-r1"
+	res, err := e.Execute(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res.Phase != steerv1alpha1.HelmTestJobPhaseSucceeded {
+		t.Errorf("Execute() = %s %q, want Succeeded", res.Phase, res.Message)
+	}
+}
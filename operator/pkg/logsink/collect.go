@@ -0,0 +1,99 @@
+package logsink
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// TailLines is the number of log lines kept in status.
+	TailLines = 50
+	// maxTailBytes bounds the tail so results stay small enough for status.
+	maxTailBytes = 4096
+	// collectTimeout bounds reading and storing a log, which happens during
+	// a reconcile.
+	collectTimeout = time.Minute
+)
+
+// Collect reads the logs of a finished container. With a sink, the full
+// log is stored under key and key is returned as the reference; the tail is
+// returned either way.
+func Collect(ctx context.Context, clientset kubernetes.Interface, sink Sink, namespace, pod, container, key string) (tail, ref string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+	opts := &corev1.PodLogOptions{Container: container}
+	if sink == nil {
+		// The tail is cut to maxTailBytes from the front: LimitBytes would
+		// keep the start of the tail instead of its end.
+		lines := int64(TailLines)
+		opts.TailLines = &lines
+	}
+	stream, err := clientset.CoreV1().Pods(namespace).GetLogs(pod, opts).Stream(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer stream.Close()
+
+	t := &tailWriter{}
+	if sink == nil {
+		if _, err := io.Copy(t, stream); err != nil {
+			return "", "", err
+		}
+		return t.String(), "", nil
+	}
+
+	// Spool to a file: S3 needs the size up front and logs can be large.
+	spool, err := os.CreateTemp("", "steer-log-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	size, err := io.Copy(io.MultiWriter(spool, t), stream)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	if err := sink.Put(ctx, key, spool, size); err != nil {
+		return "", "", err
+	}
+	return t.String(), key, nil
+}
+
+// tailWriter keeps the last bytes written to it.
+type tailWriter struct {
+	buf []byte
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > 2*maxTailBytes {
+		t.buf = append(t.buf[:0:0], t.buf[len(t.buf)-maxTailBytes:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the last TailLines lines, within maxTailBytes.
+func (t *tailWriter) String() string {
+	buf := t.buf
+	if len(buf) > maxTailBytes {
+		buf = buf[len(buf)-maxTailBytes:]
+	}
+	s := string(buf)
+	lines := strings.SplitAfter(s, "\n")
+	if last := len(lines) - 1; last >= 0 && lines[last] == "" {
+		lines = lines[:last]
+	}
+	if len(lines) > TailLines {
+		lines = lines[len(lines)-TailLines:]
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,86 @@
+package logsink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestTailWriter(t *testing.T) {
+	lines := func(from, to int) string {
+		var b strings.Builder
+		for i := from; i <= to; i++ {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		return b.String()
+	}
+	long := strings.Repeat("x", 3*maxTailBytes)
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{name: "empty"},
+		{name: "short log", writes: []string{"a\n", "b"}, want: "a\nb"},
+		{name: "keeps the last lines", writes: []string{lines(1, 30), lines(31, 80)}, want: lines(31, 80)},
+		{name: "bounds the bytes", writes: []string{"start\n", long}, want: long[len(long)-maxTailBytes:]},
+		{name: "many small writes", writes: strings.SplitAfter(lines(1, 1000), "\n"), want: lines(951, 1000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &tailWriter{}
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+					t.Fatalf("Write() = %d, %v", n, err)
+				}
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	ctx := context.Background()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	key := Key("default", "job", "r1", "test", "main")
+
+	t.Run("without a sink", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(pod)
+		tail, ref, err := Collect(ctx, clientset, nil, "default", "test", "main", key)
+		if err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+		// The fake clientset returns "fake logs" for every pod.
+		if tail != "fake logs" || ref != "" {
+			t.Errorf("Collect() = %q, %q, want the tail and no reference", tail, ref)
+		}
+		// LimitBytes would cut off the end of the tail.
+		actions := clientset.Actions()
+		opts, _ := actions[len(actions)-1].(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+		if opts == nil || opts.TailLines == nil || *opts.TailLines != TailLines || opts.LimitBytes != nil {
+			t.Errorf("Collect() read the logs with %+v, want only the last %d lines", opts, TailLines)
+		}
+	})
+
+	t.Run("with a sink", func(t *testing.T) {
+		sink := &FakeSink{}
+		tail, ref, err := Collect(ctx, fake.NewSimpleClientset(pod), sink, "default", "test", "main", key)
+		if err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+		if tail != "fake logs" || ref != key {
+			t.Errorf("Collect() = %q, %q, want the tail and %q", tail, ref, key)
+		}
+		if got := string(sink.Objects[key]); got != "fake logs" {
+			t.Errorf("sink has %q, want the full log", got)
+		}
+	})
+}
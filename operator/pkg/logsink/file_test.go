@@ -0,0 +1,68 @@
+package logsink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+	s := &FileSink{Dir: t.TempDir()}
+	key := Key("default", "job", "r1", "test", "main")
+
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open() of a missing log error = %v, want ErrNotFound", err)
+	}
+	for _, content := range []string{"first\n", "second\n"} {
+		if err := s.Put(ctx, key, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		r, err := s.Open(ctx, key)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		got, _ := io.ReadAll(r)
+		r.Close()
+		if string(got) != content {
+			t.Errorf("Open() = %q, want %q", got, content)
+		}
+	}
+	entries, err := os.ReadDir(filepath.Join(s.Dir, "default", "job", "r1", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("log directory has %d entries, want only the log", len(entries))
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() of a missing log error = %v", err)
+	}
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileSinkRejectsKeysOutsideDir(t *testing.T) {
+	ctx := context.Background()
+	s := &FileSink{Dir: t.TempDir()}
+	for _, key := range []string{"../escape.log", "/etc/passwd", "a/../../escape.log"} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := s.Open(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) error = %v, want an invalid key", key, err)
+		}
+		if err := s.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+	}
+}
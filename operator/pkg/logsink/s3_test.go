@@ -0,0 +1,102 @@
+package logsink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newS3Server is a minimal path-style bucket.
+func newS3Server(t *testing.T) (*httptest.Server, map[string]string) {
+	t.Helper()
+	var mu sync.Mutex
+	objects := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			http.Error(w, "unsigned request", http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, body)
+		case http.MethodDelete:
+			if _, ok := objects[r.URL.Path]; !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, objects
+}
+
+func TestS3Sink(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	srv, objects := newS3Server(t)
+	s, err := NewS3Sink(S3Config{Endpoint: srv.URL, Bucket: "logs", Prefix: "/steer/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := Key("default", "job", "r1", "test", "main")
+
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open() of a missing log error = %v, want ErrNotFound", err)
+	}
+	if err := s.Put(ctx, key, strings.NewReader("log\n"), 4); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if got := objects["/logs/steer/"+key]; got != "log\n" {
+		t.Errorf("bucket has %q at the prefixed key, want the log", got)
+	}
+	r, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if string(got) != "log\n" {
+		t.Errorf("Open() = %q, want the log", got)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() of a missing log error = %v", err)
+	}
+	if err := s.Put(ctx, "../escape.log", strings.NewReader("x"), 1); err == nil {
+		t.Error("Put() with an invalid key succeeded")
+	}
+}
+
+func TestS3SinkReportsErrors(t *testing.T) {
+	srv, _ := newS3Server(t)
+	// Without credentials requests are not signed and the server refuses them.
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	s, err := NewS3Sink(S3Config{Endpoint: srv.URL, Bucket: "logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Put(context.Background(), "a.log", strings.NewReader("x"), 1)
+	if err == nil || !strings.Contains(err.Error(), "status 403: unsigned request") {
+		t.Errorf("Put() error = %v, want the status and body", err)
+	}
+}
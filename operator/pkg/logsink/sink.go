@@ -0,0 +1,119 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when a log does not exist in the sink.
+var ErrNotFound = errors.New("log not found")
+
+// Sink stores the full logs of test and hook pods outside the cluster
+// state; run status only keeps a reference and a short tail. Logs are
+// addressed by slash separated keys.
+type Sink interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+const (
+	// TypePVC stores logs in a directory, usually a mounted PersistentVolumeClaim.
+	TypePVC = "pvc"
+	// TypeS3 stores logs in an S3-compatible bucket, e.g. MinIO.
+	TypeS3 = "s3"
+)
+
+// Config selects and configures the sink.
+//
+//	logSink:
+//	  type: s3
+//	  s3:
+//	    endpoint: http://minio.steer-system.svc:9000
+//	    bucket: steer-logs
+type Config struct {
+	// Type is pvc or s3. Without a type, logs are only kept as a tail in
+	// the run status.
+	Type string `json:"type,omitempty"`
+
+	// Dir is where type pvc stores logs.
+	Dir string `json:"dir,omitempty"`
+
+	S3 S3Config `json:"s3,omitempty"`
+}
+
+// New returns the sink described by cfg, or nil when no sink is configured.
+func New(cfg Config) (Sink, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case TypePVC:
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("log sink %s requires dir", cfg.Type)
+		}
+		return &FileSink{Dir: cfg.Dir}, nil
+	case TypeS3:
+		return NewS3Sink(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown log sink type %q", cfg.Type)
+	}
+}
+
+// Key returns the key of the log of a test or hook in a run.
+func Key(namespace, job, runKey, stage, name string) string {
+	return strings.Join([]string{namespace, job, runKey, stage, name + ".log"}, "/")
+}
+
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid log key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid log key %q", key)
+		}
+	}
+	return nil
+}
+
+// FakeSink is an in-memory Sink for tests.
+type FakeSink struct {
+	mu      sync.Mutex
+	Objects map[string][]byte
+}
+
+func (f *FakeSink) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Objects == nil {
+		f.Objects = map[string][]byte{}
+	}
+	f.Objects[key] = data
+	return nil
+}
+
+func (f *FakeSink) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.Objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (f *FakeSink) Delete(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.Objects, key)
+	return nil
+}
@@ -0,0 +1,66 @@
+package logsink
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		wantNil  bool
+		wantType string
+		wantErr  bool
+	}{
+		{name: "no sink", cfg: Config{}, wantNil: true},
+		{name: "pvc", cfg: Config{Type: TypePVC, Dir: "/logs"}, wantType: "*logsink.FileSink"},
+		{name: "pvc without dir", cfg: Config{Type: TypePVC}, wantErr: true},
+		{name: "s3", cfg: Config{Type: TypeS3, S3: S3Config{Bucket: "logs"}}, wantType: "*logsink.S3Sink"},
+		{name: "s3 without bucket", cfg: Config{Type: TypeS3}, wantErr: true},
+		{name: "s3 with invalid endpoint", cfg: Config{Type: TypeS3, S3: S3Config{Bucket: "logs", Endpoint: "minio:9000"}}, wantErr: true},
+		{name: "unknown type", cfg: Config{Type: "gcs"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (sink == nil) != tt.wantNil {
+				t.Fatalf("New() = %v, want nil %v", sink, tt.wantNil)
+			}
+			if tt.wantType != "" {
+				if got := fmt.Sprintf("%T", sink); got != tt.wantType {
+					t.Errorf("New() = %s, want %s", got, tt.wantType)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{key: Key("default", "job", "20260101-000000", "test", "main")},
+		{key: "a/b.log"},
+		{key: "", wantErr: true},
+		{key: "/a/b.log", wantErr: true},
+		{key: "a//b.log", wantErr: true},
+		{key: "a/../b.log", wantErr: true},
+		{key: "a/./b.log", wantErr: true},
+		{key: "a/b/", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := validateKey(tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("validateKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+const (
+	FormatJUnit = "junit"
+	FormatJSON  = "json"
+)
+
+// Suite names, one per stage of a run.
+const (
+	SuitePreTest  = "preTest"
+	SuiteTest     = "test"
+	SuitePostTest = "postTest"
+)
+
+// Report is the machine-readable summary of a run.
+type Report struct {
+	Namespace       string     `json:"namespace"`
+	Job             string     `json:"job"`
+	Run             string     `json:"run"`
+	Trigger         string     `json:"trigger,omitempty"`
+	ReleaseRevision int64      `json:"releaseRevision,omitempty"`
+	Phase           string     `json:"phase"`
+	Message         string     `json:"message,omitempty"`
+	StartTime       *time.Time `json:"startTime,omitempty"`
+	CompletionTime  *time.Time `json:"completionTime,omitempty"`
+	// Duration is in seconds.
+	Duration float64 `json:"duration"`
+	Tests    int     `json:"tests"`
+	Failures int     `json:"failures"`
+	Skipped  int     `json:"skipped"`
+	Suites   []Suite `json:"suites"`
+}
+
+type Suite struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration"`
+	Tests    int     `json:"tests"`
+	Failures int     `json:"failures"`
+	Skipped  int     `json:"skipped"`
+	Cases    []Case  `json:"cases"`
+}
+
+// Case is a hook, a test pod or a test case parsed from the output of a
+// test pod.
+type Case struct {
+	Name string `json:"name"`
+	// Suite is the suite of a parsed test case.
+	Suite    string  `json:"suite,omitempty"`
+	Phase    string  `json:"phase"`
+	Message  string  `json:"message,omitempty"`
+	Duration float64 `json:"duration"`
+	JobName  string  `json:"jobName,omitempty"`
+	PodName  string  `json:"podName,omitempty"`
+	ExitCode *int32  `json:"exitCode,omitempty"`
+	Attempts int32   `json:"attempts,omitempty"`
+	// Logs is the log tail kept in the run status.
+	Logs string `json:"logs,omitempty"`
+}
+
+// Key returns the log sink key of a report of a run.
+func Key(namespace, job, runKey, format string) string {
+	ext := format
+	if format == FormatJUnit {
+		ext = "xml"
+	}
+	return strings.Join([]string{namespace, job, runKey, "report." + ext}, "/")
+}
+
+// Build summarizes the status of a run.
+func Build(run *steerv1alpha1.HelmTestRun) *Report {
+	status := &run.Status
+	rep := &Report{
+		Namespace:       run.Namespace,
+		Job:             run.Spec.HelmTestJobName,
+		Run:             run.Name,
+		Trigger:         string(run.Spec.Trigger),
+		ReleaseRevision: run.Spec.ReleaseRevision,
+		Phase:           string(status.Phase),
+		Message:         status.Message,
+		StartTime:       timeOf(status.StartTime),
+		CompletionTime:  timeOf(status.CompletionTime),
+		Duration:        seconds(status.StartTime, status.CompletionTime),
+	}
+
+	var preTest, postTest []steerv1alpha1.HookResult
+	if status.HookResults != nil {
+		preTest, postTest = status.HookResults.PreTest, status.HookResults.PostTest
+	}
+	tests := make([]Case, 0, len(status.TestResults))
+	for _, tr := range status.TestResults {
+		if len(tr.Cases) > 0 {
+			tests = append(tests, testCases(tr)...)
+			continue
+		}
+		tests = append(tests, Case{
+			Name:     tr.Name,
+			Phase:    string(tr.Phase),
+			Message:  tr.Message,
+			Duration: seconds(tr.StartedAt, tr.CompletedAt),
+			JobName:  tr.JobName,
+			PodName:  tr.PodName,
+			Attempts: tr.Attempts,
+			Logs:     tr.Logs,
+		})
+	}
+	rep.Suites = []Suite{
+		newSuite(SuitePreTest, hookCases(preTest)),
+		newSuite(SuiteTest, tests),
+		newSuite(SuitePostTest, hookCases(postTest)),
+	}
+	for _, suite := range rep.Suites {
+		rep.Tests += suite.Tests
+		rep.Failures += suite.Failures
+		rep.Skipped += suite.Skipped
+	}
+	return rep
+}
+
+// testCases returns the parsed test cases of a test pod.
+func testCases(tr steerv1alpha1.TestResult) []Case {
+	cases := make([]Case, 0, len(tr.Cases))
+	for _, tc := range tr.Cases {
+		c := Case{
+			Name:     tc.Name,
+			Suite:    tc.Suite,
+			Phase:    string(tc.Phase),
+			Message:  tc.Message,
+			JobName:  tr.JobName,
+			PodName:  tr.PodName,
+			Attempts: tc.Attempts,
+		}
+		if tc.Duration != nil {
+			c.Duration = tc.Duration.Seconds()
+		}
+		cases = append(cases, c)
+	}
+	return cases
+}
+
+func hookCases(results []steerv1alpha1.HookResult) []Case {
+	cases := make([]Case, 0, len(results))
+	for _, hr := range results {
+		cases = append(cases, Case{
+			Name:     hr.Name,
+			Phase:    string(hr.Phase),
+			Message:  hr.Message,
+			Duration: seconds(hr.StartedAt, hr.CompletedAt),
+			JobName:  hr.JobName,
+			PodName:  hr.PodName,
+			ExitCode: hr.ExitCode,
+			Attempts: hr.Attempts,
+			Logs:     hr.Logs,
+		})
+	}
+	return cases
+}
+
+func newSuite(name string, cases []Case) Suite {
+	suite := Suite{Name: name, Cases: cases, Tests: len(cases)}
+	for _, c := range cases {
+		suite.Duration += c.Duration
+		switch {
+		case failed(c):
+			suite.Failures++
+		case skipped(c):
+			suite.Skipped++
+		}
+	}
+	return suite
+}
+
+func failed(c Case) bool {
+	return c.Phase == string(steerv1alpha1.HelmTestJobPhaseFailed)
+}
+
+// skipped reports cases that didn't run to completion, including the ones
+// of a run that is still in progress.
+func skipped(c Case) bool {
+	return c.Phase != string(steerv1alpha1.HelmTestJobPhaseSucceeded) && !failed(c)
+}
+
+// JSON encodes the report as indented JSON.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// JUnit encodes the report as JUnit XML with a test suite per stage.
+func (r *Report) JUnit() ([]byte, error) {
+	out := junitTestSuites{
+		Name:     r.Namespace + "/" + r.Job,
+		Tests:    r.Tests,
+		Failures: r.Failures,
+		Skipped:  r.Skipped,
+		Time:     junitTime(r.Duration),
+	}
+	for _, suite := range r.Suites {
+		js := junitTestSuite{
+			Name:     suite.Name,
+			Tests:    suite.Tests,
+			Failures: suite.Failures,
+			Skipped:  suite.Skipped,
+			Time:     junitTime(suite.Duration),
+		}
+		if r.StartTime != nil {
+			js.Timestamp = r.StartTime.UTC().Format(time.RFC3339)
+		}
+		for _, c := range suite.Cases {
+			jc := junitTestCase{
+				Name:      c.Name,
+				Classname: fmt.Sprintf("%s.%s.%s", r.Namespace, r.Job, suite.Name),
+				Time:      junitTime(c.Duration),
+			}
+			if c.Suite != "" {
+				jc.Classname = c.Suite
+			}
+			switch {
+			case failed(c):
+				jc.Failure = &junitMessage{Message: c.Message, Body: c.Logs}
+			case skipped(c):
+				jc.Skipped = &junitMessage{Message: c.Message}
+			default:
+				jc.SystemOut = c.Logs
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+		out.Suites = append(out.Suites, js)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// Encode returns the report in the given format.
+func (r *Report) Encode(format string) ([]byte, error) {
+	switch format {
+	case FormatJUnit:
+		return r.JUnit()
+	case FormatJSON:
+		return r.JSON()
+	default:
+		return nil, fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+func junitTime(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+func timeOf(t *metav1.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return &t.Time
+}
+
+func seconds(start, end *metav1.Time) float64 {
+	if start == nil || end == nil {
+		return 0
+	}
+	return end.Sub(start.Time).Seconds()
+}
@@ -0,0 +1,206 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+var start = time.Date(2026, 1, 28, 10, 0, 0, 0, time.UTC)
+
+func at(seconds int) *metav1.Time {
+	return &metav1.Time{Time: start.Add(time.Duration(seconds) * time.Second)}
+}
+
+func testRun() *steerv1alpha1.HelmTestRun {
+	exitCode := int32(1)
+	return &steerv1alpha1.HelmTestRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "job-20260128-100000", Namespace: "default"},
+		Spec: steerv1alpha1.HelmTestRunSpec{
+			HelmTestJobName: "job",
+			Trigger:         steerv1alpha1.HelmTestRunTriggerManual,
+			ReleaseRevision: 3,
+		},
+		Status: steerv1alpha1.HelmTestRunStatus{
+			Phase:          steerv1alpha1.HelmTestJobPhaseFailed,
+			Message:        "test main failed",
+			StartTime:      at(0),
+			CompletionTime: at(60),
+			HookResults: &steerv1alpha1.HookResults{
+				PreTest: []steerv1alpha1.HookResult{
+					{Name: "seed", Phase: steerv1alpha1.HelmTestJobPhaseSucceeded, StartedAt: at(0), CompletedAt: at(5), Logs: "seeded"},
+				},
+				PostTest: []steerv1alpha1.HookResult{
+					{Name: "notify", Phase: steerv1alpha1.HelmTestJobPhaseFailed, Message: "exit 1", ExitCode: &exitCode, StartedAt: at(50), CompletedAt: at(52), Logs: "boom"},
+					{Name: "archive", Phase: steerv1alpha1.HelmTestJobPhasePending},
+				},
+			},
+			TestResults: []steerv1alpha1.TestResult{
+				{Name: "smoke", Phase: steerv1alpha1.HelmTestJobPhaseSucceeded, StartedAt: at(5), CompletedAt: at(15), JobName: "job-smoke"},
+				{
+					Name: "main", Phase: steerv1alpha1.HelmTestJobPhaseFailed, JobName: "job-main", PodName: "job-main-x",
+					Cases: []steerv1alpha1.TestCaseResult{
+						{Name: "TestA", Suite: "pkg/a", Phase: steerv1alpha1.HelmTestJobPhaseSucceeded, Duration: &metav1.Duration{Duration: 1500 * time.Millisecond}, Attempts: 2},
+						{Name: "TestB", Suite: "pkg/a", Phase: steerv1alpha1.HelmTestJobPhaseFailed, Message: "want 1, got 2"},
+						{Name: "TestC", Suite: "pkg/b", Phase: steerv1alpha1.HelmTestJobPhaseSkipped, Message: "slow"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	rep := Build(testRun())
+
+	if rep.Job != "job" || rep.Run != "job-20260128-100000" || rep.Trigger != "Manual" || rep.ReleaseRevision != 3 {
+		t.Errorf("Build() identifies %s/%s trigger %s revision %d", rep.Job, rep.Run, rep.Trigger, rep.ReleaseRevision)
+	}
+	if rep.Duration != 60 {
+		t.Errorf("Build() duration = %v, want 60", rep.Duration)
+	}
+	type counts struct{ tests, failures, skipped int }
+	if got, want := (counts{rep.Tests, rep.Failures, rep.Skipped}), (counts{7, 2, 2}); got != want {
+		t.Errorf("Build() counts = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		suite string
+		cases []string
+		want  counts
+	}{
+		{suite: SuitePreTest, cases: []string{"seed"}, want: counts{1, 0, 0}},
+		// Test pods with parsed cases are replaced by their cases.
+		{suite: SuiteTest, cases: []string{"smoke", "TestA", "TestB", "TestC"}, want: counts{4, 1, 1}},
+		// Hooks that never finished count as skipped.
+		{suite: SuitePostTest, cases: []string{"notify", "archive"}, want: counts{2, 1, 1}},
+	}
+	if len(rep.Suites) != len(tests) {
+		t.Fatalf("Build() has %d suites, want %d", len(rep.Suites), len(tests))
+	}
+	for i, tt := range tests {
+		suite := rep.Suites[i]
+		var names []string
+		for _, c := range suite.Cases {
+			names = append(names, c.Name)
+		}
+		if suite.Name != tt.suite || !reflect.DeepEqual(names, tt.cases) {
+			t.Errorf("suite %d = %s %v, want %s %v", i, suite.Name, names, tt.suite, tt.cases)
+		}
+		if got := (counts{suite.Tests, suite.Failures, suite.Skipped}); got != tt.want {
+			t.Errorf("suite %s counts = %+v, want %+v", suite.Name, got, tt.want)
+		}
+	}
+
+	testA := rep.Suites[1].Cases[1]
+	if testA.Suite != "pkg/a" || testA.Duration != 1.5 || testA.Attempts != 2 || testA.PodName != "job-main-x" {
+		t.Errorf("Build() case TestA = %+v", testA)
+	}
+}
+
+func TestBuildEmptyRun(t *testing.T) {
+	rep := Build(&steerv1alpha1.HelmTestRun{ObjectMeta: metav1.ObjectMeta{Name: "run"}})
+	if rep.Tests != 0 || rep.Duration != 0 || rep.StartTime != nil || len(rep.Suites) != 3 {
+		t.Errorf("Build() = %+v, want three empty suites", rep)
+	}
+	for _, format := range []string{FormatJUnit, FormatJSON} {
+		if _, err := rep.Encode(format); err != nil {
+			t.Errorf("Encode(%s) error = %v", format, err)
+		}
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	out, err := Build(testRun()).Encode(FormatJUnit)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !strings.HasPrefix(string(out), xml.Header) {
+		t.Errorf("JUnit() doesn't start with the XML header")
+	}
+	var got junitTestSuites
+	if err := xml.Unmarshal(out, &got); err != nil {
+		t.Fatalf("JUnit() is not valid XML: %v", err)
+	}
+	if got.Name != "default/job" || got.Tests != 7 || got.Failures != 2 || got.Skipped != 2 || got.Time != "60.000" {
+		t.Errorf("JUnit() test suites = %s %d/%d/%d %s", got.Name, got.Tests, got.Failures, got.Skipped, got.Time)
+	}
+	if len(got.Suites) != 3 || got.Suites[0].Timestamp != "2026-01-28T10:00:00Z" {
+		t.Fatalf("JUnit() suites = %+v", got.Suites)
+	}
+
+	tests := []struct {
+		suite, name, classname string
+		failure, skipped       *junitMessage
+		systemOut              string
+	}{
+		{suite: "preTest", name: "seed", classname: "default.job.preTest", systemOut: "seeded"},
+		{suite: "test", name: "TestB", classname: "pkg/a", failure: &junitMessage{Message: "want 1, got 2"}},
+		{suite: "test", name: "TestC", classname: "pkg/b", skipped: &junitMessage{Message: "slow"}},
+		{suite: "postTest", name: "notify", classname: "default.job.postTest", failure: &junitMessage{Message: "exit 1", Body: "boom"}},
+		{suite: "postTest", name: "archive", classname: "default.job.postTest", skipped: &junitMessage{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var found *junitTestCase
+			for i := range got.Suites {
+				for j := range got.Suites[i].Cases {
+					if got.Suites[i].Name == tt.suite && got.Suites[i].Cases[j].Name == tt.name {
+						found = &got.Suites[i].Cases[j]
+					}
+				}
+			}
+			if found == nil {
+				t.Fatalf("no test case %s in suite %s", tt.name, tt.suite)
+			}
+			if found.Classname != tt.classname || found.SystemOut != tt.systemOut {
+				t.Errorf("test case = %s %q, want %s %q", found.Classname, found.SystemOut, tt.classname, tt.systemOut)
+			}
+			if !reflect.DeepEqual(found.Failure, tt.failure) || !reflect.DeepEqual(found.Skipped, tt.skipped) {
+				t.Errorf("test case failure %+v skipped %+v, want %+v %+v", found.Failure, found.Skipped, tt.failure, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	want := Build(testRun())
+	out, err := want.Encode(FormatJSON)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var got Report
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("JSON() is not valid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("JSON() round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestEncodeUnknownFormat(t *testing.T) {
+	if _, err := Build(testRun()).Encode("html"); err == nil {
+		t.Error("Encode(html) succeeded")
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		format, want string
+	}{
+		{format: FormatJUnit, want: "default/job/r1/report.xml"},
+		{format: FormatJSON, want: "default/job/r1/report.json"},
+	}
+	for _, tt := range tests {
+		if got := Key("default", "job", "r1", tt.format); got != tt.want {
+			t.Errorf("Key(%s) = %s, want %s", tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package results
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+const (
+	// maxOutputBytes bounds how much test output is read.
+	maxOutputBytes = 32 << 20
+	// maxMessageBytes bounds the message of a case kept in the status.
+	maxMessageBytes = 1024
+)
+
+// Parse reads structured test output and returns its test cases. At most
+// maxCases cases are returned; the counts cover all of them. Output that is
+// not part of the format, like log lines around it, is ignored.
+func Parse(format steerv1alpha1.ResultFormat, r io.Reader, maxCases int) ([]steerv1alpha1.TestCaseResult, steerv1alpha1.TestCaseCounts, error) {
+	c := &collector{max: maxCases}
+	r = io.LimitReader(r, maxOutputBytes)
+	var err error
+	switch format {
+	case steerv1alpha1.ResultFormatJUnit:
+		err = parseJUnit(r, c)
+	case steerv1alpha1.ResultFormatTAP:
+		err = parseTAP(r, c)
+	case steerv1alpha1.ResultFormatGoTest:
+		err = parseGoTest(r, c)
+	default:
+		err = fmt.Errorf("unknown result format %q", format)
+	}
+	return c.cases, c.counts, err
+}
+
+type collector struct {
+	max    int
+	cases  []steerv1alpha1.TestCaseResult
+	counts steerv1alpha1.TestCaseCounts
+}
+
+func (c *collector) add(tc steerv1alpha1.TestCaseResult) {
+	c.counts.Total++
+	switch tc.Phase {
+	case steerv1alpha1.HelmTestJobPhaseSucceeded:
+		c.counts.Passed++
+	case steerv1alpha1.HelmTestJobPhaseFailed:
+		c.counts.Failed++
+	default:
+		c.counts.Skipped++
+	}
+	if len(c.cases) < c.max {
+		tc.Message = truncate(strings.TrimSpace(tc.Message))
+		c.cases = append(c.cases, tc)
+	}
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// parseJUnit reads the testcase elements of JUnit XML. Parsing starts at the
+// first testsuites or testsuite element and stops at the end of the XML.
+func parseJUnit(r io.Reader, c *collector) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	start := bytes.Index(data, []byte("<testsuite"))
+	if start < 0 {
+		return errors.New("no JUnit testsuite found")
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data[start:]))
+	dec.Strict = false
+	var suites []string
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) || (depth == 0 && c.counts.Total > 0) {
+				return nil
+			}
+			return fmt.Errorf("parse JUnit: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "testsuite":
+				suites = append(suites, attr(t, "name"))
+			case "testcase":
+				var jc junitCase
+				if err := dec.DecodeElement(&jc, &t); err != nil {
+					return fmt.Errorf("parse JUnit: %w", err)
+				}
+				suite := jc.Classname
+				if suite == "" && len(suites) > 0 {
+					suite = suites[len(suites)-1]
+				}
+				c.add(junitResult(jc, suite))
+				continue
+			}
+			depth++
+		case xml.EndElement:
+			if t.Name.Local == "testsuite" && len(suites) > 0 {
+				suites = suites[:len(suites)-1]
+			}
+			depth--
+		}
+	}
+}
+
+func junitResult(jc junitCase, suite string) steerv1alpha1.TestCaseResult {
+	tc := steerv1alpha1.TestCaseResult{Name: jc.Name, Suite: suite, Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}
+	if secs, err := strconv.ParseFloat(jc.Time, 64); err == nil {
+		tc.Duration = &metav1.Duration{Duration: time.Duration(secs * float64(time.Second))}
+	}
+	switch {
+	case jc.Failure != nil:
+		tc.Phase, tc.Message = steerv1alpha1.HelmTestJobPhaseFailed, messageOf(jc.Failure)
+	case jc.Error != nil:
+		tc.Phase, tc.Message = steerv1alpha1.HelmTestJobPhaseFailed, messageOf(jc.Error)
+	case jc.Skipped != nil:
+		tc.Phase, tc.Message = steerv1alpha1.HelmTestJobPhaseSkipped, messageOf(jc.Skipped)
+	}
+	return tc
+}
+
+func messageOf(m *junitMessage) string {
+	if m.Message != "" {
+		return m.Message
+	}
+	return m.Body
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+var tapLine = regexp.MustCompile(`^(not ok|ok)\b\s*(\d+)?\s*(?:-\s*)?([^#]*?)\s*(?:#\s*(\S+)\s*(.*))?$`)
+
+// parseTAP reads the top level test points of TAP output. The YAML
+// diagnostics following a failed test point become its message.
+func parseTAP(r io.Reader, c *collector) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var (
+		pending     *steerv1alpha1.TestCaseResult
+		diagnostics []string
+		inYAML      bool
+	)
+	flush := func() {
+		if pending == nil {
+			return
+		}
+		if len(diagnostics) > 0 {
+			pending.Message = strings.Join(diagnostics, "\n")
+		}
+		c.add(*pending)
+		pending, diagnostics, inYAML = nil, nil, false
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if pending != nil && pending.Phase == steerv1alpha1.HelmTestJobPhaseFailed {
+			switch trimmed := strings.TrimSpace(line); {
+			case !inYAML && trimmed == "---" && line != trimmed:
+				inYAML = true
+				continue
+			case inYAML && trimmed == "...":
+				inYAML = false
+				continue
+			case inYAML:
+				diagnostics = append(diagnostics, trimmed)
+				continue
+			}
+		}
+		m := tapLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		flush()
+		tc := steerv1alpha1.TestCaseResult{Name: m[3], Phase: steerv1alpha1.HelmTestJobPhaseSucceeded}
+		if tc.Name == "" {
+			tc.Name = "test " + m[2]
+		}
+		if m[1] == "not ok" {
+			tc.Phase = steerv1alpha1.HelmTestJobPhaseFailed
+		}
+		switch strings.ToUpper(m[4]) {
+		case "SKIP":
+			tc.Phase, tc.Message = steerv1alpha1.HelmTestJobPhaseSkipped, m[5]
+		case "TODO":
+			// Failing TODO tests are expected to fail.
+			if tc.Phase == steerv1alpha1.HelmTestJobPhaseFailed {
+				tc.Phase = steerv1alpha1.HelmTestJobPhaseSkipped
+			}
+			tc.Message = m[5]
+		}
+		pending = &tc
+	}
+	flush()
+	return scanner.Err()
+}
+
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// parseGoTest reads go test -json output. The output of a failed test
+// becomes its message.
+func parseGoTest(r io.Reader, c *collector) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	outputs := map[string]*strings.Builder{}
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var ev goTestEvent
+		if err := json.Unmarshal(line, &ev); err != nil || ev.Test == "" {
+			continue
+		}
+		key := ev.Package + "\x00" + ev.Test
+		switch ev.Action {
+		case "output":
+			out := outputs[key]
+			if out == nil {
+				out = &strings.Builder{}
+				outputs[key] = out
+			}
+			if out.Len() < maxMessageBytes {
+				out.WriteString(ev.Output)
+			}
+		case "pass", "fail", "skip":
+			tc := steerv1alpha1.TestCaseResult{
+				Name:     ev.Test,
+				Suite:    ev.Package,
+				Phase:    steerv1alpha1.HelmTestJobPhaseSucceeded,
+				Duration: &metav1.Duration{Duration: time.Duration(ev.Elapsed * float64(time.Second))},
+			}
+			if ev.Action == "fail" {
+				tc.Phase = steerv1alpha1.HelmTestJobPhaseFailed
+			} else if ev.Action == "skip" {
+				tc.Phase = steerv1alpha1.HelmTestJobPhaseSkipped
+			}
+			if out := outputs[key]; out != nil && tc.Phase != steerv1alpha1.HelmTestJobPhaseSucceeded {
+				tc.Message = out.String()
+			}
+			delete(outputs, key)
+			c.add(tc)
+		}
+	}
+	return scanner.Err()
+}
+
+func truncate(s string) string {
+	if len(s) <= maxMessageBytes {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxMessageBytes], "") + "..."
+}
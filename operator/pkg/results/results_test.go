@@ -0,0 +1,267 @@
+package results
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	steerv1alpha1 "github.com/MrLYC/steer/operator/api/v1alpha1"
+)
+
+// summarize renders cases as "phase suite/name: message" for comparison.
+func summarize(cases []steerv1alpha1.TestCaseResult) []string {
+	var out []string
+	for _, tc := range cases {
+		s := fmt.Sprintf("%s %s/%s", tc.Phase, tc.Suite, tc.Name)
+		if tc.Message != "" {
+			s += ": " + tc.Message
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func counts(total, passed, failed, skipped int32) steerv1alpha1.TestCaseCounts {
+	return steerv1alpha1.TestCaseCounts{Total: total, Passed: passed, Failed: failed, Skipped: skipped}
+}
+
+type parseTest struct {
+	name       string
+	input      string
+	maxCases   int
+	want       []string
+	wantCounts steerv1alpha1.TestCaseCounts
+	wantErr    bool
+}
+
+func runParseTests(t *testing.T, format steerv1alpha1.ResultFormat, tests []parseTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxCases := tt.maxCases
+			if maxCases == 0 {
+				maxCases = 100
+			}
+			cases, got, err := Parse(format, strings.NewReader(tt.input), maxCases)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(summarize(cases), tt.want) {
+				t.Errorf("Parse() cases =\n%s\nwant\n%s", strings.Join(summarize(cases), "\n"), strings.Join(tt.want, "\n"))
+			}
+			if got != tt.wantCounts {
+				t.Errorf("Parse() counts = %+v, want %+v", got, tt.wantCounts)
+			}
+		})
+	}
+}
+
+func TestParseJUnit(t *testing.T) {
+	runParseTests(t, steerv1alpha1.ResultFormatJUnit, []parseTest{
+		{
+			name: "nested suites with log lines around",
+			input: `running tests...
+<?xml version="1.0"?>
+<testsuites>
+  <testsuite name="api">
+    <testcase name="create" classname="api.Users" time="0.5"/>
+    <testcase name="delete" time="1">
+      <failure message="expected 204">stack</failure>
+    </testcase>
+    <testsuite name="api.admin">
+      <testcase name="ban"><error>panic</error></testcase>
+    </testsuite>
+    <testcase name="list"><skipped message="flaky"/></testcase>
+  </testsuite>
+</testsuites>
+done`,
+			want: []string{
+				"Succeeded api.Users/create",
+				"Failed api/delete: expected 204",
+				"Failed api.admin/ban: panic",
+				"Skipped api/list: flaky",
+			},
+			wantCounts: counts(4, 1, 2, 1),
+		},
+		{
+			name:       "single suite",
+			input:      `<testsuite name="s"><testcase name="a"/></testsuite>`,
+			want:       []string{"Succeeded s/a"},
+			wantCounts: counts(1, 1, 0, 0),
+		},
+		{
+			name:     "truncated to maxCases",
+			input:    `<testsuite name="s"><testcase name="a"/><testcase name="b"><failure/></testcase><testcase name="c"/></testsuite>`,
+			maxCases: 1,
+			want:     []string{"Succeeded s/a"},
+			// The counts still cover every case.
+			wantCounts: counts(3, 2, 1, 0),
+		},
+		{
+			name:    "no JUnit",
+			input:   "PASS\nok  \texample.com/pkg\t0.01s\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed XML",
+			input:   `<testsuite name="s"><testcase name="a"><failure>unterminated`,
+			wantErr: true,
+		},
+		{
+			name:       "garbage after the document",
+			input:      `<testsuite name="s"><testcase name="a"/></testsuite> <<< trailing`,
+			want:       []string{"Succeeded s/a"},
+			wantCounts: counts(1, 1, 0, 0),
+		},
+	})
+}
+
+func TestParseJUnitDuration(t *testing.T) {
+	cases, _, err := Parse(steerv1alpha1.ResultFormatJUnit, strings.NewReader(`<testsuite><testcase name="a" time="1.25"/><testcase name="b" time="n/a"/></testsuite>`), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cases[0].Duration == nil || cases[0].Duration.Duration != 1250*time.Millisecond {
+		t.Errorf("duration = %v, want 1.25s", cases[0].Duration)
+	}
+	if cases[1].Duration != nil {
+		t.Errorf("invalid time parsed as %v", cases[1].Duration)
+	}
+}
+
+func TestParseTAP(t *testing.T) {
+	runParseTests(t, steerv1alpha1.ResultFormatTAP, []parseTest{
+		{
+			name: "test points with diagnostics",
+			input: `TAP version 13
+1..4
+ok 1 - login works
+not ok 2 - logout works
+  ---
+  message: expected 200
+  got: 500
+  ...
+# a comment
+ok 3
+not ok 4 checkout
+`,
+			want: []string{
+				"Succeeded /login works",
+				"Failed /logout works: message: expected 200\ngot: 500",
+				"Succeeded /test 3",
+				"Failed /checkout",
+			},
+			wantCounts: counts(4, 2, 2, 0),
+		},
+		{
+			name: "skip and todo",
+			input: `1..4
+ok 1 - cache # SKIP no redis
+not ok 2 - search # TODO not implemented
+ok 3 - export # todo finished early
+not ok 4 - upload # skip no bucket
+`,
+			want: []string{
+				"Skipped /cache: no redis",
+				"Skipped /search: not implemented",
+				"Succeeded /export: finished early",
+				"Skipped /upload: no bucket",
+			},
+			wantCounts: counts(4, 1, 0, 3),
+		},
+		{
+			name: "subtests and log lines are ignored",
+			input: `starting
+    ok 1 - inner
+ok 1 - outer
+okay then
+`,
+			want:       []string{"Succeeded /outer"},
+			wantCounts: counts(1, 1, 0, 0),
+		},
+		{
+			name:       "truncated to maxCases",
+			input:      "ok 1 - a\nnot ok 2 - b\nok 3 - c # SKIP\n",
+			maxCases:   2,
+			want:       []string{"Succeeded /a", "Failed /b"},
+			wantCounts: counts(3, 1, 1, 1),
+		},
+		{
+			name:       "no test points",
+			input:      "1..0 # Skipped: nothing to do\n",
+			wantCounts: counts(0, 0, 0, 0),
+		},
+	})
+}
+
+func goTestLine(action, pkg, test, output string) string {
+	return fmt.Sprintf(`{"Action":%q,"Package":%q,"Test":%q,"Output":%q,"Elapsed":0.5}`, action, pkg, test, output)
+}
+
+func TestParseGoTest(t *testing.T) {
+	runParseTests(t, steerv1alpha1.ResultFormatGoTest, []parseTest{
+		{
+			name: "interleaved packages",
+			input: strings.Join([]string{
+				goTestLine("run", "a", "TestX", ""),
+				goTestLine("run", "b", "TestX", ""),
+				goTestLine("output", "a", "TestX", "a: want 1\n"),
+				goTestLine("output", "b", "TestX", "b: fine\n"),
+				goTestLine("fail", "a", "TestX", ""),
+				goTestLine("output", "b", "TestY", "b: no network\n"),
+				goTestLine("skip", "b", "TestY", ""),
+				goTestLine("pass", "b", "TestX", ""),
+				`{"Action":"pass","Package":"a","Elapsed":1}`,
+			}, "\n"),
+			want: []string{
+				"Failed a/TestX: a: want 1",
+				"Skipped b/TestY: b: no network",
+				"Succeeded b/TestX",
+			},
+			wantCounts: counts(3, 1, 1, 1),
+		},
+		{
+			name: "malformed lines are ignored",
+			input: strings.Join([]string{
+				"go: downloading example.com/dep v1.0.0",
+				`{"Action":"pass","Test":`,
+				goTestLine("pass", "a", "TestOK", ""),
+				"{not json}",
+			}, "\n"),
+			want:       []string{"Succeeded a/TestOK"},
+			wantCounts: counts(1, 1, 0, 0),
+		},
+		{
+			name: "truncated to maxCases",
+			input: strings.Join([]string{
+				goTestLine("pass", "a", "Test1", ""),
+				goTestLine("fail", "a", "Test2", ""),
+				goTestLine("pass", "a", "Test3", ""),
+			}, "\n"),
+			maxCases:   1,
+			want:       []string{"Succeeded a/Test1"},
+			wantCounts: counts(3, 2, 1, 0),
+		},
+	})
+}
+
+func TestParseTruncatesMessages(t *testing.T) {
+	long := strings.Repeat("é", maxMessageBytes)
+	input := fmt.Sprintf(`<testsuite><testcase name="a"><failure message=%q/></testcase></testsuite>`, long)
+	cases, _, err := Parse(steerv1alpha1.ResultFormatJUnit, strings.NewReader(input), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := cases[0].Message
+	if !strings.HasSuffix(msg, "...") || len(msg) > maxMessageBytes+3 || !strings.HasPrefix(long, strings.TrimSuffix(msg, "...")) {
+		t.Errorf("message of %d bytes was truncated to %d bytes", len(long), len(msg))
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, _, err := Parse("csv", strings.NewReader(""), 1); err == nil {
+		t.Error("Parse(csv) succeeded")
+	}
+}
@@ -54,6 +54,10 @@ export interface HelmRelease {
       deleteNamespace?: boolean;
       deleteImages?: boolean;
     };
+    concurrencyPolicy?: 'Allow' | 'Forbid' | 'Replace';
+    startingDeadlineSeconds?: number;
+    successfulRunsHistoryLimit?: number;
+    failedRunsHistoryLimit?: number;
   };
   status: {
     phase: string;
@@ -75,8 +79,9 @@ export interface HelmTestJob {
       name: string;
       namespace: string;
     };
+    environment?: 'shared' | 'ephemeral';
     schedule: {
-      type: 'once' | 'cron';
+      type: 'once' | 'cron' | 'onRelease';
       delay?: string;
       cron?: string;
       timezone?: string;
@@ -85,6 +90,19 @@ export interface HelmTestJob {
       timeout?: string;
       logs?: boolean;
       filter?: string;
+      env?: EnvVar[];
+      resultFormat?: 'junit' | 'tap' | 'goTest';
+      retries?: number;
+    };
+    runTimeout?: string;
+    podTemplate?: PodTemplateOverlay;
+    rbac?: {
+      rules?: {
+        apiGroups?: string[];
+        resources?: string[];
+        verbs: string[];
+        resourceNames?: string[];
+      }[];
     };
     hooks?: {
       preTest?: Hook[];
@@ -93,6 +111,8 @@ export interface HelmTestJob {
     cleanup?: {
       deleteNamespace?: boolean;
       deleteImages?: boolean;
+      when?: 'always' | 'onSuccess' | 'onFailure';
+      delay?: string;
     };
   };
   status: {
@@ -100,16 +120,152 @@ export interface HelmTestJob {
     message?: string;
     startTime?: string;
     completionTime?: string;
+    lastRunName?: string;
+    testCounts?: TestCaseCounts;
+    flakiness?: TestFlakiness[];
+    lastTestedRevision?: number;
+    lastRunRequest?: string;
+    lastCancelRequest?: string;
+  };
+}
+
+// RunRequest is returned when a manual run is requested. The run shows up in
+// the job's runs once the operator has picked up the request.
+export interface RunRequest {
+  runRequestedAt: string;
+}
+
+// CancelRequest is returned when cancellation of the active runs is requested.
+export interface CancelRequest {
+  cancelRequestedAt: string;
+  cancelRequestedBy: string;
+}
+
+export interface HelmTestRun {
+  apiVersion: string;
+  kind: string;
+  metadata: {
+    name: string;
+    namespace: string;
+    creationTimestamp?: string;
+  };
+  spec: {
+    helmTestJobName: string;
+    runKey: string;
+    trigger?: 'Schedule' | 'Manual' | 'Release';
+    scheduledTime?: string;
+    releaseRevision?: number;
+  };
+  status: {
+    phase?: string;
+    message?: string;
+    startTime?: string;
+    completionTime?: string;
+    cancelledAt?: string;
+    cancelledBy?: string;
+    reports?: {
+      junit?: string;
+      json?: string;
+    };
+    timedOutAt?: string;
+    environment?: {
+      namespace: string;
+      releaseName: string;
+      revision?: number;
+      installedAt?: string;
+      uninstalledAt?: string;
+    };
+    rbac?: {
+      serviceAccountName: string;
+      namespace: string;
+    };
+    cleanup?: {
+      namespace: string;
+      deleteNamespace?: boolean;
+      deleteImages?: boolean;
+      phase: string;
+      message?: string;
+      scheduledAt?: string;
+      startedAt?: string;
+      completedAt?: string;
+    };
     testResults?: TestResult[];
-    hookResults?: HookResult[];
+    hookResults?: {
+      preTest?: HookResult[];
+      postTest?: HookResult[];
+    };
   };
 }
 
 export interface Hook {
   name: string;
-  type: 'script' | 'kubernetes';
+  type: 'script' | 'kubernetes' | 'http' | 'waitFor' | 'chaos';
+  runPolicy?: 'onSuccess' | 'onFailure' | 'always';
+  continueOnError?: boolean;
+  parallelGroup?: string;
+  timeout?: string;
+  podTemplate?: PodTemplateOverlay;
   env?: EnvVar[];
   script?: string;
+  http?: HTTPHook;
+  waitFor?: WaitForHook;
+  chaos?: ChaosHook;
+}
+
+export interface ChaosHook {
+  action: 'deletePods' | 'scaleToZero';
+  selector?: {
+    matchLabels?: Record<string, string>;
+  };
+  count?: number;
+  deployment?: string;
+  duration?: string;
+}
+
+export interface WaitForHook {
+  apiVersion?: string;
+  kind: string;
+  namespace?: string;
+  name?: string;
+  selector?: {
+    matchLabels?: Record<string, string>;
+    matchExpressions?: { key: string; operator: string; values?: string[] }[];
+  };
+  condition?: string;
+  jsonPath?: string;
+  value?: string;
+  interval?: string;
+}
+
+export interface HTTPHook {
+  url: string;
+  method?: string;
+  headers?: { name: string; value: string }[];
+  body?: string;
+  expectedStatus?: number[];
+  bodyRegex?: string;
+  retries?: number;
+  interval?: string;
+  requestTimeout?: string;
+}
+
+// PodTemplateOverlay mirrors the operator type; Kubernetes objects such as
+// tolerations and volumes are passed through untyped.
+export interface PodTemplateOverlay {
+  labels?: Record<string, string>;
+  annotations?: Record<string, string>;
+  serviceAccountName?: string;
+  resources?: {
+    requests?: Record<string, string>;
+    limits?: Record<string, string>;
+  };
+  nodeSelector?: Record<string, string>;
+  tolerations?: Record<string, unknown>[];
+  securityContext?: Record<string, unknown>;
+  containerSecurityContext?: Record<string, unknown>;
+  volumes?: Record<string, unknown>[];
+  volumeMounts?: Record<string, unknown>[];
+  imagePullSecrets?: { name: string }[];
 }
 
 export interface EnvVar {
@@ -120,6 +276,10 @@ export interface EnvVar {
     helmReleaseRef?: {
       fieldPath: string;
     };
+    hookOutput?: {
+      hook: string;
+      key: string;
+    };
   };
 }
 
@@ -129,12 +289,70 @@ export interface TestResult {
   startedAt: string;
   completedAt: string;
   message?: string;
+  jobName?: string;
+  podName?: string;
+  imageID?: string;
+  logs?: string;
+  logRef?: string;
+  counts?: TestCaseCounts;
+  cases?: TestCaseResult[];
+  attempts?: number;
+  previousAttempts?: {
+    jobName: string;
+    podName?: string;
+    message?: string;
+    logRef?: string;
+    completedAt?: string;
+  }[];
+}
+
+export interface TestFlakiness {
+  name: string;
+  runs: number;
+  flakyRuns: number;
+  // 最近运行中不稳定的百分比
+  score: number;
+}
+
+export interface TestCaseCounts {
+  total: number;
+  passed: number;
+  failed: number;
+  skipped: number;
+}
+
+export interface TestCaseResult {
+  name: string;
+  suite?: string;
+  phase: 'Succeeded' | 'Failed' | 'Skipped';
+  message?: string;
+  duration?: string;
+  attempts?: number;
 }
 
 export interface HookResult {
   name: string;
   phase: string;
   message?: string;
+  startedAt?: string;
+  completedAt?: string;
+  exitCode?: number;
+  jobName?: string;
+  podName?: string;
+  imageID?: string;
+  logs?: string;
+  logRef?: string;
+  outputs?: Record<string, string>;
+  attempts?: number;
+  lastAttemptAt?: string;
+  disruption?: {
+    action: 'deletePods' | 'scaleToZero';
+    namespace: string;
+    targets?: string[];
+    originalReplicas?: number;
+    disruptedAt?: string;
+    restoredAt?: string;
+  };
 }
 
 // API 方法
@@ -150,4 +368,32 @@ export const helmTestJobApi = {
   create: (data: HelmTestJob) => apiClient.post<HelmTestJob>('/helmtestjobs', data),
   get: (namespace: string, name: string) => apiClient.get<HelmTestJob>(`/helmtestjobs/${namespace}/${name}`),
   delete: (namespace: string, name: string) => apiClient.delete(`/helmtestjobs/${namespace}/${name}`),
+  listRuns: (namespace: string, name: string) => apiClient.get<HelmTestRun[]>(`/helmtestjobs/${namespace}/${name}/runs`),
+  run: (namespace: string, name: string) => apiClient.post<RunRequest>(`/helmtestjobs/${namespace}/${name}/run`),
+  // 取消者由服务端记录: 可信代理的 X-Forwarded-User, 否则为 web
+  cancel: (namespace: string, name: string) => apiClient.post<CancelRequest>(`/helmtestjobs/${namespace}/${name}/cancel`),
+  // 完整日志的地址, stage 为 preTest/test/postTest
+  runLogsUrl: (namespace: string, name: string, run: string, stage: string, hook?: string) => {
+    const params = new URLSearchParams({ stage });
+    if (hook) {
+      params.set('hook', hook);
+    }
+    return `${API_BASE_URL}/helmtestjobs/${namespace}/${name}/runs/${run}/logs?${params}`;
+  },
+  // 运行报告的下载地址, 默认为最新一次运行
+  reportUrl: (namespace: string, name: string, format: 'junit' | 'json', run?: string) => {
+    const params = new URLSearchParams({ format });
+    if (run) {
+      params.set('run', run);
+    }
+    return `${API_BASE_URL}/helmtestjobs/${namespace}/${name}/report?${params}`;
+  },
+  // 最新一次运行的实时日志 (SSE), 断线后 EventSource 通过 Last-Event-ID 续传
+  streamLogsUrl: (namespace: string, name: string, stage: string, hook?: string) => {
+    const params = new URLSearchParams({ stage, follow: 'true' });
+    if (hook) {
+      params.set('hook', hook);
+    }
+    return `${API_BASE_URL}/helmtestjobs/${namespace}/${name}/logs?${params}`;
+  },
 };
@@ -1,7 +1,7 @@
 import React, { useEffect, useState } from 'react';
 import { Table, Button, Tag, Space, DialogPlugin, Dialog, Form, Input, Select, MessagePlugin, Drawer } from 'tdesign-react';
-import { AddIcon, RefreshIcon, DeleteIcon, PlayCircleIcon, FileIcon } from 'tdesign-icons-react';
-import { helmTestJobApi, helmReleaseApi, HelmTestJob, HelmRelease } from '../api/client';
+import { AddIcon, RefreshIcon, DeleteIcon, PlayCircleIcon, StopCircleIcon, FileIcon } from 'tdesign-icons-react';
+import { helmTestJobApi, helmReleaseApi, HelmTestJob, HelmTestRun, HelmRelease } from '../api/client';
 
 const HelmTestJobs: React.FC = () => {
   const [jobs, setJobs] = useState<HelmTestJob[]>([]);
@@ -10,6 +10,8 @@ const HelmTestJobs: React.FC = () => {
   const [visible, setVisible] = useState(false);
   const [logVisible, setLogVisible] = useState(false);
   const [currentJob, setCurrentJob] = useState<HelmTestJob | null>(null);
+  const [currentRun, setCurrentRun] = useState<HelmTestRun | null>(null);
+  const [liveLog, setLiveLog] = useState('');
   const [form] = Form.useForm();
 
   useEffect(() => {
@@ -17,6 +19,18 @@ const HelmTestJobs: React.FC = () => {
     loadReleases();
   }, []);
 
+  // 运行中的测试实时展示日志
+  useEffect(() => {
+    setLiveLog('');
+    if (!logVisible || !currentJob || !currentRun || currentRun.status.completionTime) {
+      return;
+    }
+    const source = new EventSource(helmTestJobApi.streamLogsUrl(currentJob.metadata.namespace, currentJob.metadata.name, 'test'));
+    source.onmessage = (event) => setLiveLog(log => log + event.data + '\n');
+    source.addEventListener('end', () => source.close());
+    return () => source.close();
+  }, [logVisible, currentJob, currentRun]);
+
   const loadJobs = async () => {
     setLoading(true);
     try {
@@ -99,9 +113,36 @@ const HelmTestJobs: React.FC = () => {
     }
   };
 
-  const showLogs = (row: HelmTestJob) => {
+  const handleRun = async (row: HelmTestJob) => {
+    try {
+      await helmTestJobApi.run(row.metadata.namespace, row.metadata.name);
+      MessagePlugin.success('Run requested');
+      loadJobs();
+    } catch (error) {
+      MessagePlugin.error('Failed to request run');
+    }
+  };
+
+  const handleCancel = async (row: HelmTestJob) => {
+    try {
+      await helmTestJobApi.cancel(row.metadata.namespace, row.metadata.name);
+      MessagePlugin.success('Cancel requested');
+      loadJobs();
+    } catch (error) {
+      MessagePlugin.error('Failed to cancel job');
+    }
+  };
+
+  const showLogs = async (row: HelmTestJob) => {
     setCurrentJob(row);
+    setCurrentRun(null);
     setLogVisible(true);
+    try {
+      const response = await helmTestJobApi.listRuns(row.metadata.namespace, row.metadata.name);
+      setCurrentRun(response.data[0] ?? null);
+    } catch (error) {
+      MessagePlugin.error('Failed to load runs');
+    }
   };
 
   const columns = [
@@ -129,8 +170,17 @@ const HelmTestJobs: React.FC = () => {
       cell: ({ row }: { row: HelmTestJob }) => {
         const theme = row.status.phase === 'Succeeded' ? 'success' : 
                       row.status.phase === 'Failed' ? 'danger' : 
-                      row.status.phase === 'Running' ? 'warning' : 'primary';
-        return <Tag theme={theme}>{row.status.phase}</Tag>;
+                      row.status.phase === 'Running' ? 'warning' :
+                      row.status.phase === 'Cancelled' ? 'default' : 'primary';
+        const counts = row.status.testCounts;
+        const flaky = row.status.flakiness?.filter(f => f.score > 0).length ?? 0;
+        return (
+          <Space size="small">
+            <Tag theme={theme}>{row.status.phase}</Tag>
+            {counts && <span style={{ fontSize: 12 }}>{counts.passed}/{counts.total} passed</span>}
+            {flaky > 0 && <Tag theme="warning" variant="light">{flaky} flaky</Tag>}
+          </Space>
+        );
       }
     },
     {
@@ -138,6 +188,24 @@ const HelmTestJobs: React.FC = () => {
       title: 'Operation',
       cell: ({ row }: { row: HelmTestJob }) => (
         <Space>
+          <Button
+            theme="primary"
+            variant="text"
+            icon={<PlayCircleIcon />}
+            onClick={() => handleRun(row)}
+          >
+            Rerun
+          </Button>
+          {(row.status.phase === 'Running' || row.status.phase === 'Pending') && (
+            <Button
+              theme="warning"
+              variant="text"
+              icon={<StopCircleIcon />}
+              onClick={() => handleCancel(row)}
+            >
+              Cancel
+            </Button>
+          )}
           <Button 
             theme="primary" 
             variant="text" 
@@ -200,6 +268,7 @@ const HelmTestJobs: React.FC = () => {
             <Select>
               <Select.Option value="once" label="Once" />
               <Select.Option value="cron" label="Cron" />
+              <Select.Option value="onRelease" label="On Release" />
             </Select>
           </Form.FormItem>
           <Form.FormItem 
@@ -240,8 +309,46 @@ const HelmTestJobs: React.FC = () => {
               </div>
             )}
 
+            {currentRun && (
+              <div style={{ marginBottom: 16, fontSize: 12, color: 'var(--td-text-color-secondary)' }}>
+                Run: {currentRun.metadata.name} ({currentRun.spec.trigger})
+                {currentRun.spec.releaseRevision !== undefined && <span>, revision {currentRun.spec.releaseRevision}</span>}
+                {currentRun.status.cancelledBy && <span>, cancelled by {currentRun.status.cancelledBy}</span>}
+                {currentRun.status.environment && <span>, environment {currentRun.status.environment.namespace}</span>}
+                {currentRun.status.cleanup && (
+                  <span title={currentRun.status.cleanup.message}>
+                    , cleanup of {currentRun.status.cleanup.namespace} {currentRun.status.cleanup.phase.toLowerCase()}
+                  </span>
+                )}
+                <Space style={{ marginLeft: 8 }}>
+                  <a href={helmTestJobApi.reportUrl(currentJob.metadata.namespace, currentJob.metadata.name, 'junit', currentRun.metadata.name)}>JUnit report</a>
+                  <a href={helmTestJobApi.reportUrl(currentJob.metadata.namespace, currentJob.metadata.name, 'json', currentRun.metadata.name)}>JSON report</a>
+                </Space>
+              </div>
+            )}
+
+            {liveLog && (
+              <div style={{ marginBottom: 16 }}>
+                <h3>Live Log</h3>
+                <pre style={{ padding: 8, background: 'var(--td-bg-color-secondary)', borderRadius: 4, whiteSpace: 'pre-wrap', maxHeight: 300, overflow: 'auto' }}>
+                  {liveLog}
+                </pre>
+              </div>
+            )}
+
+            {currentJob.status.flakiness?.some(f => f.score > 0) && (
+              <div style={{ marginBottom: 16 }}>
+                <h3>Flaky Tests</h3>
+                {currentJob.status.flakiness.filter(f => f.score > 0).map(f => (
+                  <div key={f.name} style={{ fontSize: 12 }}>
+                    <Tag theme="warning" variant="light" size="small">{f.score}%</Tag> {f.name} ({f.flakyRuns} of {f.runs} runs)
+                  </div>
+                ))}
+              </div>
+            )}
+
             <h3>Test Results</h3>
-            {currentJob.status.testResults?.map((result, index) => (
+            {currentRun?.status.testResults?.map((result, index) => (
               <div key={index} style={{ marginBottom: 12, padding: 12, border: '1px solid var(--td-border-level-1-color)', borderRadius: 4 }}>
                 <div style={{ display: 'flex', justifyContent: 'space-between', marginBottom: 8 }}>
                   <strong>{result.name}</strong>
@@ -251,17 +358,76 @@ const HelmTestJobs: React.FC = () => {
                 <div style={{ fontSize: 12, color: 'var(--td-text-color-secondary)', marginTop: 4 }}>
                   {new Date(result.startedAt).toLocaleString()} - {new Date(result.completedAt).toLocaleString()}
                 </div>
+                {result.attempts !== undefined && result.attempts > 1 && (
+                  <div style={{ fontSize: 12, marginTop: 4 }}>
+                    Attempt {result.attempts}
+                    {result.phase === 'Succeeded' && <Tag theme="warning" variant="light" size="small" style={{ marginLeft: 4 }}>Flaky</Tag>}
+                  </div>
+                )}
+                {result.counts && (
+                  <div style={{ fontSize: 12, marginTop: 4 }}>
+                    Cases: {result.counts.passed} passed, {result.counts.failed} failed, {result.counts.skipped} skipped
+                  </div>
+                )}
+                {result.cases?.filter(c => c.phase === 'Failed').map(c => (
+                  <div key={`${c.suite}/${c.name}`} style={{ fontSize: 12, marginTop: 4 }}>
+                    <Tag theme="danger" size="small">Failed</Tag> {c.suite ? `${c.suite}: ` : ''}{c.name}
+                    {c.message && <pre style={{ margin: '4px 0', whiteSpace: 'pre-wrap' }}>{c.message}</pre>}
+                  </div>
+                ))}
+                {result.logs && (
+                  <pre style={{ marginTop: 8, padding: 8, background: 'var(--td-bg-color-secondary)', borderRadius: 4, whiteSpace: 'pre-wrap', maxHeight: 300, overflow: 'auto' }}>
+                    {result.logs}
+                  </pre>
+                )}
+                {result.logRef && currentJob && currentRun && (
+                  <a href={helmTestJobApi.runLogsUrl(currentJob.metadata.namespace, currentJob.metadata.name, currentRun.metadata.name, 'test')} target="_blank" rel="noreferrer">
+                    Full log
+                  </a>
+                )}
               </div>
             ))}
 
-            <h3>Hook Results</h3>
-            {currentJob.status.hookResults?.map((result, index) => (
-              <div key={index} style={{ marginBottom: 12, padding: 12, border: '1px solid var(--td-border-level-1-color)', borderRadius: 4 }}>
-                <div style={{ display: 'flex', justifyContent: 'space-between', marginBottom: 8 }}>
-                  <strong>{result.name}</strong>
-                  <Tag theme={result.phase === 'Succeeded' ? 'success' : 'danger'}>{result.phase}</Tag>
-                </div>
-                <div>{result.message}</div>
+            {(['preTest', 'postTest'] as const).map(stage => (
+              <div key={stage}>
+                <h3>Hook Results ({stage})</h3>
+                {currentRun?.status.hookResults?.[stage]?.map((result, index) => (
+                  <div key={index} style={{ marginBottom: 12, padding: 12, border: '1px solid var(--td-border-level-1-color)', borderRadius: 4 }}>
+                    <div style={{ display: 'flex', justifyContent: 'space-between', marginBottom: 8 }}>
+                      <strong>{result.name}</strong>
+                      <Tag theme={result.phase === 'Succeeded' ? 'success' : result.phase === 'Failed' ? 'danger' : 'primary'}>{result.phase}</Tag>
+                    </div>
+                    <div>{result.message}</div>
+                    <div style={{ fontSize: 12, color: 'var(--td-text-color-secondary)', marginTop: 4 }}>
+                      {result.podName && <span>Pod: {result.podName} </span>}
+                      {result.exitCode !== undefined && <span>Exit code: {result.exitCode} </span>}
+                      {result.attempts !== undefined && <span>Attempts: {result.attempts} </span>}
+                      {result.startedAt && <span>{new Date(result.startedAt).toLocaleString()}</span>}
+                      {result.completedAt && <span> - {new Date(result.completedAt).toLocaleString()}</span>}
+                    </div>
+                    {result.disruption && (
+                      <div style={{ fontSize: 12, marginTop: 4 }}>
+                        Disrupted ({result.disruption.action}) in {result.disruption.namespace}: {result.disruption.targets?.join(', ')}
+                        {result.disruption.restoredAt && <span>, restored at {new Date(result.disruption.restoredAt).toLocaleString()}</span>}
+                      </div>
+                    )}
+                    {result.outputs && (
+                      <div style={{ fontSize: 12, marginTop: 4 }}>
+                        Outputs: {Object.keys(result.outputs).join(', ')}
+                      </div>
+                    )}
+                    {result.logs && (
+                      <pre style={{ marginTop: 8, padding: 8, background: 'var(--td-bg-color-secondary)', borderRadius: 4, whiteSpace: 'pre-wrap', maxHeight: 300, overflow: 'auto' }}>
+                        {result.logs}
+                      </pre>
+                    )}
+                    {result.logRef && currentJob && currentRun && (
+                      <a href={helmTestJobApi.runLogsUrl(currentJob.metadata.namespace, currentJob.metadata.name, currentRun.metadata.name, stage, result.name)} target="_blank" rel="noreferrer">
+                        Full log
+                      </a>
+                    )}
+                  </div>
+                ))}
               </div>
             ))}
           </div>